@@ -0,0 +1,253 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"MidayBrief/logging"
+	"MidayBrief/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// adminRoute is one admin API endpoint. The OpenAPI spec is generated from
+// the same table the router is built from, so the two can't drift apart.
+type adminRoute struct {
+	Method  string
+	Pattern string
+	// Name is the OpenAPI operationId.
+	Name    string
+	Summary string
+	Scope   string
+	// Request and Response are zero values of the JSON bodies, nil for
+	// none. Status is the status code of a successful response.
+	Request  any
+	Response any
+	Status   int
+	Handler  http.HandlerFunc
+}
+
+var adminRoutes = []adminRoute{
+	{
+		Method: http.MethodGet, Pattern: "/team", Name: "getTeam",
+		Summary: "Get the key's team", Scope: db.APIScopeTeamRead,
+		Response: teamResponse{}, Status: http.StatusOK, Handler: handleGetTeam,
+	},
+	{
+		Method: http.MethodGet, Pattern: "/standups", Name: "listStandups",
+		Summary: "List standups", Scope: db.APIScopeStandupsRead,
+		Response: standupListResponse{}, Status: http.StatusOK, Handler: handleListStandups,
+	},
+	{
+		Method: http.MethodPost, Pattern: "/standups", Name: "createStandup",
+		Summary: "Create a standup", Scope: db.APIScopeStandupsWrite,
+		Request: createStandupRequest{}, Response: standupResponse{}, Status: http.StatusCreated, Handler: handleCreateStandup,
+	},
+	{
+		Method: http.MethodGet, Pattern: "/standups/{standupID}", Name: "getStandup",
+		Summary: "Get a standup", Scope: db.APIScopeStandupsRead,
+		Response: standupResponse{}, Status: http.StatusOK, Handler: handleGetStandup,
+	},
+	{
+		Method: http.MethodPatch, Pattern: "/standups/{standupID}", Name: "updateStandup",
+		Summary: "Update a standup's channel or questions", Scope: db.APIScopeStandupsWrite,
+		Request: updateStandupRequest{}, Response: standupResponse{}, Status: http.StatusOK, Handler: handleUpdateStandup,
+	},
+	{
+		Method: http.MethodDelete, Pattern: "/standups/{standupID}", Name: "deleteStandup",
+		Summary: "Delete a standup with its participants and updates", Scope: db.APIScopeStandupsWrite,
+		Status: http.StatusNoContent, Handler: handleDeleteStandup,
+	},
+	{
+		Method: http.MethodGet, Pattern: "/standups/{standupID}/schedule", Name: "getSchedule",
+		Summary: "Get a standup's schedule", Scope: db.APIScopeStandupsRead,
+		Response: schedule{}, Status: http.StatusOK, Handler: handleGetSchedule,
+	},
+	{
+		Method: http.MethodPut, Pattern: "/standups/{standupID}/schedule", Name: "setSchedule",
+		Summary: "Replace a standup's schedule", Scope: db.APIScopeStandupsWrite,
+		Request: schedule{}, Response: schedule{}, Status: http.StatusOK, Handler: handleSetSchedule,
+	},
+	{
+		Method: http.MethodGet, Pattern: "/standups/{standupID}/participants", Name: "listParticipants",
+		Summary: "List the users prompted for a standup", Scope: db.APIScopeParticipantsRead,
+		Response: participantListResponse{}, Status: http.StatusOK, Handler: handleListParticipants,
+	},
+	{
+		Method: http.MethodPut, Pattern: "/standups/{standupID}/participants/{userID}", Name: "addParticipant",
+		Summary: "Prompt a user for a standup", Scope: db.APIScopeParticipantsWrite,
+		Status: http.StatusNoContent, Handler: handleAddParticipant,
+	},
+	{
+		Method: http.MethodDelete, Pattern: "/standups/{standupID}/participants/{userID}", Name: "removeParticipant",
+		Summary: "Stop prompting a user for a standup", Scope: db.APIScopeParticipantsWrite,
+		Status: http.StatusNoContent, Handler: handleRemoveParticipant,
+	},
+	{
+		Method: http.MethodGet, Pattern: "/standups/{standupID}/submissions", Name: "listSubmissions",
+		Summary: "List the updates submitted since the last summary", Scope: db.APIScopeSubmissionsRead,
+		Response: submissionListResponse{}, Status: http.StatusOK, Handler: handleListSubmissions,
+	},
+}
+
+// AdminAPI serves the admin API, to be mounted at /api/v1. Requests
+// authenticate with a team's API key as a bearer token and are limited to
+// that team and the key's scopes. The OpenAPI spec is served unauthenticated
+// at openapi.json.
+func AdminAPI() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/openapi.json", handleOpenAPISpec)
+	r.Group(func(r chi.Router) {
+		r.Use(authenticateAPIKey)
+		for _, route := range adminRoutes {
+			r.With(requireScope(route.Scope)).Method(route.Method, route.Pattern, route.Handler)
+		}
+	})
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "not found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	})
+	return r
+}
+
+// apiKeyTouchInterval limits how often a key's last use is written back.
+const apiKeyTouchInterval = time.Minute
+
+type apiKeyContextKey struct{}
+
+type apiTeamContextKey struct{}
+
+// authenticateAPIKey loads the key and its team into the request context,
+// rejecting unknown and revoked keys and keys of uninstalled teams.
+func authenticateAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="midaybrief"`)
+			writeAPIError(w, http.StatusUnauthorized, "missing bearer API key")
+			return
+		}
+
+		key, err := stores.APIKeys.GetAPIKeyByHash(utils.HashAPIKey(token))
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				slog.ErrorContext(r.Context(), "Failed to look up API key", "error", err)
+				writeAPIError(w, http.StatusInternalServerError, "failed to authenticate")
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="midaybrief", error="invalid_token"`)
+			writeAPIError(w, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+
+		ctx := logging.WithTeam(r.Context(), key.TeamID)
+		team, err := stores.Teams.GetTeamConfig(key.TeamID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load team of API key", "key", key.Prefix, "error", err)
+			writeAPIError(w, http.StatusInternalServerError, "failed to load team")
+			return
+		}
+		if !team.IsActive {
+			writeAPIError(w, http.StatusForbidden, "MidayBrief is not installed in this team")
+			return
+		}
+
+		if now := time.Now().UTC(); key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+			if err := stores.APIKeys.TouchAPIKey(key.ID, now); err != nil {
+				slog.WarnContext(ctx, "Failed to record API key use", "key", key.Prefix, "error", err)
+			}
+		}
+
+		ctx = context.WithValue(ctx, apiKeyContextKey{}, key)
+		ctx = context.WithValue(ctx, apiTeamContextKey{}, team)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireScope rejects requests whose key wasn't granted scope.
+func requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !requestKey(r).HasScope(scope) {
+				writeAPIError(w, http.StatusForbidden, fmt.Sprintf("API key lacks the %s scope", scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func requestKey(r *http.Request) *db.APIKey {
+	return r.Context().Value(apiKeyContextKey{}).(*db.APIKey)
+}
+
+func requestTeam(r *http.Request) *db.TeamConfig {
+	return r.Context().Value(apiTeamContextKey{}).(*db.TeamConfig)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPIJSON(w, status, errorResponse{Error: message})
+}
+
+// maxAPIRequestBody bounds the size of admin API request bodies.
+const maxAPIRequestBody = 64 << 10
+
+// decodeAPIRequest decodes the JSON body into dst, rejecting unknown
+// fields, and writes a 400 response if that fails.
+func decodeAPIRequest(w http.ResponseWriter, r *http.Request, dst any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// requestStandup loads the standup named by the {standupID} URL parameter,
+// writing a 404 response unless it belongs to the key's team.
+func requestStandup(w http.ResponseWriter, r *http.Request) (*db.Standup, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "standupID"), 10, 0)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, "standup not found")
+		return nil, false
+	}
+	standup, err := stores.Standups.GetStandup(uint(id))
+	if err != nil || standup.TeamID != requestTeam(r).TeamID {
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(r.Context(), "Failed to load standup", "standup_id", id, "error", err)
+			writeAPIError(w, http.StatusInternalServerError, "failed to load standup")
+			return nil, false
+		}
+		writeAPIError(w, http.StatusNotFound, "standup not found")
+		return nil, false
+	}
+	return standup, true
+}
+
+// writeAPIStoreError answers a failed storage call, logging it as action.
+func writeAPIStoreError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	slog.ErrorContext(r.Context(), "Admin API request failed", "action", action, "error", err)
+	writeAPIError(w, http.StatusInternalServerError, "failed to "+action)
+}
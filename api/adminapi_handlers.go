@@ -0,0 +1,403 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	apiStandupNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+	apiChannelIDPattern   = regexp.MustCompile(`^[CG][A-Z0-9]+$`)
+	apiUserIDPattern      = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+)
+
+type teamResponse struct {
+	TeamID          string    `json:"team_id"`
+	EnterpriseID    string    `json:"enterprise_id,omitempty"`
+	Timezone        string    `json:"timezone"`
+	IncludeGuests   bool      `json:"include_guests"`
+	IncludeBots     bool      `json:"include_bots"`
+	IncludeAppUsers bool      `json:"include_app_users"`
+	Admins          []string  `json:"admins" doc:"Slack user IDs of the team's MidayBrief admins."`
+	InstalledAt     time.Time `json:"installed_at"`
+}
+
+type standupResponse struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	ChannelID  string    `json:"channel_id" doc:"Channel the summary is posted to; empty until configured."`
+	Questions  []string  `json:"questions"`
+	Schedule   schedule  `json:"schedule"`
+	SyncSource string    `json:"sync_source,omitempty" doc:"channel or usergroup when participants follow one."`
+	SyncID     string    `json:"sync_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type standupListResponse struct {
+	Standups []standupResponse `json:"standups"`
+}
+
+type createStandupRequest struct {
+	Name      string   `json:"name" doc:"Lowercase letters, digits, - and _."`
+	ChannelID string   `json:"channel_id,omitempty"`
+	Questions []string `json:"questions,omitempty" doc:"Defaults to the standard three questions."`
+	Timezone  string   `json:"timezone,omitempty" doc:"IANA timezone; defaults to the team's."`
+	// PromptTime and PostTime are optional here; the standup stays idle
+	// until both are set.
+	PromptTime string `json:"prompt_time,omitempty" doc:"HH:MM, 24-hour, in the standup's timezone."`
+	PostTime   string `json:"post_time,omitempty" doc:"HH:MM, 24-hour, in the standup's timezone."`
+}
+
+// updateStandupRequest changes only the fields that are present.
+type updateStandupRequest struct {
+	ChannelID *string  `json:"channel_id,omitempty"`
+	Questions []string `json:"questions,omitempty"`
+}
+
+// schedule is when a standup's prompts go out and its summary is posted.
+type schedule struct {
+	Timezone   string `json:"timezone" doc:"IANA timezone, e.g. Europe/London."`
+	PromptTime string `json:"prompt_time" doc:"HH:MM, 24-hour; empty to stop prompting."`
+	PostTime   string `json:"post_time" doc:"HH:MM, 24-hour; empty to stop posting summaries."`
+}
+
+type participantResponse struct {
+	UserID  string    `json:"user_id"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type participantListResponse struct {
+	Participants []participantResponse `json:"participants"`
+	SyncSource   string                `json:"sync_source,omitempty" doc:"Set when participants follow a channel or user group and can't be edited."`
+	SyncID       string                `json:"sync_id,omitempty"`
+}
+
+type submissionResponse struct {
+	UserID      string    `json:"user_id"`
+	Text        string    `json:"text"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+type submissionListResponse struct {
+	Submissions []submissionResponse `json:"submissions"`
+}
+
+func standupFromModel(s *db.Standup) standupResponse {
+	return standupResponse{
+		ID:         s.ID,
+		Name:       s.Name,
+		ChannelID:  s.ChannelID,
+		Questions:  s.QuestionList(),
+		Schedule:   schedule{Timezone: s.Timezone, PromptTime: s.PromptTime, PostTime: s.PostTime},
+		SyncSource: s.SyncSource,
+		SyncID:     s.SyncID,
+		CreatedAt:  s.CreatedAt,
+		UpdatedAt:  s.UpdatedAt,
+	}
+}
+
+func handleGetTeam(w http.ResponseWriter, r *http.Request) {
+	team := requestTeam(r)
+	admins, err := stores.Teams.GetTeamAdmins(team.TeamID)
+	if err != nil {
+		writeAPIStoreError(w, r, "list admins", err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, teamResponse{
+		TeamID:          team.TeamID,
+		EnterpriseID:    team.EnterpriseID,
+		Timezone:        team.Timezone,
+		IncludeGuests:   team.IncludeGuests,
+		IncludeBots:     team.IncludeBots,
+		IncludeAppUsers: team.IncludeAppUsers,
+		Admins:          append([]string{}, admins...),
+		InstalledAt:     team.CreatedAt,
+	})
+}
+
+func handleListStandups(w http.ResponseWriter, r *http.Request) {
+	standups, err := stores.Standups.GetStandupsForTeam(requestTeam(r).TeamID)
+	if err != nil {
+		writeAPIStoreError(w, r, "list standups", err)
+		return
+	}
+	resp := standupListResponse{Standups: make([]standupResponse, 0, len(standups))}
+	for i := range standups {
+		resp.Standups = append(resp.Standups, standupFromModel(&standups[i]))
+	}
+	writeAPIJSON(w, http.StatusOK, resp)
+}
+
+func handleCreateStandup(w http.ResponseWriter, r *http.Request) {
+	var req createStandupRequest
+	if !decodeAPIRequest(w, r, &req) {
+		return
+	}
+	team := requestTeam(r)
+
+	req.Name = strings.ToLower(strings.TrimSpace(req.Name))
+	if req.Timezone == "" {
+		req.Timezone = team.Timezone
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	var problems []string
+	if !apiStandupNamePattern.MatchString(req.Name) {
+		problems = append(problems, "name must be lowercase letters, digits, - and _")
+	}
+	problems = append(problems, validateChannelID(req.ChannelID)...)
+	problems = append(problems, validateSchedule(schedule{Timezone: req.Timezone, PromptTime: req.PromptTime, PostTime: req.PostTime})...)
+	if len(problems) > 0 {
+		writeAPIError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+
+	if _, err := stores.Standups.GetStandupByName(team.TeamID, req.Name); err == nil {
+		writeAPIError(w, http.StatusConflict, fmt.Sprintf("standup %s already exists", req.Name))
+		return
+	}
+	standup, err := stores.Standups.CreateStandup(team.TeamID, req.Name, req.Timezone)
+	if err != nil {
+		writeAPIStoreError(w, r, "create standup", err)
+		return
+	}
+
+	steps := []struct {
+		set  bool
+		save func() error
+	}{
+		{req.ChannelID != "", func() error { return stores.Standups.UpdateChannelID(standup.ID, req.ChannelID) }},
+		{len(req.Questions) > 0, func() error { return stores.Standups.UpdateQuestions(standup.ID, req.Questions) }},
+		{req.PromptTime != "", func() error { return stores.Standups.UpdatePromptTime(standup.ID, req.PromptTime) }},
+		{req.PostTime != "", func() error { return stores.Standups.UpdatePostTime(standup.ID, req.PostTime) }},
+	}
+	for _, step := range steps {
+		if step.set {
+			if err := step.save(); err != nil {
+				writeAPIStoreError(w, r, "configure standup", err)
+				return
+			}
+		}
+	}
+
+	if standup, err = stores.Standups.GetStandup(standup.ID); err != nil {
+		writeAPIStoreError(w, r, "load standup", err)
+		return
+	}
+	writeAPIJSON(w, http.StatusCreated, standupFromModel(standup))
+}
+
+func handleGetStandup(w http.ResponseWriter, r *http.Request) {
+	standup, ok := requestStandup(w, r)
+	if !ok {
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, standupFromModel(standup))
+}
+
+func handleUpdateStandup(w http.ResponseWriter, r *http.Request) {
+	standup, ok := requestStandup(w, r)
+	if !ok {
+		return
+	}
+	var req updateStandupRequest
+	if !decodeAPIRequest(w, r, &req) {
+		return
+	}
+
+	var questions []string
+	for _, q := range req.Questions {
+		if q = strings.TrimSpace(q); q != "" {
+			questions = append(questions, q)
+		}
+	}
+	var problems []string
+	if req.ChannelID != nil {
+		problems = append(problems, validateChannelID(*req.ChannelID)...)
+	}
+	if req.Questions != nil && len(questions) == 0 {
+		problems = append(problems, "questions must not be empty")
+	}
+	if len(problems) > 0 {
+		writeAPIError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+
+	if req.ChannelID != nil {
+		if err := stores.Standups.UpdateChannelID(standup.ID, *req.ChannelID); err != nil {
+			writeAPIStoreError(w, r, "update channel", err)
+			return
+		}
+	}
+	if len(questions) > 0 {
+		if err := stores.Standups.UpdateQuestions(standup.ID, questions); err != nil {
+			writeAPIStoreError(w, r, "update questions", err)
+			return
+		}
+	}
+
+	standup, err := stores.Standups.GetStandup(standup.ID)
+	if err != nil {
+		writeAPIStoreError(w, r, "load standup", err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, standupFromModel(standup))
+}
+
+func handleDeleteStandup(w http.ResponseWriter, r *http.Request) {
+	standup, ok := requestStandup(w, r)
+	if !ok {
+		return
+	}
+	if err := stores.Standups.DeleteStandup(standup); err != nil {
+		writeAPIStoreError(w, r, "delete standup", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	standup, ok := requestStandup(w, r)
+	if !ok {
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, standupFromModel(standup).Schedule)
+}
+
+func handleSetSchedule(w http.ResponseWriter, r *http.Request) {
+	standup, ok := requestStandup(w, r)
+	if !ok {
+		return
+	}
+	var req schedule
+	if !decodeAPIRequest(w, r, &req) {
+		return
+	}
+	if problems := validateSchedule(req); len(problems) > 0 {
+		writeAPIError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+
+	if err := stores.Standups.UpdateTimezone(standup.ID, req.Timezone); err != nil {
+		writeAPIStoreError(w, r, "update timezone", err)
+		return
+	}
+	if err := stores.Standups.UpdatePromptTime(standup.ID, req.PromptTime); err != nil {
+		writeAPIStoreError(w, r, "update prompt time", err)
+		return
+	}
+	if err := stores.Standups.UpdatePostTime(standup.ID, req.PostTime); err != nil {
+		writeAPIStoreError(w, r, "update post time", err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, req)
+}
+
+func handleListParticipants(w http.ResponseWriter, r *http.Request) {
+	standup, ok := requestStandup(w, r)
+	if !ok {
+		return
+	}
+	users, err := stores.Participants.GetAllPromptUser(standup.ID)
+	if err != nil {
+		writeAPIStoreError(w, r, "list participants", err)
+		return
+	}
+	resp := participantListResponse{
+		Participants: make([]participantResponse, 0, len(users)),
+		SyncSource:   standup.SyncSource,
+		SyncID:       standup.SyncID,
+	}
+	for _, user := range users {
+		resp.Participants = append(resp.Participants, participantResponse{UserID: user.UserID, AddedAt: user.CreatedAt})
+	}
+	writeAPIJSON(w, http.StatusOK, resp)
+}
+
+func handleAddParticipant(w http.ResponseWriter, r *http.Request) {
+	standup, userID, ok := participantRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := stores.Participants.AddPromptUser(standup.TeamID, standup.ID, userID); err != nil {
+		writeAPIStoreError(w, r, "add participant", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRemoveParticipant(w http.ResponseWriter, r *http.Request) {
+	standup, userID, ok := participantRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := stores.Participants.RemovePromptUser(standup.ID, userID); err != nil {
+		writeAPIStoreError(w, r, "remove participant", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// participantRequest resolves the standup and user of a participant
+// change, refusing changes to standups whose participants are synced.
+func participantRequest(w http.ResponseWriter, r *http.Request) (*db.Standup, string, bool) {
+	standup, ok := requestStandup(w, r)
+	if !ok {
+		return nil, "", false
+	}
+	userID := chi.URLParam(r, "userID")
+	if !apiUserIDPattern.MatchString(userID) {
+		writeAPIError(w, http.StatusBadRequest, "userID must be a Slack user ID")
+		return nil, "", false
+	}
+	if standup.SyncSource != "" {
+		writeAPIError(w, http.StatusConflict, fmt.Sprintf("participants follow %s %s and can't be edited", standup.SyncSource, standup.SyncID))
+		return nil, "", false
+	}
+	return standup, userID, true
+}
+
+func handleListSubmissions(w http.ResponseWriter, r *http.Request) {
+	standup, ok := requestStandup(w, r)
+	if !ok {
+		return
+	}
+	messages, err := stores.Submissions.GetMessagesForStandupToday(r.Context(), standup.ID)
+	if err != nil {
+		writeAPIStoreError(w, r, "list submissions", err)
+		return
+	}
+	resp := submissionListResponse{Submissions: make([]submissionResponse, 0, len(messages))}
+	for _, msg := range messages {
+		resp.Submissions = append(resp.Submissions, submissionResponse{UserID: msg.UserID, Text: msg.Message, SubmittedAt: msg.Timestamp})
+	}
+	writeAPIJSON(w, http.StatusOK, resp)
+}
+
+func validateChannelID(channelID string) []string {
+	if channelID != "" && !apiChannelIDPattern.MatchString(channelID) {
+		return []string{"channel_id must be a Slack channel ID"}
+	}
+	return nil
+}
+
+func validateSchedule(s schedule) []string {
+	var problems []string
+	if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" {
+		problems = append(problems, fmt.Sprintf("invalid timezone %q", s.Timezone))
+	}
+	for _, field := range []struct{ name, value string }{{"prompt_time", s.PromptTime}, {"post_time", s.PostTime}} {
+		if _, err := time.Parse("15:04", field.value); field.value != "" && (err != nil || len(field.value) != 5) {
+			problems = append(problems, fmt.Sprintf("%s must be HH:MM, got %q", field.name, field.value))
+		}
+	}
+	return problems
+}
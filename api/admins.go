@@ -0,0 +1,146 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+var (
+	addAdminPattern    = regexp.MustCompile(`(?i)^\s*add admin\s+(<@U[0-9A-Z]+>\s*)+$`)
+	removeAdminPattern = regexp.MustCompile(`(?i)^\s*remove admin\s+(<@U[0-9A-Z]+>\s*)+$`)
+	listAdminsPattern  = regexp.MustCompile(`(?i)^\s*list admins\s*$`)
+	auditLogPattern    = regexp.MustCompile(`(?i)^\s*audit log\s*$`)
+)
+
+const auditLogLimit = 10
+
+// handleAdminCommand handles the commands that manage who administers the
+// team. It reports false when text isn't one of them.
+func handleAdminCommand(ctx context.Context, team *db.TeamConfig, actorID, text string) (string, bool) {
+	switch {
+	case addAdminPattern.MatchString(text):
+		var reply []string
+		for _, userID := range extractUserIDs(text) {
+			if err := stores.Teams.AddTeamAdmin(team.TeamID, userID); err != nil {
+				slog.ErrorContext(ctx, "Failed to add admin", "user_id", userID, "error", err)
+				reply = append(reply, fmt.Sprintf("Failed to add <@%s> as admin.", userID))
+				continue
+			}
+			recordAudit(ctx, team.TeamID, actorID, db.AuditActionAdminAdded, userID)
+			reply = append(reply, fmt.Sprintf("<@%s> is now an admin.", userID))
+		}
+		return strings.Join(reply, "\n"), true
+
+	case removeAdminPattern.MatchString(text):
+		var reply []string
+		for _, userID := range extractUserIDs(text) {
+			if err := stores.Teams.RemoveTeamAdmin(team.TeamID, userID); err != nil {
+				slog.WarnContext(ctx, "Failed to remove admin", "user_id", userID, "error", err)
+				reply = append(reply, fmt.Sprintf("Couldn't remove <@%s>; a team needs at least one admin.", userID))
+				continue
+			}
+			recordAudit(ctx, team.TeamID, actorID, db.AuditActionAdminRemoved, userID)
+			reply = append(reply, fmt.Sprintf("<@%s> is no longer an admin.", userID))
+		}
+		return strings.Join(reply, "\n"), true
+
+	case listAdminsPattern.MatchString(text):
+		admins, err := stores.Teams.GetTeamAdmins(team.TeamID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list admins", "error", err)
+			return "Failed to list admins.", true
+		}
+		return "👑 Admins: " + mentionList(admins), true
+
+	case auditLogPattern.MatchString(text):
+		events, err := stores.Teams.GetAuditEvents(team.TeamID, auditLogLimit)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to read audit log", "error", err)
+			return "Failed to read the audit log.", true
+		}
+		if len(events) == 0 {
+			return "The audit log is empty.", true
+		}
+
+		var reply strings.Builder
+		reply.WriteString("📜 Recent changes:\n")
+		for _, e := range events {
+			reply.WriteString(fmt.Sprintf("\t• %s — %s by <@%s>", e.CreatedAt.Format("2006-01-02 15:04 MST"), e.Action, e.ActorUserID))
+			if e.Detail != "" {
+				reply.WriteString(" (" + e.Detail + ")")
+			}
+			reply.WriteString("\n")
+		}
+		return reply.String(), true
+	}
+
+	return "", false
+}
+
+// recordReinstall logs a reinstall in the audit trail. The installer is not
+// made an admin; if they weren't one already, the existing admins are told.
+func recordReinstall(ctx context.Context, previous *db.TeamConfig, installerID string) {
+	detail := ""
+	if !previous.IsActive {
+		detail = "after uninstall"
+	}
+	recordAudit(ctx, previous.TeamID, installerID, db.AuditActionReinstalled, detail)
+
+	if stores.Teams.IsTeamAdmin(previous.TeamID, installerID) {
+		return
+	}
+
+	admins, err := stores.Teams.GetTeamAdmins(previous.TeamID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load admins to notify of reinstall", "error", err)
+		return
+	}
+	for _, adminID := range admins {
+		sendDM(ctx, previous.TeamID, adminID, fmt.Sprintf(slackReinstalledByNonAdminMessage, installerID, installerID))
+	}
+}
+
+// welcomeBackMessage summarises the configuration preserved across a
+// reinstall.
+func welcomeBackMessage(ctx context.Context, teamID, installerID, timezone string) string {
+	standups, err := stores.Standups.GetStandupsForTeam(teamID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list standups for welcome message", "error", err)
+	}
+	admins, err := stores.Teams.GetTeamAdmins(teamID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list admins for welcome message", "error", err)
+	}
+
+	settings := "\t• No standups configured yet.\n"
+	if len(standups) > 0 {
+		settings = formatStandupList(standups)
+	}
+
+	msg := fmt.Sprintf(slackWelcomeBackMessage, timezone, settings, mentionList(admins))
+	if !stores.Teams.IsTeamAdmin(teamID, installerID) {
+		msg += "\n\nYou're not an admin of this workspace's MidayBrief setup, so ask one of the admins above if you need to change settings."
+	}
+	return msg
+}
+
+func recordAudit(ctx context.Context, teamID, actorID, action, detail string) {
+	if err := stores.Teams.RecordAudit(teamID, actorID, action, detail); err != nil {
+		slog.ErrorContext(ctx, "Failed to record audit event", "action", action, "error", err)
+	}
+}
+
+func mentionList(userIDs []string) string {
+	if len(userIDs) == 0 {
+		return "none"
+	}
+	mentions := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		mentions[i] = fmt.Sprintf("<@%s>", userID)
+	}
+	return strings.Join(mentions, ", ")
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"MidayBrief/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	createAPIKeyPattern = regexp.MustCompile(`(?i)^\s*create api key\s+([a-z0-9_-]+)((?:\s+[a-z]+:[a-z]+)*)\s*$`)
+	listAPIKeysPattern  = regexp.MustCompile(`(?i)^\s*list api keys\s*$`)
+	revokeAPIKeyPattern = regexp.MustCompile(`(?i)^\s*revoke api key\s+([0-9a-f]+)\s*$`)
+)
+
+// defaultAPIScopes are granted when a key is created without scopes.
+var defaultAPIScopes = []string{db.APIScopeTeamRead, db.APIScopeStandupsRead, db.APIScopeParticipantsRead}
+
+func isAPIKeyCommand(text string) bool {
+	return createAPIKeyPattern.MatchString(text) || listAPIKeysPattern.MatchString(text) || revokeAPIKeyPattern.MatchString(text)
+}
+
+// handleAPIKeyCommand handles the commands that manage the team's admin API
+// keys. It reports false when text isn't one of them.
+func handleAPIKeyCommand(ctx context.Context, team *db.TeamConfig, actorID, text string) (string, bool) {
+	if matches := createAPIKeyPattern.FindStringSubmatch(text); matches != nil {
+		name := strings.ToLower(matches[1])
+		scopes := strings.Fields(strings.ToLower(matches[2]))
+		if len(scopes) == 0 {
+			scopes = defaultAPIScopes
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(db.APIScopes, scope) {
+				return fmt.Sprintf("Unknown scope `%s`. Scopes: `%s`.", scope, strings.Join(db.APIScopes, "`, `")), true
+			}
+		}
+
+		secret, prefix, hash, err := utils.NewAPIKey()
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to generate API key", "error", err)
+			return "Failed to create the API key.", true
+		}
+		key := &db.APIKey{
+			TeamID:    team.TeamID,
+			Name:      name,
+			Prefix:    prefix,
+			KeyHash:   hash,
+			Scopes:    strings.Join(scopes, " "),
+			CreatedBy: actorID,
+			CreatedAt: time.Now().UTC(),
+		}
+		if err := stores.APIKeys.CreateAPIKey(key); err != nil {
+			slog.ErrorContext(ctx, "Failed to save API key", "error", err)
+			return "Failed to create the API key.", true
+		}
+		recordAudit(ctx, team.TeamID, actorID, db.AuditActionAPIKeyCreated, fmt.Sprintf("%s %s", prefix, name))
+		return fmt.Sprintf("🔑 Created API key *%s* (`%s`) with scopes `%s`:\n\n`%s`\n\n"+
+			"Copy it now and delete this message; it won't be shown again. Send it as `Authorization: Bearer <key>` to %s.",
+			name, prefix, strings.Join(scopes, "`, `"), secret, apiBaseURL()), true
+	}
+
+	if listAPIKeysPattern.MatchString(text) {
+		keys, err := stores.APIKeys.GetAPIKeys(team.TeamID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list API keys", "error", err)
+			return "Failed to list API keys.", true
+		}
+		if len(keys) == 0 {
+			return "No API keys yet. Create one with `create api key <name> [scopes]`.", true
+		}
+
+		var reply strings.Builder
+		reply.WriteString("🔑 API keys:\n")
+		for _, key := range keys {
+			lastUsed := "never used"
+			if key.LastUsedAt != nil {
+				lastUsed = "last used " + key.LastUsedAt.Format("2006-01-02 15:04 MST")
+			}
+			reply.WriteString(fmt.Sprintf("\t• *%s* (`%s`) — %s, created by <@%s>, %s\n",
+				key.Name, key.Prefix, strings.Join(key.ScopeList(), " "), key.CreatedBy, lastUsed))
+		}
+		return reply.String(), true
+	}
+
+	if matches := revokeAPIKeyPattern.FindStringSubmatch(text); matches != nil {
+		prefix := strings.ToLower(matches[1])
+		if err := stores.APIKeys.RevokeAPIKey(team.TeamID, prefix); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Sprintf("No API key `%s`. Send `list api keys` to see them.", prefix), true
+			}
+			slog.ErrorContext(ctx, "Failed to revoke API key", "key", prefix, "error", err)
+			return "Failed to revoke the API key.", true
+		}
+		recordAudit(ctx, team.TeamID, actorID, db.AuditActionAPIKeyRevoked, prefix)
+		return fmt.Sprintf("Revoked API key `%s`.", prefix), true
+	}
+
+	return "", false
+}
+
+// apiBaseURL is where the admin API is served.
+func apiBaseURL() string {
+	if cfg.BaseURL == "" {
+		return "/api/v1"
+	}
+	return cfg.BaseURL + "/api/v1"
+}
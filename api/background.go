@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// tasks tracks work that handlers started in the background so Drain can
+// wait for it.
+var tasks sync.WaitGroup
+
+// tasksCtx is cancelled by Drain when it gives up waiting, cancelling the
+// tasks still running.
+var tasksCtx, abortTasks = context.WithCancel(context.Background())
+
+// goTask runs task in the background once the request that started it has
+// been answered. task keeps ctx's values but not its cancellation, and is
+// cancelled after timeout or when Drain gives up.
+func goTask(ctx context.Context, timeout time.Duration, task func(context.Context)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	stop := context.AfterFunc(tasksCtx, cancel)
+	tasks.Add(1)
+	go func() {
+		defer tasks.Done()
+		defer cancel()
+		defer stop()
+		task(ctx)
+	}()
+}
+
+// Drain waits for background tasks started by handlers. If ctx is done
+// first, the tasks are cancelled and ctx's error is returned.
+func Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		tasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		abortTasks()
+		return fmt.Errorf("api.Drain: tasks still running: %w", ctx.Err())
+	}
+}
@@ -1,13 +1,28 @@
 package api
 
+import "time"
+
+const (
+	oauthStateCookie = "midaybrief_oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+)
+
+const (
+	dashboardPath             = "/dashboard"
+	dashboardCallbackEndpoint = dashboardPath + "/callback"
+	dashboardLoginStateCookie = "midaybrief_login_state"
+	dashboardSessionCookie    = "midaybrief_session"
+	slackOpenIDAuthorizeURL   = "https://slack.com/openid/connect/authorize"
+	slackOpenIDAuthorizeScope = "openid profile"
+	slackOpenIDIssuer         = "https://slack.com"
+)
+
 const (
 	slackOAuthAuthorizeURL   = "https://slack.com/oauth/v2/authorize"
-	slackOAuthTokenURL       = "https://slack.com/api/oauth.v2.access"
-	slackOAuthAuthorizeScope = "chat:write,users:read,channels:read,groups:read"
+	slackOAuthAuthorizeScope = "chat:write,users:read,channels:read,groups:read,usergroups:read"
 	slackCallbackEndpoint    = "/slack/oauth/callback"
-	slackPostMessagesURL     = "https://slack.com/api/chat.postMessage"
-	slackUserInfoURL         = "https://slack.com/api/users.info"
-	slackUsersListURL        = "https://slack.com/api/users.list"
+	slackAppDeepLink         = "slack://app?team=%s&id=%s"
+	slackAppRedirectURL      = "https://slack.com/app_redirect?app=%s"
 	slackWelcomeMessage      = "Hey there! 👋 Thanks for installing *MidayBrief* — your team's stand-up assistant.\n\n" +
 		"I’ve auto-detected your timezone as *%s*. If that’s not right, you can change it anytime with:\n" +
 		"`timezone Your/Timezone` (e.g. `timezone Europe/London`)\n\n" +
@@ -21,6 +36,24 @@ const (
 		"4️⃣ Decide who gets prompted:\n" +
 		"• To include everyone: `add all users`\n" +
 		"• To choose specific people: `add user @alice @bob`\n\n" +
-		"• To remove specific people: `remove user @alice @bob`\n\n" +
+		"• To remove specific people: `remove user @alice @bob`\n" +
+		"• To keep participants in sync with a channel or user group: `sync channel #team` or `sync group @squad`\n" +
+		"• Guests are included and bots left out by default; change this with `exclude guests` or `include bots`\n\n" +
+		"5️⃣ Optionally, customise the questions:\n" +
+		"`questions What did you do yesterday? | What's next? | Any blockers?`\n\n" +
+		"👥 Running several squads? Create more standups with `create standup backend` and prefix any command " +
+		"with the standup name, e.g. `standup backend post time 10:00`. Use `list standups` to see them all.\n\n" +
+		"👑 Share admin rights with `add admin @alice`, see them with `list admins`, and review changes with `audit log`.\n\n" +
+		"🔑 Automating setup? Create a key for the admin API with `create api key <name>`; see `list api keys` and `revoke api key <id>`.\n\n" +
 		"🛠️ You can always tweak these settings later by sending the individual commands above."
+
+	slackWelcomeBackMessage = "Welcome back! 👋 *MidayBrief* has been reinstalled and your existing setup was kept.\n\n" +
+		"🌍 Timezone: *%s*\n\n" +
+		"📋 Standups:\n%s\n" +
+		"👑 Admins: %s\n\n" +
+		"Send `list standups` to review these settings, or any setup command to change them."
+	slackReinstalledByNonAdminMessage = "Heads up: <@%s> reinstalled *MidayBrief*. Your configuration was preserved and the admins list is unchanged. " +
+		"Send `add admin <@%s>` if they should manage settings."
+	slackEnterpriseWelcomeMessage = "MidayBrief is now available across *%s*. Each workspace keeps its own standups: " +
+		"the setup commands below apply to the workspace you send them from, and org admins can run them in any workspace.\n\n"
 )
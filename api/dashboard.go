@@ -0,0 +1,293 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"MidayBrief/logging"
+	"MidayBrief/slack"
+	"MidayBrief/utils"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// Dashboard serves the web dashboard, to be mounted at /dashboard. Users
+// sign in with Slack; members of an installed team can browse the history
+// of their standups and admins can edit every standup's settings.
+func Dashboard() http.Handler {
+	r := chi.NewRouter()
+	r.Use(dashboardHeaders)
+	r.Get("/login", handleDashboardLogin)
+	r.Get("/callback", handleDashboardCallback)
+	r.Group(func(r chi.Router) {
+		r.Use(requireDashboardSession)
+		r.Get("/", handleDashboardHome)
+		r.Post("/logout", handleDashboardLogout)
+		r.Get("/standups/{standupID}", handleDashboardHistory)
+		r.Get("/standups/{standupID}/settings", handleDashboardSettings)
+		r.Post("/standups/{standupID}/settings", handleDashboardSaveSettings)
+	})
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		renderDashboardError(w, r, http.StatusNotFound, "Page not found", "There's nothing here.")
+	})
+	return r
+}
+
+// dashboardHeaders keeps pages out of frames and caches and limits them to
+// the inline styles and charts they use.
+func dashboardHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src 'self' data:; form-action 'self'; frame-ancestors 'none'; base-uri 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "same-origin")
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// openIDNonce derives the ID token nonce from the login's state, which the
+// state cookie already binds to the browser.
+func openIDNonce(state string) string {
+	sum := sha256.Sum256([]byte("nonce:" + state))
+	return hex.EncodeToString(sum[:])
+}
+
+func handleDashboardLogin(w http.ResponseWriter, r *http.Request) {
+	clientID, baseURL := cfg.Slack.ClientID, cfg.BaseURL
+	if clientID == "" || baseURL == "" {
+		renderDashboardError(w, r, http.StatusInternalServerError, "Sign in unavailable", "Sign in with Slack isn't configured on this server.")
+		return
+	}
+
+	state, err := newOAuthState()
+	if err == nil {
+		err = stores.State.SaveOAuthState(state, oauthStateTTL, r.Context())
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to start dashboard sign in", "error", err)
+		renderDashboardError(w, r, http.StatusInternalServerError, "Sign in failed", "We couldn't start signing you in. Please try again.")
+		return
+	}
+	setOAuthStateCookie(w, dashboardLoginStateCookie, dashboardCallbackEndpoint, state)
+
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {clientID},
+		"scope":         {slackOpenIDAuthorizeScope},
+		"redirect_uri":  {baseURL + dashboardCallbackEndpoint},
+		"state":         {state},
+		"nonce":         {openIDNonce(state)},
+	}
+	http.Redirect(w, r, slackOpenIDAuthorizeURL+"?"+params.Encode(), http.StatusFound)
+}
+
+func handleDashboardCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		slog.InfoContext(r.Context(), "Dashboard sign in was not completed", "error_code", errCode)
+		renderDashboardError(w, r, http.StatusBadRequest, "Sign in cancelled", "Signing in was cancelled or denied in Slack.")
+		return
+	}
+	if !verifyOAuthState(w, r, dashboardLoginStateCookie, dashboardCallbackEndpoint) {
+		renderDashboardError(w, r, http.StatusBadRequest, "Sign in failed", "This sign-in link is invalid or has expired. Please sign in again.")
+		return
+	}
+
+	clientID, clientSecret := cfg.Slack.ClientID, cfg.Slack.ClientSecret
+	resp, err := slackClient.OpenIDConnectToken(r.Context(), clientID, clientSecret, query.Get("code"), cfg.BaseURL+dashboardCallbackEndpoint)
+	if err != nil {
+		slog.WarnContext(r.Context(), "OpenID Connect exchange failed", "error", err)
+		if code := slack.ErrorCode(err); code != "" {
+			renderDashboardError(w, r, http.StatusBadRequest, "Sign in failed", fmt.Sprintf("Slack rejected the sign in (%s).", code))
+		} else {
+			renderDashboardError(w, r, http.StatusBadGateway, "Sign in failed", "We couldn't reach Slack to sign you in.")
+		}
+		return
+	}
+	claims, err := parseIDToken(resp.IDToken, clientID, openIDNonce(query.Get("state")), time.Now())
+	if err != nil {
+		slog.WarnContext(r.Context(), "Rejected ID token", "error", err)
+		renderDashboardError(w, r, http.StatusBadRequest, "Sign in failed", "Slack's answer couldn't be verified. Please sign in again.")
+		return
+	}
+
+	ctx := logging.WithTeam(r.Context(), claims.TeamID)
+	team, err := stores.Teams.GetTeamConfig(claims.TeamID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.ErrorContext(ctx, "Failed to load team for dashboard sign in", "error", err)
+		renderDashboardError(w, r, http.StatusInternalServerError, "Sign in failed", "We couldn't load your workspace. Please try again.")
+		return
+	}
+	if team == nil || !team.IsActive {
+		renderDashboardError(w, r, http.StatusForbidden, "Not installed", "MidayBrief isn't installed in your Slack workspace.")
+		return
+	}
+
+	sessionID, err := newOAuthState()
+	var csrfToken string
+	if err == nil {
+		csrfToken, err = newOAuthState()
+	}
+	if err == nil {
+		err = stores.State.SaveSession(sessionID, utils.Session{
+			TeamID:    claims.TeamID,
+			UserID:    claims.UserID,
+			Name:      claims.Name,
+			CSRFToken: csrfToken,
+		}, ctx)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create dashboard session", "error", err)
+		renderDashboardError(w, r, http.StatusInternalServerError, "Sign in failed", "We couldn't sign you in. Please try again.")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     dashboardSessionCookie,
+		Value:    sessionID,
+		Path:     dashboardPath,
+		MaxAge:   int(utils.SessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.BaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	slog.InfoContext(ctx, "Signed in to dashboard", "user_id", claims.UserID)
+	http.Redirect(w, r, dashboardPath+"/", http.StatusFound)
+}
+
+// idTokenClaims are the claims of a Sign in with Slack ID token the
+// dashboard uses.
+type idTokenClaims struct {
+	Issuer   string `json:"iss"`
+	Audience string `json:"aud"`
+	Expiry   int64  `json:"exp"`
+	Nonce    string `json:"nonce"`
+	Name     string `json:"name"`
+	UserID   string `json:"https://slack.com/user_id"`
+	TeamID   string `json:"https://slack.com/team_id"`
+}
+
+// parseIDToken decodes an ID token and checks it was issued by Slack to us
+// for this login and hasn't expired. The signature isn't checked: the token
+// comes straight from Slack's token endpoint over TLS, which OpenID Connect
+// accepts in its place for the authorization code flow.
+func parseIDToken(token, clientID, nonce string, now time.Time) (*idTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("parseIDToken: malformed token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("parseIDToken: malformed payload: %w", err)
+	}
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("parseIDToken: malformed claims: %w", err)
+	}
+
+	switch {
+	case claims.Issuer != slackOpenIDIssuer:
+		return nil, fmt.Errorf("parseIDToken: unexpected issuer %q", claims.Issuer)
+	case claims.Audience != clientID:
+		return nil, fmt.Errorf("parseIDToken: unexpected audience %q", claims.Audience)
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return nil, errors.New("parseIDToken: nonce mismatch")
+	case now.Unix() >= claims.Expiry:
+		return nil, errors.New("parseIDToken: token expired")
+	case claims.UserID == "" || claims.TeamID == "":
+		return nil, errors.New("parseIDToken: missing user or team")
+	}
+	return &claims, nil
+}
+
+// dashboardUser is the signed-in user of a dashboard request.
+type dashboardUser struct {
+	SessionID string
+	utils.Session
+	Team  *db.TeamConfig
+	Admin bool
+}
+
+type dashboardUserContextKey struct{}
+
+// requireDashboardSession loads the signed-in user, showing the sign-in page
+// to everyone else. Admin rights and the install are checked on every
+// request so revoking them takes effect at once.
+func requireDashboardSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var session *utils.Session
+		cookie, err := r.Cookie(dashboardSessionCookie)
+		if err == nil {
+			if session, err = stores.State.GetSession(cookie.Value, r.Context()); err != nil {
+				slog.ErrorContext(r.Context(), "Failed to load dashboard session", "error", err)
+				renderDashboardError(w, r, http.StatusInternalServerError, "Something went wrong", "We couldn't load your session. Please try again.")
+				return
+			}
+		}
+		if session == nil {
+			renderDashboardPage(w, r, http.StatusUnauthorized, "signin", dashboardPage{Title: "Sign in"})
+			return
+		}
+
+		ctx := logging.WithTeam(r.Context(), session.TeamID)
+		team, err := stores.Teams.GetTeamConfig(session.TeamID)
+		if err != nil || !team.IsActive {
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				slog.ErrorContext(ctx, "Failed to load team for dashboard", "error", err)
+				renderDashboardError(w, r, http.StatusInternalServerError, "Something went wrong", "We couldn't load your workspace. Please try again.")
+				return
+			}
+			endDashboardSession(w, r, cookie.Value)
+			renderDashboardError(w, r, http.StatusForbidden, "Not installed", "MidayBrief is no longer installed in your Slack workspace.")
+			return
+		}
+
+		user := &dashboardUser{
+			SessionID: cookie.Value,
+			Session:   *session,
+			Team:      team,
+			Admin:     stores.Teams.IsAdmin(team, session.UserID),
+		}
+		ctx = context.WithValue(ctx, dashboardUserContextKey{}, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func requestDashboardUser(r *http.Request) *dashboardUser {
+	return r.Context().Value(dashboardUserContextKey{}).(*dashboardUser)
+}
+
+// checkCSRF reports whether a form was posted from one of our pages.
+func checkCSRF(r *http.Request, user *dashboardUser) bool {
+	token := r.PostFormValue("csrf_token")
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(user.CSRFToken)) == 1
+}
+
+func endDashboardSession(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if err := stores.State.DeleteSession(sessionID, r.Context()); err != nil {
+		slog.WarnContext(r.Context(), "Failed to delete dashboard session", "error", err)
+	}
+	http.SetCookie(w, &http.Cookie{Name: dashboardSessionCookie, Value: "", Path: dashboardPath, MaxAge: -1})
+}
+
+func handleDashboardLogout(w http.ResponseWriter, r *http.Request) {
+	user := requestDashboardUser(r)
+	if !checkCSRF(r, user) {
+		renderDashboardError(w, r, http.StatusForbidden, "Sign out failed", "This form has expired. Please reload the page and try again.")
+		return
+	}
+	endDashboardSession(w, r, user.SessionID)
+	http.Redirect(w, r, dashboardPath+"/", http.StatusSeeOther)
+}
@@ -0,0 +1,468 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultHistoryDays is how many days of history are shown when no
+	// range is picked; maxHistoryDays bounds the range, since every update
+	// in it is decrypted to search and render it.
+	defaultHistoryDays = 30
+	maxHistoryDays     = 366
+)
+
+// standupOverview is a standup's row on the dashboard home page.
+type standupOverview struct {
+	Standup      db.Standup
+	Participants int
+	LastRun      *db.StandupRun
+}
+
+func handleDashboardHome(w http.ResponseWriter, r *http.Request) {
+	user := requestDashboardUser(r)
+	standups, err := visibleStandups(user)
+	if err != nil {
+		renderDashboardStoreError(w, r, "list standups", err)
+		return
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -defaultHistoryDays).Format(time.DateOnly)
+	overviews := make([]standupOverview, 0, len(standups))
+	for _, standup := range standups {
+		users, err := stores.Participants.GetAllPromptUser(standup.ID)
+		if err != nil {
+			renderDashboardStoreError(w, r, "list participants", err)
+			return
+		}
+		runs, err := stores.Submissions.GetStandupRuns(standup.ID, since, "")
+		if err != nil {
+			renderDashboardStoreError(w, r, "load runs", err)
+			return
+		}
+		overview := standupOverview{Standup: standup, Participants: len(users)}
+		if len(runs) > 0 {
+			overview.LastRun = &runs[len(runs)-1]
+		}
+		overviews = append(overviews, overview)
+	}
+	renderDashboardPage(w, r, http.StatusOK, "home", dashboardPage{Title: "Standups", Data: overviews})
+}
+
+// visibleStandups returns every standup of the team to admins and the
+// standups they take part in to everyone else.
+func visibleStandups(user *dashboardUser) ([]db.Standup, error) {
+	if user.Admin {
+		return stores.Standups.GetStandupsForTeam(user.TeamID)
+	}
+	return stores.Standups.GetStandupsForUser(user.TeamID, user.UserID)
+}
+
+// dashboardStandup loads the standup named by the {standupID} URL parameter,
+// rendering a 404 page unless the user may see it.
+func dashboardStandup(w http.ResponseWriter, r *http.Request, user *dashboardUser) (*db.Standup, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "standupID"), 10, 0)
+	if err != nil {
+		renderDashboardError(w, r, http.StatusNotFound, "Standup not found", "There's no such standup in your workspace.")
+		return nil, false
+	}
+	standup, err := stores.Standups.GetStandup(uint(id))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		renderDashboardStoreError(w, r, "load standup", err)
+		return nil, false
+	}
+	if err != nil || standup.TeamID != user.TeamID {
+		renderDashboardError(w, r, http.StatusNotFound, "Standup not found", "There's no such standup in your workspace.")
+		return nil, false
+	}
+
+	if !user.Admin {
+		users, err := stores.Participants.GetAllPromptUser(standup.ID)
+		if err != nil {
+			renderDashboardStoreError(w, r, "list participants", err)
+			return nil, false
+		}
+		if !slices.ContainsFunc(users, func(u db.PromptUser) bool { return u.UserID == user.UserID }) {
+			renderDashboardError(w, r, http.StatusNotFound, "Standup not found", "There's no such standup in your workspace.")
+			return nil, false
+		}
+	}
+	return standup, true
+}
+
+// historyQuery is the history page's filter form.
+type historyQuery struct {
+	From   string
+	To     string
+	UserID string
+	Search string
+}
+
+type historyView struct {
+	Standup      *db.Standup
+	Query        historyQuery
+	Problem      string
+	People       []person
+	Days         []historyDay
+	Matches      int
+	Chart        participationChart
+	Contributors []contributor
+}
+
+type person struct {
+	UserID string
+	Name   string
+}
+
+// historyDay is one summary's updates.
+type historyDay struct {
+	Date    string
+	Run     *db.StandupRun
+	Updates []historyUpdate
+}
+
+type historyUpdate struct {
+	Name  string
+	Time  time.Time
+	Lines []updateLine
+}
+
+// updateLine is a line of an update; the prompt's questions are stored in
+// Slack's *bold* markup.
+type updateLine struct {
+	Text     string
+	Question bool
+}
+
+// contributor is how many of the range's summaries included someone's
+// update.
+type contributor struct {
+	Name    string
+	Days    int
+	Percent int
+}
+
+func handleDashboardHistory(w http.ResponseWriter, r *http.Request) {
+	user := requestDashboardUser(r)
+	standup, ok := dashboardStandup(w, r, user)
+	if !ok {
+		return
+	}
+
+	location, err := time.LoadLocation(standup.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	view := historyView{Standup: standup}
+	view.Query, view.Problem = parseHistoryQuery(r, time.Now().In(location))
+
+	messages, err := stores.Submissions.GetHistory(r.Context(), db.HistoryFilter{StandupID: standup.ID, From: view.Query.From, To: view.Query.To})
+	if err != nil {
+		renderDashboardStoreError(w, r, "load history", err)
+		return
+	}
+	runs, err := stores.Submissions.GetStandupRuns(standup.ID, view.Query.From, view.Query.To)
+	if err != nil {
+		renderDashboardStoreError(w, r, "load runs", err)
+		return
+	}
+	participants, err := stores.Participants.GetAllPromptUser(standup.ID)
+	if err != nil {
+		renderDashboardStoreError(w, r, "list participants", err)
+		return
+	}
+
+	var userIDs []string
+	for _, p := range participants {
+		userIDs = append(userIDs, p.UserID)
+	}
+	for _, msg := range messages {
+		if !slices.Contains(userIDs, msg.UserID) {
+			userIDs = append(userIDs, msg.UserID)
+		}
+	}
+	profiles := GetUserProfiles(r.Context(), user.Team, userIDs)
+	name := func(userID string) string {
+		if profile, ok := profiles[userID]; ok && profile.DisplayName != "" {
+			return profile.DisplayName
+		}
+		return userID
+	}
+	for _, userID := range userIDs {
+		view.People = append(view.People, person{UserID: userID, Name: name(userID)})
+	}
+	sort.Slice(view.People, func(i, j int) bool {
+		return strings.ToLower(view.People[i].Name) < strings.ToLower(view.People[j].Name)
+	})
+
+	view.Chart = newParticipationChart(runs)
+	view.Contributors = contributors(messages, runs, name)
+
+	runsByDate := make(map[string]*db.StandupRun, len(runs))
+	for i := range runs {
+		runsByDate[runs[i].Date] = &runs[i]
+	}
+	search := strings.ToLower(view.Query.Search)
+	for _, msg := range messages {
+		if view.Query.UserID != "" && msg.UserID != view.Query.UserID {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(msg.Message), search) {
+			continue
+		}
+		if len(view.Days) == 0 || view.Days[len(view.Days)-1].Date != msg.SummaryDate {
+			view.Days = append(view.Days, historyDay{Date: msg.SummaryDate, Run: runsByDate[msg.SummaryDate]})
+		}
+		day := &view.Days[len(view.Days)-1]
+		day.Updates = append(day.Updates, historyUpdate{Name: name(msg.UserID), Time: msg.Timestamp.In(location), Lines: updateLines(msg.Message)})
+		view.Matches++
+	}
+
+	renderDashboardPage(w, r, http.StatusOK, "history", dashboardPage{Title: standup.Name, Data: view})
+}
+
+// parseHistoryQuery reads the filter form, defaulting to the last
+// defaultHistoryDays days up to today and clamping longer ranges.
+func parseHistoryQuery(r *http.Request, today time.Time) (historyQuery, string) {
+	query := historyQuery{
+		UserID: strings.TrimSpace(r.URL.Query().Get("user")),
+		Search: strings.TrimSpace(r.URL.Query().Get("q")),
+	}
+	var problems []string
+
+	to, err := time.Parse(time.DateOnly, r.URL.Query().Get("to"))
+	if err != nil {
+		if r.URL.Query().Get("to") != "" {
+			problems = append(problems, "The end date isn't a valid date.")
+		}
+		to, _ = time.Parse(time.DateOnly, today.Format(time.DateOnly))
+	}
+	from, err := time.Parse(time.DateOnly, r.URL.Query().Get("from"))
+	if err != nil {
+		if r.URL.Query().Get("from") != "" {
+			problems = append(problems, "The start date isn't a valid date.")
+		}
+		from = to.AddDate(0, 0, -(defaultHistoryDays - 1))
+	}
+	if from.After(to) {
+		from, to = to, from
+	}
+	if to.Sub(from) >= maxHistoryDays*24*time.Hour {
+		from = to.AddDate(0, 0, -(maxHistoryDays - 1))
+		problems = append(problems, fmt.Sprintf("Showing the last %d days of the range.", maxHistoryDays))
+	}
+
+	query.From, query.To = from.Format(time.DateOnly), to.Format(time.DateOnly)
+	return query, strings.Join(problems, " ")
+}
+
+func updateLines(message string) []updateLine {
+	var lines []updateLine
+	for _, line := range strings.Split(message, "\n") {
+		if len(line) > 2 && strings.HasPrefix(line, "*") && strings.HasSuffix(line, "*") {
+			lines = append(lines, updateLine{Text: strings.Trim(line, "*"), Question: true})
+			continue
+		}
+		lines = append(lines, updateLine{Text: line})
+	}
+	return lines
+}
+
+// contributors counts the summaries each author's updates were part of,
+// as a share of the summaries that prompted anyone.
+func contributors(messages []db.UserMessage, runs []db.StandupRun, name func(string) string) []contributor {
+	days := make(map[string]map[string]bool)
+	for _, msg := range messages {
+		if days[msg.UserID] == nil {
+			days[msg.UserID] = make(map[string]bool)
+		}
+		days[msg.UserID][msg.SummaryDate] = true
+	}
+	prompted := 0
+	for _, run := range runs {
+		if run.Prompted > 0 {
+			prompted++
+		}
+	}
+
+	result := make([]contributor, 0, len(days))
+	for userID, dates := range days {
+		c := contributor{Name: name(userID), Days: len(dates)}
+		if prompted > 0 {
+			c.Percent = min(100, c.Days*100/prompted)
+		}
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Days != result[j].Days {
+			return result[i].Days > result[j].Days
+		}
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+const (
+	chartWidth  = 720
+	chartHeight = 160
+)
+
+// participationChart is a bar chart of the share of participants who sent
+// an update, one bar per summary.
+type participationChart struct {
+	Width   int
+	Height  int
+	Bars    []chartBar
+	First   string
+	Last    string
+	Average int
+}
+
+type chartBar struct {
+	X, Y, Width, Height float64
+	Title               string
+}
+
+func newParticipationChart(runs []db.StandupRun) participationChart {
+	chart := participationChart{Width: chartWidth, Height: chartHeight}
+	var counted []db.StandupRun
+	for _, run := range runs {
+		if run.Prompted > 0 {
+			counted = append(counted, run)
+		}
+	}
+	if len(counted) == 0 {
+		return chart
+	}
+
+	slot := float64(chartWidth) / float64(len(counted))
+	gap := min(slot*0.2, 4)
+	total := 0
+	for i, run := range counted {
+		rate := min(float64(run.Submitted)/float64(run.Prompted), 1)
+		height := max(rate*chartHeight, 1)
+		chart.Bars = append(chart.Bars, chartBar{
+			X:      float64(i)*slot + gap/2,
+			Y:      chartHeight - height,
+			Width:  slot - gap,
+			Height: height,
+			Title:  fmt.Sprintf("%s: %d of %d (%d%%)", run.Date, run.Submitted, run.Prompted, int(rate*100)),
+		})
+		total += int(rate * 100)
+	}
+	chart.First, chart.Last = counted[0].Date, counted[len(counted)-1].Date
+	chart.Average = total / len(counted)
+	return chart
+}
+
+// settingsForm is the standup settings form, as submitted.
+type settingsForm struct {
+	ChannelID  string
+	Timezone   string
+	PromptTime string
+	PostTime   string
+	Questions  string
+}
+
+type settingsView struct {
+	Standup  *db.Standup
+	Form     settingsForm
+	Problems []string
+	Saved    bool
+}
+
+// dashboardAdminStandup is dashboardStandup for pages only admins may use.
+func dashboardAdminStandup(w http.ResponseWriter, r *http.Request) (*db.Standup, bool) {
+	user := requestDashboardUser(r)
+	if !user.Admin {
+		renderDashboardError(w, r, http.StatusForbidden, "Admins only", "Only MidayBrief admins can change standup settings. Ask one to run `add admin` for you in Slack.")
+		return nil, false
+	}
+	return dashboardStandup(w, r, user)
+}
+
+func handleDashboardSettings(w http.ResponseWriter, r *http.Request) {
+	standup, ok := dashboardAdminStandup(w, r)
+	if !ok {
+		return
+	}
+	view := settingsView{
+		Standup: standup,
+		Form: settingsForm{
+			ChannelID:  standup.ChannelID,
+			Timezone:   standup.Timezone,
+			PromptTime: standup.PromptTime,
+			PostTime:   standup.PostTime,
+			Questions:  strings.Join(standup.QuestionList(), "\n"),
+		},
+		Saved: r.URL.Query().Get("saved") != "",
+	}
+	renderDashboardPage(w, r, http.StatusOK, "settings", dashboardPage{Title: standup.Name + " settings", Data: view})
+}
+
+func handleDashboardSaveSettings(w http.ResponseWriter, r *http.Request) {
+	standup, ok := dashboardAdminStandup(w, r)
+	if !ok {
+		return
+	}
+	if !checkCSRF(r, requestDashboardUser(r)) {
+		renderDashboardError(w, r, http.StatusForbidden, "Not saved", "This form has expired. Please reload the page and try again.")
+		return
+	}
+
+	form := settingsForm{
+		ChannelID:  strings.TrimSpace(r.PostFormValue("channel_id")),
+		Timezone:   strings.TrimSpace(r.PostFormValue("timezone")),
+		PromptTime: strings.TrimSpace(r.PostFormValue("prompt_time")),
+		PostTime:   strings.TrimSpace(r.PostFormValue("post_time")),
+		Questions:  r.PostFormValue("questions"),
+	}
+	var questions []string
+	for _, q := range strings.Split(form.Questions, "\n") {
+		if q = strings.TrimSpace(q); q != "" {
+			questions = append(questions, q)
+		}
+	}
+
+	problems := validateChannelID(form.ChannelID)
+	problems = append(problems, validateSchedule(schedule{Timezone: form.Timezone, PromptTime: form.PromptTime, PostTime: form.PostTime})...)
+	if len(questions) == 0 {
+		problems = append(problems, "questions must not be empty")
+	}
+	if len(problems) > 0 {
+		view := settingsView{Standup: standup, Form: form, Problems: problems}
+		renderDashboardPage(w, r, http.StatusBadRequest, "settings", dashboardPage{Title: standup.Name + " settings", Data: view})
+		return
+	}
+
+	updates := []struct {
+		action string
+		save   func() error
+	}{
+		{"update channel", func() error { return stores.Standups.UpdateChannelID(standup.ID, form.ChannelID) }},
+		{"update timezone", func() error { return stores.Standups.UpdateTimezone(standup.ID, form.Timezone) }},
+		{"update prompt time", func() error { return stores.Standups.UpdatePromptTime(standup.ID, form.PromptTime) }},
+		{"update post time", func() error { return stores.Standups.UpdatePostTime(standup.ID, form.PostTime) }},
+		{"update questions", func() error { return stores.Standups.UpdateQuestions(standup.ID, questions) }},
+	}
+	for _, update := range updates {
+		if err := update.save(); err != nil {
+			renderDashboardStoreError(w, r, update.action, err)
+			return
+		}
+	}
+	slog.InfoContext(r.Context(), "Updated standup settings from dashboard", "standup", standup.Name, "user_id", requestDashboardUser(r).UserID)
+	http.Redirect(w, r, fmt.Sprintf("%s/standups/%d/settings?saved=1", dashboardPath, standup.ID), http.StatusSeeOther)
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+// dashboardPage is what every dashboard template renders. User is nil on
+// pages shown before signing in.
+type dashboardPage struct {
+	Title   string
+	Message string
+	User    *dashboardUser
+	Data    any
+}
+
+func renderDashboardPage(w http.ResponseWriter, r *http.Request, status int, name string, page dashboardPage) {
+	if user, ok := r.Context().Value(dashboardUserContextKey{}).(*dashboardUser); ok {
+		page.User = user
+	}
+
+	// Pages are rendered in full before anything is written, so a template
+	// error doesn't leave half a page behind.
+	var buf bytes.Buffer
+	if err := dashboardTemplates.ExecuteTemplate(&buf, name, page); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to render dashboard page", "page", name, "error", err)
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+func renderDashboardError(w http.ResponseWriter, r *http.Request, status int, title, message string) {
+	renderDashboardPage(w, r, status, "error", dashboardPage{Title: title, Message: message})
+}
+
+// renderDashboardStoreError answers a failed storage call, logging it as
+// action.
+func renderDashboardStoreError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	slog.ErrorContext(r.Context(), "Dashboard request failed", "action", action, "error", err)
+	renderDashboardError(w, r, http.StatusInternalServerError, "Something went wrong", "We couldn't "+action+". Please try again.")
+}
+
+var dashboardTemplates = template.Must(template.New("dashboard").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>MidayBrief — {{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: #f8f8fa; color: #1d1c1d; margin: 0; }
+header { background: #4a154b; color: #fff; padding: .75rem 1.5rem; display: flex; align-items: center; gap: 1rem; }
+header a.brand { color: #fff; font-weight: 700; text-decoration: none; margin-right: auto; }
+header form { margin: 0; }
+header button { background: none; border: 1px solid rgba(255,255,255,.6); color: #fff; border-radius: 6px; padding: .25rem .75rem; cursor: pointer; }
+main { max-width: 60rem; margin: 2rem auto; padding: 0 1rem; }
+section, .card { background: #fff; border-radius: 12px; box-shadow: 0 2px 12px rgba(0,0,0,.08); padding: 1.5rem; margin-bottom: 1.5rem; }
+.card.narrow { max-width: 28rem; margin: 10vh auto; text-align: center; }
+h1 { font-size: 1.5rem; margin-top: 0; }
+h2 { font-size: 1.125rem; margin-top: 0; }
+table { width: 100%; border-collapse: collapse; }
+th, td { text-align: left; padding: .5rem; border-bottom: 1px solid #eee; vertical-align: top; }
+a { color: #1264a3; }
+a.button, button.primary { display: inline-block; padding: .6rem 1.25rem; border-radius: 8px; border: 0; background: #4a154b; color: #fff; text-decoration: none; font-weight: 600; font-size: 1rem; cursor: pointer; }
+form.filters { display: flex; flex-wrap: wrap; gap: .75rem; align-items: end; }
+label { display: block; font-size: .875rem; font-weight: 600; margin-bottom: .25rem; }
+input, select, textarea { font: inherit; padding: .4rem; border: 1px solid #ccc; border-radius: 6px; box-sizing: border-box; }
+form.settings input, form.settings textarea { width: 100%; margin-bottom: 1rem; }
+.muted { color: #616061; font-size: .875rem; }
+.problem { background: #fdecea; color: #8a1c12; border-radius: 8px; padding: .75rem 1rem; margin-bottom: 1rem; }
+.notice { background: #e8f5e9; color: #1b5e20; border-radius: 8px; padding: .75rem 1rem; margin-bottom: 1rem; }
+.status-posted { color: #1b5e20; } .status-empty { color: #616061; } .status-failed { color: #8a1c12; }
+.update { margin: 0 0 1rem; } .update p { margin: .15rem 0; white-space: pre-wrap; } .update .question { font-weight: 600; margin-top: .5rem; }
+.meter { background: #eee; border-radius: 4px; height: .75rem; min-width: 8rem; } .meter span { display: block; background: #4a154b; border-radius: 4px; height: 100%; }
+svg rect { fill: #4a154b; } svg text { font-size: 11px; fill: #616061; }
+</style>
+</head>
+<body>
+<header>
+<a class="brand" href="/dashboard/">MidayBrief</a>
+{{with .User}}<span>{{.Name}}{{if .Admin}} · admin{{end}}</span>
+<form method="post" action="/dashboard/logout"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><button>Sign out</button></form>{{end}}
+</header>
+<main>
+{{end}}
+
+{{define "footer"}}</main>
+</body>
+</html>
+{{end}}
+
+{{define "signin"}}{{template "header" .}}
+<div class="card narrow">
+<h1>Standup history</h1>
+<p>Sign in with your Slack account to browse your team's past standups.</p>
+<a class="button" href="/dashboard/login">Sign in with Slack</a>
+</div>
+{{template "footer" .}}{{end}}
+
+{{define "error"}}{{template "header" .}}
+<div class="card narrow">
+<h1>⚠️ {{.Title}}</h1>
+<p>{{.Message}}</p>
+<a class="button" href="/dashboard/">Back to the dashboard</a>
+</div>
+{{template "footer" .}}{{end}}
+
+{{define "home"}}{{template "header" .}}
+<section>
+<h1>Standups</h1>
+{{if .Data}}
+<table>
+<tr><th>Standup</th><th>Schedule</th><th>Participants</th><th>Last summary</th>{{if .User.Admin}}<th></th>{{end}}</tr>
+{{range .Data}}
+<tr>
+<td><a href="/dashboard/standups/{{.Standup.ID}}">{{.Standup.Name}}</a></td>
+<td>{{if and .Standup.PromptTime .Standup.PostTime}}prompt {{.Standup.PromptTime}}, post {{.Standup.PostTime}} <span class="muted">{{.Standup.Timezone}}</span>{{else}}<span class="muted">not scheduled</span>{{end}}</td>
+<td>{{.Participants}}</td>
+<td>{{with .LastRun}}{{.Date}} · <span class="status-{{.Status}}">{{.Status}}</span> · {{.Submitted}} of {{.Prompted}}{{else}}<span class="muted">none yet</span>{{end}}</td>
+{{if $.User.Admin}}<td><a href="/dashboard/standups/{{.Standup.ID}}/settings">Settings</a></td>{{end}}
+</tr>
+{{end}}
+</table>
+{{else}}
+<p class="muted">{{if .User.Admin}}Your workspace has no standups yet. Set one up by messaging MidayBrief in Slack.{{else}}You aren't part of any standup yet.{{end}}</p>
+{{end}}
+</section>
+{{template "footer" .}}{{end}}
+
+{{define "history"}}{{template "header" .}}{{with .Data}}
+<section>
+<h1>{{.Standup.Name}}</h1>
+{{if $.User.Admin}}<p><a href="/dashboard/standups/{{.Standup.ID}}/settings">Edit settings</a></p>{{end}}
+<form class="filters" method="get">
+<div><label for="from">From</label><input id="from" type="date" name="from" value="{{.Query.From}}"></div>
+<div><label for="to">To</label><input id="to" type="date" name="to" value="{{.Query.To}}"></div>
+<div><label for="user">Person</label><select id="user" name="user"><option value="">Everyone</option>
+{{range .People}}<option value="{{.UserID}}"{{if eq .UserID $.Data.Query.UserID}} selected{{end}}>{{.Name}}</option>{{end}}
+</select></div>
+<div><label for="q">Search</label><input id="q" type="search" name="q" value="{{.Query.Search}}" placeholder="Text in updates"></div>
+<div><button class="primary">Show</button></div>
+</form>
+{{if .Problem}}<p class="problem">{{.Problem}}</p>{{end}}
+</section>
+
+<section>
+<h2>Participation</h2>
+{{with .Chart}}{{if .Bars}}
+<p class="muted">Share of participants who sent an update, per summary. Average {{.Average}}%.</p>
+<svg viewBox="0 0 {{.Width}} {{.Height}}" width="100%" role="img" aria-label="Participation per summary">
+{{range .Bars}}<rect x="{{printf "%.1f" .X}}" y="{{printf "%.1f" .Y}}" width="{{printf "%.1f" .Width}}" height="{{printf "%.1f" .Height}}"><title>{{.Title}}</title></rect>{{end}}
+</svg>
+<p class="muted">{{.First}} – {{.Last}}</p>
+{{else}}<p class="muted">No summaries in this range.</p>{{end}}{{end}}
+{{if .Contributors}}
+<table>
+<tr><th>Person</th><th>Summaries with an update</th><th></th></tr>
+{{range .Contributors}}<tr><td>{{.Name}}</td><td>{{.Days}}</td><td><div class="meter"><span style="width: {{.Percent}}%"></span></div></td></tr>{{end}}
+</table>
+{{end}}
+</section>
+
+<section>
+<h2>Updates</h2>
+<p class="muted">{{.Matches}} update{{if ne .Matches 1}}s{{end}} from {{.Query.From}} to {{.Query.To}}.</p>
+{{range .Days}}
+<h3>{{.Date}}{{with .Run}} <span class="muted">· <span class="status-{{.Status}}">{{.Status}}</span> · {{.Submitted}} of {{.Prompted}}</span>{{end}}</h3>
+{{range .Updates}}
+<div class="update">
+<strong>{{.Name}}</strong> <span class="muted">{{.Time.Format "Jan 2 15:04"}}</span>
+{{range .Lines}}<p{{if .Question}} class="question"{{end}}>{{.Text}}</p>{{end}}
+</div>
+{{end}}
+{{else}}
+<p class="muted">No updates match.</p>
+{{end}}
+</section>
+{{end}}{{template "footer" .}}{{end}}
+
+{{define "settings"}}{{template "header" .}}{{with .Data}}
+<section>
+<h1>{{.Standup.Name}} settings</h1>
+<p><a href="/dashboard/standups/{{.Standup.ID}}">Back to history</a></p>
+{{if .Saved}}<p class="notice">Settings saved.</p>{{end}}
+{{range .Problems}}<p class="problem">{{.}}</p>{{end}}
+<form class="settings" method="post">
+<input type="hidden" name="csrf_token" value="{{$.User.CSRFToken}}">
+<label for="channel_id">Summary channel ID</label>
+<input id="channel_id" name="channel_id" value="{{.Form.ChannelID}}" placeholder="C0123456789">
+<label for="timezone">Timezone</label>
+<input id="timezone" name="timezone" value="{{.Form.Timezone}}" placeholder="Europe/London">
+<label for="prompt_time">Prompt time (HH:MM, blank to stop prompting)</label>
+<input id="prompt_time" name="prompt_time" value="{{.Form.PromptTime}}" placeholder="09:30">
+<label for="post_time">Summary time (HH:MM, blank to stop posting)</label>
+<input id="post_time" name="post_time" value="{{.Form.PostTime}}" placeholder="10:00">
+<label for="questions">Questions, one per line</label>
+<textarea id="questions" name="questions" rows="5">{{.Form.Questions}}</textarea>
+<button class="primary">Save</button>
+</form>
+{{if .Standup.SyncSource}}<p class="muted">Participants follow {{.Standup.SyncSource}} {{.Standup.SyncID}}; change that in Slack.</p>{{end}}
+</section>
+{{end}}{{template "footer" .}}{{end}}
+`))
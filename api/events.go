@@ -2,17 +2,20 @@ package api
 
 import (
 	"MidayBrief/db"
+	"MidayBrief/logging"
+	"MidayBrief/metrics"
 	"MidayBrief/utils"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 func HandleSlackEvents(w http.ResponseWriter, r *http.Request) {
@@ -24,51 +27,191 @@ func HandleSlackEvents(w http.ResponseWriter, r *http.Request) {
 
 	var verification urlVerification
 	if err := json.Unmarshal(body, &verification); err == nil && verification.Type == "url_verification" {
+		metrics.EventsReceived.WithLabelValues(verification.Type).Inc()
 		w.Header().Set("Content-Type", "text/plain")
 		w.Write([]byte(verification.Challenge))
 		return
 	}
 
+	// Peek at the event type first: some events (user_change) carry a user
+	// object where SlackEventData expects a user ID.
+	var envelope struct {
+		EventID        string          `json:"event_id"`
+		TeamID         string          `json:"team_id"`
+		EnterpriseID   string          `json:"enterprise_id"`
+		Authorizations []Authorization `json:"authorizations"`
+		Event          struct {
+			Type string `json:"type"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, "Invalid Slack event format", http.StatusBadRequest)
+		return
+	}
+	metrics.EventsReceived.WithLabelValues(envelope.Event.Type).Inc()
+
+	// Handlers outlive the request only in the sense that Slack may hang up
+	// first; keep going, but carry the event's IDs into every log line.
+	ctx := logging.WithTeam(logging.WithEvent(context.WithoutCancel(r.Context()), envelope.EventID), envelope.TeamID)
+	slog.DebugContext(ctx, "Received Slack event", "type", envelope.Event.Type)
+
+	token, duplicate := claimEvent(ctx, envelope.EventID)
+	if duplicate {
+		slog.DebugContext(ctx, "Ignoring a repeated delivery of a Slack event", "retry", r.Header.Get("X-Slack-Retry-Num"))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if token != "" {
+		// A failed delivery gives up its claim so Slack's retry is handled.
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		w = ww
+		defer func() {
+			if status := ww.Status(); status == 0 || status >= http.StatusMultipleChoices {
+				releaseEvent(ctx, envelope.EventID, token)
+			}
+		}()
+	}
+
+	// Lifecycle events for an org-wide install concern the installation
+	// record, which is keyed by the enterprise ID.
+	installID, enterprise := envelope.TeamID, false
+	if len(envelope.Authorizations) > 0 && envelope.Authorizations[0].IsEnterpriseInstall {
+		installID, enterprise = envelope.EnterpriseID, true
+	}
+
+	switch envelope.Event.Type {
+	case "app_uninstalled":
+		handleAppUninstalled(ctx, installID, enterprise)
+		w.WriteHeader(http.StatusOK)
+		return
+	case "tokens_revoked":
+		handleTokensRevoked(ctx, installID, enterprise, body)
+		w.WriteHeader(http.StatusOK)
+		return
+	case "user_change":
+		handleUserChange(ctx, envelope.TeamID, body)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	var event SlackEvent
 	if err := json.Unmarshal(body, &event); err != nil {
 		http.Error(w, "Invalid Slack event format", http.StatusBadRequest)
 		return
 	}
 
+	switch event.Event.Type {
+	case "member_joined_channel", "member_left_channel", "subteam_members_changed":
+		if team, err := stores.Teams.GetOrCreateWorkspaceConfig(event.EnterpriseID, event.TeamID); err == nil && team.IsActive {
+			if err := EnsureFreshToken(ctx, team); err != nil {
+				slog.WarnContext(ctx, "Failed to refresh token", "error", err)
+			}
+			handleMembershipEvent(ctx, event, team)
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	if event.Event.Text == "" {
 		http.Error(w, "Empty text message", http.StatusBadRequest)
 		return
 	}
 
-	team, err := db.GetTeamConfig(event.TeamID)
+	team, err := stores.Teams.GetOrCreateWorkspaceConfig(event.EnterpriseID, event.TeamID)
 	if err != nil {
 		http.Error(w, "Team not configured", http.StatusBadRequest)
 		return
 	}
 
+	if !team.IsActive {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := EnsureFreshToken(ctx, team); err != nil {
+		slog.WarnContext(ctx, "Failed to refresh token", "error", err)
+	}
+
 	if event.Event.Type != "message" || event.Event.ChannelType != "im" || event.Event.User == team.BotUserID {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
 	// Check if user is in the middle of a prompt flow
-	ctx := context.Background()
-	if state, err := utils.GetPromptState(team.TeamID, event.Event.User, ctx); err == nil && state != nil {
+	if state, err := stores.State.GetPromptState(team.TeamID, event.Event.User, ctx); err == nil && state != nil {
 		handlePromptStep(event, team, *state, ctx)
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
 	if isConfig(event.Event.Text) {
-		handleCombinedConfig(event, team)
+		handleCombinedConfig(ctx, event, team)
 	} else {
-		handleUserMessage(event, team)
+		handleUserMessage(ctx, event, team)
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// eventClaimTTL is how long a handled event's ID is remembered. Slack
+// retries an event three times within about five minutes.
+const eventClaimTTL = time.Hour
+
+// claimEvent marks eventID as being handled, so Slack's retries of it are
+// ignored, and returns the token releaseEvent needs. It reports duplicate
+// when the event is being or has been handled. If the state store is
+// unavailable the event is handled without a claim.
+func claimEvent(ctx context.Context, eventID string) (token string, duplicate bool) {
+	if eventID == "" {
+		return "", false
+	}
+	token, err := stores.State.AcquireLock(eventClaimKey(eventID), eventClaimTTL, ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to claim Slack event", "error", err)
+		return "", false
+	}
+	return token, token == ""
+}
+
+func releaseEvent(ctx context.Context, eventID, token string) {
+	if err := stores.State.ReleaseLock(eventClaimKey(eventID), token, ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to release Slack event", "error", err)
+	}
+}
+
+func eventClaimKey(eventID string) string {
+	return "slack_event:" + eventID
+}
+
+// addAllUsersTimeout bounds adding every user of a workspace, which pages
+// through users.list.
+const addAllUsersTimeout = 5 * time.Minute
+
+var (
+	standupScopePattern  = regexp.MustCompile(`(?is)^\s*standup\s+([a-z0-9_-]+)\s+(.+)$`)
+	createStandupPattern = regexp.MustCompile(`(?i)^\s*create standup\s+([a-z0-9_-]+)\s*$`)
+	deleteStandupPattern = regexp.MustCompile(`(?i)^\s*delete standup\s+([a-z0-9_-]+)\s*$`)
+	listStandupsPattern  = regexp.MustCompile(`(?i)^\s*list standups\s*$`)
+	questionsPattern     = regexp.MustCompile(`(?is)^\s*questions\s+(.+)$`)
+	syncChannelPattern   = regexp.MustCompile(`(?i)^\s*sync channel\s+<#(C\w+)\|?[^>]*>`)
+	syncGroupPattern     = regexp.MustCompile(`(?i)^\s*sync group\s+<!subteam\^(S\w+)\|?[^>]*>`)
+	syncOffPattern       = regexp.MustCompile(`(?i)^\s*sync off\s*$`)
+)
+
 func isConfig(text string) bool {
+	if createStandupPattern.MatchString(text) || deleteStandupPattern.MatchString(text) || listStandupsPattern.MatchString(text) {
+		return true
+	}
+	if addAdminPattern.MatchString(text) || removeAdminPattern.MatchString(text) || listAdminsPattern.MatchString(text) || auditLogPattern.MatchString(text) {
+		return true
+	}
+	if userFilterPattern.MatchString(text) || isAPIKeyCommand(text) {
+		return true
+	}
+	if matches := standupScopePattern.FindStringSubmatch(text); matches != nil {
+		return isConfig(matches[2])
+	}
+
 	lowered := strings.ToLower(text)
 	isConfig := regexp.MustCompile(`config\s+<#(C\w+)\|?[^>]*>`).MatchString(text)
 	isPostTime := regexp.MustCompile(`post time\s+\d{2}:\d{2}`).MatchString(lowered)
@@ -77,37 +220,113 @@ func isConfig(text string) bool {
 	isAddAll := strings.Contains(lowered, "add all users")
 	isAddUser := regexp.MustCompile(`add user\s+(<@U[0-9A-Z]+>\s*)+`).MatchString(text)
 	isRemoveUser := regexp.MustCompile(`remove user\s+(<@U[0-9A-Z]+>\s*)+`).MatchString(text)
+	isQuestions := questionsPattern.MatchString(text)
+	isSync := syncChannelPattern.MatchString(text) || syncGroupPattern.MatchString(text) || syncOffPattern.MatchString(text)
+
+	return isConfig || isPostTime || isTimezone || isPromptTime || isAddAll || isAddUser || isRemoveUser || isQuestions || isSync
+}
+
+// resolveStandup looks up the standup a command is scoped to. The default
+// standup is created on demand so unscoped commands work out of the box.
+func resolveStandup(team *db.TeamConfig, name string) (*db.Standup, error) {
+	if strings.EqualFold(name, db.DefaultStandupName) {
+		return stores.Standups.GetOrCreateDefaultStandup(team.TeamID, team.Timezone)
+	}
+	return stores.Standups.GetStandupByName(team.TeamID, name)
+}
 
-	return isConfig || isPostTime || isTimezone || isPromptTime || isAddAll || isAddUser || isRemoveUser
+// standupForUser picks the standup a free-form update belongs to: the
+// user's oldest standup membership, or the default standup otherwise.
+func standupForUser(team *db.TeamConfig, userID string) (*db.Standup, error) {
+	standups, err := stores.Standups.GetStandupsForUser(team.TeamID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(standups) > 0 {
+		return &standups[0], nil
+	}
+	return stores.Standups.GetOrCreateDefaultStandup(team.TeamID, team.Timezone)
 }
 
-func handleUserMessage(event SlackEvent, team *db.TeamConfig) {
-	hash := utils.Hash(event.Event.Text)
-	if db.IsDuplicateMessage(event.TeamID, event.Event.User, hash, team.Timezone) {
-		sendDM(event.TeamID, event.Event.Channel, "Looks like you've already sent this update today.")
+func handleUserMessage(ctx context.Context, event SlackEvent, team *db.TeamConfig) {
+	text := event.Event.Text
+
+	var standup *db.Standup
+	var err error
+	if matches := standupScopePattern.FindStringSubmatch(text); matches != nil {
+		if standup, err = stores.Standups.GetStandupByName(team.TeamID, matches[1]); err == nil {
+			text = strings.TrimSpace(matches[2])
+		}
+	}
+	if standup == nil {
+		if standup, err = standupForUser(team, event.Event.User); err != nil {
+			slog.ErrorContext(ctx, "Failed to resolve standup for update", "user_id", event.Event.User, "error", err)
+			sendDM(ctx, event.TeamID, event.Event.Channel, "Sorry, I couldn't record your update. Please try again.")
+			return
+		}
+	}
+
+	hashes := utils.MessageHashCandidates(event.Event.User, text)
+	if stores.Submissions.IsDuplicateMessage(standup.ID, event.Event.User, hashes, standup.Timezone) {
+		sendDM(ctx, event.TeamID, event.Event.Channel, "Looks like you've already sent this update today.")
 		return
 	}
 
-	encryptedMessage, _ := utils.Encrypt(event.Event.Text)
-	if err := db.SaveUserMessage(event.TeamID, event.Event.User, encryptedMessage); err != nil {
-		log.Printf("Failed to save user message: %v", err)
+	encryptedMessage, err := utils.EncryptForTeam(event.TeamID, text)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to encrypt update", "user_id", event.Event.User, "error", err)
+		sendDM(ctx, event.TeamID, event.Event.Channel, "Sorry, I couldn't record your update. Please try again.")
+		return
+	}
+	if err := stores.Submissions.SaveUserMessage(event.TeamID, standup.ID, event.Event.User, encryptedMessage, hashes[0]); err != nil {
+		slog.ErrorContext(ctx, "Failed to save update", "user_id", event.Event.User, "error", err)
 	} else {
-		log.Printf("User message saved for team %s, user %s", event.TeamID, event.Event.User)
-		sendDM(event.TeamID, event.Event.Channel, "Got your update for today!")
+		slog.InfoContext(ctx, "Saved update", "standup", standup.Name, "user_id", event.Event.User)
+		metrics.SubmissionsReceived.WithLabelValues("message").Inc()
+		sendDM(ctx, event.TeamID, event.Event.Channel, fmt.Sprintf("Got your update for *%s* today!", standup.Name))
 	}
 }
 
-func handleCombinedConfig(event SlackEvent, team *db.TeamConfig) {
-	if event.Event.User != team.AdminUserID {
-		sendDM(team.TeamID, event.Event.Channel, "Only the admin can update team settings.")
+func handleCombinedConfig(ctx context.Context, event SlackEvent, team *db.TeamConfig) {
+	if !stores.Teams.IsAdmin(team, event.Event.User) {
+		sendDM(ctx, team.TeamID, event.Event.Channel, "Only an admin can update team settings.")
+		return
+	}
+
+	text := strings.TrimSpace(event.Event.Text)
+	if reply, ok := handleAdminCommand(ctx, team, event.Event.User, text); ok {
+		sendDM(ctx, team.TeamID, event.Event.Channel, reply)
+		return
+	}
+	if reply, ok := handleAPIKeyCommand(ctx, team, event.Event.User, text); ok {
+		sendDM(ctx, team.TeamID, event.Event.Channel, reply)
+		return
+	}
+	if reply, ok := handleUserFilterCommand(ctx, team, text); ok {
+		sendDM(ctx, team.TeamID, event.Event.Channel, reply)
+		return
+	}
+	if reply, ok := handleStandupCommand(ctx, team, text); ok {
+		sendDM(ctx, team.TeamID, event.Event.Channel, reply)
+		return
+	}
+
+	standupName := db.DefaultStandupName
+	if matches := standupScopePattern.FindStringSubmatch(text); matches != nil {
+		standupName, text = strings.ToLower(matches[1]), strings.TrimSpace(matches[2])
+	}
+
+	standup, err := resolveStandup(team, standupName)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to resolve standup", "standup", standupName, "error", err)
+		sendDM(ctx, team.TeamID, event.Event.Channel, fmt.Sprintf("Standup *%s* not found. Create it with `create standup %s`.", standupName, standupName))
 		return
 	}
 
-	text := event.Event.Text
 	var updates, errors []string
 
 	if channelID := extractChannelID(text); channelID != "" {
-		if err := db.UpdateChannelID(team.TeamID, channelID); err == nil {
+		if err := stores.Standups.UpdateChannelID(standup.ID, channelID); err == nil {
 			updates = append(updates, fmt.Sprintf("channel updated to %s", channelID))
 		} else {
 			errors = append(errors, "Failed to update channel.")
@@ -116,7 +335,7 @@ func handleCombinedConfig(event SlackEvent, team *db.TeamConfig) {
 
 	if timeStr := extractValue(text, `post time (\d{2}:\d{2})`); timeStr != "" {
 		if _, err := time.Parse("15:04", timeStr); err == nil {
-			if err := db.UpdatePostTime(team.TeamID, timeStr); err == nil {
+			if err := stores.Standups.UpdatePostTime(standup.ID, timeStr); err == nil {
 				updates = append(updates, fmt.Sprintf("post time updated to %s", timeStr))
 			} else {
 				errors = append(errors, "Failed to update post time.")
@@ -128,7 +347,7 @@ func handleCombinedConfig(event SlackEvent, team *db.TeamConfig) {
 
 	if zone := extractValue(text, `timezone ([A-Za-z]+/[A-Za-z_]+)`); zone != "" {
 		if _, err := time.LoadLocation(zone); err == nil {
-			if err := db.UpdateTimezone(team.TeamID, zone); err == nil {
+			if err := stores.Standups.UpdateTimezone(standup.ID, zone); err == nil {
 				updates = append(updates, fmt.Sprintf("timezone updated to %s", zone))
 			} else {
 				errors = append(errors, "Failed to update timezone.")
@@ -140,7 +359,7 @@ func handleCombinedConfig(event SlackEvent, team *db.TeamConfig) {
 
 	if promptTime := extractValue(text, `prompt time (\d{2}:\d{2})`); promptTime != "" {
 		if _, err := time.Parse("15:04", promptTime); err == nil {
-			if err := db.UpdatePromptTime(team.TeamID, promptTime); err == nil {
+			if err := stores.Standups.UpdatePromptTime(standup.ID, promptTime); err == nil {
 				updates = append(updates, fmt.Sprintf("prompt time updated to %s", promptTime))
 			} else {
 				errors = append(errors, "Failed to update prompt time.")
@@ -150,47 +369,69 @@ func handleCombinedConfig(event SlackEvent, team *db.TeamConfig) {
 		}
 	}
 
-	if strings.Contains(strings.TrimSpace(strings.ToLower(text)), "add all users") {
-		users, err := getAllTeamUsers(team.AccessToken)
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("Failed to fetch user list for adding. Error - %s", err))
+	if matches := questionsPattern.FindStringSubmatch(text); matches != nil {
+		questions := extractQuestions(matches[1])
+		if len(questions) == 0 {
+			errors = append(errors, "No questions found. Separate questions with `|`, e.g. questions What did you do? | Any blockers?")
+		} else if err := stores.Standups.UpdateQuestions(standup.ID, questions); err == nil {
+			updates = append(updates, fmt.Sprintf("questions updated (%d)", len(questions)))
 		} else {
-			count := 0
-			for _, userID := range users {
-				if err := db.AddPromptUser(team.TeamID, userID); err == nil {
-					count++
-				}
-			}
-			updates = append(updates, fmt.Sprintf("added %d users for prompts", count))
+			errors = append(errors, "Failed to update questions.")
 		}
 	}
 
-	if strings.HasPrefix(strings.ToLower(text), "add user ") {
-		addUsers := extractUserIDs(text)
-		for _, userID := range addUsers {
+	lowered := strings.ToLower(text)
+	changesMembers := strings.Contains(lowered, "add all users") || strings.HasPrefix(lowered, "add user ") || strings.HasPrefix(lowered, "remove user ")
+	if changesMembers && standup.SyncSource != "" {
+		errors = append(errors, fmt.Sprintf("Participants follow %s, so they can't be edited by hand. Send `sync off` first.", syncSourceLabel(standup)))
+	} else {
+		if strings.Contains(strings.TrimSpace(strings.ToLower(text)), "add all users") {
+			// Paging through a large workspace can take longer than Slack
+			// waits for the event to be acknowledged.
+			goTask(ctx, addAllUsersTimeout, func(ctx context.Context) {
+				addAllUsers(ctx, team, standup, event.Event.Channel)
+			})
+			updates = append(updates, "adding all users for prompts; I'll message you when it's done")
+		}
 
-			if err := db.AddPromptUser(team.TeamID, userID); err == nil {
-				updates = append(updates, fmt.Sprintf("added @%s", userID))
-			} else {
-				errors = append(errors, fmt.Sprintf("Failed to add @%s", userID))
+		if strings.HasPrefix(strings.ToLower(text), "add user ") {
+			addUsers := extractUserIDs(text)
+			for _, userID := range addUsers {
+				if err := stores.Participants.AddPromptUser(team.TeamID, standup.ID, userID); err == nil {
+					updates = append(updates, fmt.Sprintf("added @%s", userID))
+				} else {
+					errors = append(errors, fmt.Sprintf("Failed to add @%s", userID))
+				}
 			}
 		}
-	}
 
-	if strings.HasPrefix(strings.ToLower(text), "remove user ") {
-		removeUsers := extractUserIDs(text)
-		for _, userID := range removeUsers {
-			if err := db.RemovePromptUser(team.TeamID, userID); err == nil {
-				updates = append(updates, fmt.Sprintf("removed @%s", userID))
-			} else {
-				errors = append(errors, fmt.Sprintf("Failed to remove @%s", userID))
+		if strings.HasPrefix(strings.ToLower(text), "remove user ") {
+			removeUsers := extractUserIDs(text)
+			for _, userID := range removeUsers {
+				if err := stores.Participants.RemovePromptUser(standup.ID, userID); err == nil {
+					updates = append(updates, fmt.Sprintf("removed @%s", userID))
+				} else {
+					errors = append(errors, fmt.Sprintf("Failed to remove @%s", userID))
+				}
 			}
 		}
 	}
 
+	if matches := syncChannelPattern.FindStringSubmatch(text); matches != nil {
+		updates, errors = enableSync(ctx, team, standup, db.SyncSourceChannel, matches[1], updates, errors)
+	} else if matches := syncGroupPattern.FindStringSubmatch(text); matches != nil {
+		updates, errors = enableSync(ctx, team, standup, db.SyncSourceUserGroup, matches[1], updates, errors)
+	} else if syncOffPattern.MatchString(text) {
+		if err := stores.Standups.UpdateSyncSource(standup.ID, "", ""); err == nil {
+			updates = append(updates, "participant sync turned off; the current list is kept")
+		} else {
+			errors = append(errors, "Failed to turn off participant sync.")
+		}
+	}
+
 	var response strings.Builder
 	if len(updates) > 0 {
-		response.WriteString("✅ Updates:\n")
+		response.WriteString(fmt.Sprintf("✅ Updates for *%s*:\n", standup.Name))
 		for _, u := range updates {
 			response.WriteString("\t• " + u + "\n")
 		}
@@ -202,10 +443,29 @@ func handleCombinedConfig(event SlackEvent, team *db.TeamConfig) {
 		}
 	}
 	if response.Len() == 0 {
-		response.WriteString("No valid configuration found.\nTry: `config #channel`, `post time 17:00`, `timezone Asia/Kolkata`, `add all`, `add/remove @user`.")
+		response.WriteString("No valid configuration found.\nTry: `config #channel`, `post time 17:00`, `timezone Asia/Kolkata`, `add all`, `add/remove @user`, `questions Q1 | Q2`, `sync channel #channel`, `sync group @group`, `sync off`.\n" +
+			"Prefix any of these with `standup <name>` to configure another standup, e.g. `standup backend post time 10:00`.")
 	}
 
-	sendDM(team.TeamID, event.Event.Channel, response.String())
+	sendDM(ctx, team.TeamID, event.Event.Channel, response.String())
+}
+
+// addAllUsers adds every user in the workspace to standup and reports the
+// result in channel.
+func addAllUsers(ctx context.Context, team *db.TeamConfig, standup *db.Standup, channel string) {
+	users, err := getAllTeamUsers(ctx, team)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to list users to add", "standup", standup.Name, "error", err)
+		sendDM(ctx, team.TeamID, channel, fmt.Sprintf("⚠️ Failed to fetch the user list for *%s*. Error - %s", standup.Name, err))
+		return
+	}
+	count := 0
+	for _, userID := range users {
+		if err := stores.Participants.AddPromptUser(team.TeamID, standup.ID, userID); err == nil {
+			count++
+		}
+	}
+	sendDM(ctx, team.TeamID, channel, fmt.Sprintf("✅ Added %d users for prompts to *%s*.", count, standup.Name))
 }
 
 func extractChannelID(text string) string {
@@ -237,76 +497,166 @@ func extractUserIDs(text string) []string {
 	return users
 }
 
-func handlePromptStep(event SlackEvent, team *db.TeamConfig, state utils.PromptState, ctx context.Context) {
-	userID := event.Event.User
-	teamID := team.TeamID
-	accessToken := team.AccessToken
-	text := strings.TrimSpace(event.Event.Text)
+func enableSync(ctx context.Context, team *db.TeamConfig, standup *db.Standup, source, syncID string, updates, errors []string) ([]string, []string) {
+	if err := stores.Standups.UpdateSyncSource(standup.ID, source, syncID); err != nil {
+		return updates, append(errors, "Failed to update participant sync.")
+	}
+	standup.SyncSource, standup.SyncID = source, syncID
 
-	switch state.Step {
-	case 1:
-		state.Responses["yesterday"] = text
-		state.Step = 2
-		utils.SetPromptState(teamID, userID, state, ctx)
-		SendMessage(accessToken, userID, "Got it! What are your plans for today?")
-	case 2:
-		state.Responses["today"] = text
-		state.Step = 3
-		utils.SetPromptState(teamID, userID, state, ctx)
-		SendMessage(accessToken, userID, "Thanks! Do you have any blockers?")
-	case 3:
-		state.Responses["blockers"] = text
-		saveFinalPrompt(teamID, userID, state)
-		utils.DeletePromptState(teamID, userID, ctx)
-		SendMessage(accessToken, userID, "All set! Your standup update has been recorded.")
-	default:
-		utils.DeletePromptState(teamID, userID, ctx)
-		SendMessage(accessToken, userID, "Unexpected error. Prompt session cleared. Please try again.")
+	var added, removed int
+	eligible, err := GetEligibleUsers(ctx, team)
+	if err == nil {
+		added, removed, err = SyncStandupParticipants(ctx, team, standup, eligible)
 	}
+	if err != nil {
+		slog.WarnContext(ctx, "Initial participant sync failed", "standup", standup.Name, "error", err)
+		return updates, append(errors, fmt.Sprintf("Participants will follow %s, but the first sync failed: %s", syncSourceLabel(standup), err))
+	}
+	return append(updates, fmt.Sprintf("participants now follow %s (+%d/-%d)", syncSourceLabel(standup), added, removed)), errors
 }
 
-func saveFinalPrompt(teamID, userID string, state utils.PromptState) {
-	final := fmt.Sprintf("Yesterday: %s\nToday: %s\nBlockers: %s",
-		state.Responses["yesterday"], state.Responses["today"], state.Responses["blockers"])
+func syncSourceLabel(standup *db.Standup) string {
+	if standup.SyncSource == db.SyncSourceUserGroup {
+		return fmt.Sprintf("user group <!subteam^%s>", standup.SyncID)
+	}
+	return fmt.Sprintf("channel <#%s>", standup.SyncID)
+}
 
-	encrypted, _ := utils.Encrypt(final)
-	if err := db.SaveUserMessage(teamID, userID, encrypted); err != nil {
-		log.Printf("Failed to save final prompt message: %v", err)
+func extractQuestions(text string) []string {
+	var questions []string
+	for _, q := range strings.Split(text, "|") {
+		if q = strings.TrimSpace(q); q != "" {
+			questions = append(questions, q)
+		}
 	}
+	return questions
 }
 
-type SlackMessage struct {
-	Channel string `json:"channel"`
-	Text    string `json:"text"`
+// handleStandupCommand handles the commands that manage standups themselves
+// rather than configuring one. It reports false when text isn't one of them.
+func handleStandupCommand(ctx context.Context, team *db.TeamConfig, text string) (string, bool) {
+	if matches := createStandupPattern.FindStringSubmatch(text); matches != nil {
+		name := strings.ToLower(matches[1])
+		if _, err := stores.Standups.GetStandupByName(team.TeamID, name); err == nil {
+			return fmt.Sprintf("Standup *%s* already exists.", name), true
+		}
+		if _, err := stores.Standups.CreateStandup(team.TeamID, name, team.Timezone); err != nil {
+			slog.ErrorContext(ctx, "Failed to create standup", "standup", name, "error", err)
+			return fmt.Sprintf("Failed to create standup *%s*.", name), true
+		}
+		return fmt.Sprintf("Created standup *%s*. Configure it by prefixing commands with `standup %s`, e.g.\n"+
+			"`standup %s config #channel-name`\n`standup %s post time 10:00`\n`standup %s prompt time 09:30`\n`standup %s add user @alice`",
+			name, name, name, name, name, name), true
+	}
+
+	if matches := deleteStandupPattern.FindStringSubmatch(text); matches != nil {
+		name := strings.ToLower(matches[1])
+		standup, err := stores.Standups.GetStandupByName(team.TeamID, name)
+		if err != nil {
+			return fmt.Sprintf("Standup *%s* not found.", name), true
+		}
+		if err := stores.Standups.DeleteStandup(standup); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete standup", "standup", name, "error", err)
+			return fmt.Sprintf("Failed to delete standup *%s*.", name), true
+		}
+		return fmt.Sprintf("Deleted standup *%s*.", name), true
+	}
+
+	if listStandupsPattern.MatchString(text) {
+		standups, err := stores.Standups.GetStandupsForTeam(team.TeamID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list standups", "error", err)
+			return "Failed to list standups.", true
+		}
+		if len(standups) == 0 {
+			return "No standups configured yet. Create one with `create standup <name>`.", true
+		}
+
+		return "📋 Standups:\n" + formatStandupList(standups), true
+	}
+
+	return "", false
 }
 
-func SendMessage(accessToken, channel, text string) error {
-	msg := SlackMessage{
-		Channel: channel,
-		Text:    text,
+func formatStandupList(standups []db.Standup) string {
+	var list strings.Builder
+	for _, s := range standups {
+		users, _ := stores.Participants.GetAllPromptUser(s.ID)
+		channel := "not set"
+		if s.ChannelID != "" {
+			channel = fmt.Sprintf("<#%s>", s.ChannelID)
+		}
+		list.WriteString(fmt.Sprintf("\t• *%s* — channel %s, prompt %s, post %s (%s), %d participants\n",
+			s.Name, channel, orUnset(s.PromptTime), orUnset(s.PostTime), orUnset(s.Timezone), len(users)))
 	}
+	return list.String()
+}
 
-	body, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("SendMessage: failed to marshal message: %w", err)
+func orUnset(value string) string {
+	if value == "" {
+		return "not set"
 	}
-	req, err := http.NewRequest("POST", slackPostMessagesURL, bytes.NewBuffer(body))
+	return value
+}
+
+func handlePromptStep(event SlackEvent, team *db.TeamConfig, state utils.PromptState, ctx context.Context) {
+	userID := event.Event.User
+	teamID := team.TeamID
+	text := strings.TrimSpace(event.Event.Text)
+
+	standup, err := stores.Standups.GetStandup(state.StandupID)
 	if err != nil {
-		return fmt.Errorf("SendMessage: failed to create request: %w", err)
+		slog.ErrorContext(ctx, "Failed to load standup for prompt", "standup_id", state.StandupID, "user_id", userID, "error", err)
+		stores.State.DeletePromptState(teamID, userID, ctx)
+		SendTeamMessage(ctx, team, userID, "Unexpected error. Prompt session cleared. Please try again.")
+		return
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-	req.Header.Set("Content-Type", "application/json")
+	questions := standup.QuestionList()
+	if state.Step < 1 || state.Step > len(questions) {
+		stores.State.DeletePromptState(teamID, userID, ctx)
+		SendTeamMessage(ctx, team, userID, "Unexpected error. Prompt session cleared. Please try again.")
+		return
+	}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("SendMessage: failed to send request to Slack API: %w", err)
+	state.Responses[questions[state.Step-1]] = text
+	if state.Step < len(questions) {
+		state.Step++
+		stores.State.SetPromptState(teamID, userID, state, ctx)
+		SendTeamMessage(ctx, team, userID, "Got it! "+questions[state.Step-1])
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("SendMessage: Slack API responded with status %s", resp.Status)
+	if err := saveFinalPrompt(teamID, userID, standup, state); err != nil {
+		slog.ErrorContext(ctx, "Failed to save prompt answers", "standup", standup.Name, "user_id", userID, "error", err)
+		SendTeamMessage(ctx, team, userID, "Sorry, I couldn't record your update. Please answer the last question again.")
+		return
 	}
+	slog.InfoContext(ctx, "Saved prompt answers", "standup", standup.Name, "user_id", userID)
+	metrics.SubmissionsReceived.WithLabelValues("prompt").Inc()
+	stores.State.DeletePromptState(teamID, userID, ctx)
+	SendTeamMessage(ctx, team, userID, fmt.Sprintf("All set! Your *%s* standup update has been recorded.", standup.Name))
+}
 
+func saveFinalPrompt(teamID, userID string, standup *db.Standup, state utils.PromptState) error {
+	var lines []string
+	for _, question := range standup.QuestionList() {
+		lines = append(lines, fmt.Sprintf("*%s*\n%s", question, state.Responses[question]))
+	}
+	final := strings.Join(lines, "\n")
+
+	encrypted, err := utils.EncryptForTeam(teamID, final)
+	if err != nil {
+		return fmt.Errorf("saveFinalPrompt: failed to encrypt update for team %s: %w", teamID, err)
+	}
+	return stores.Submissions.SaveUserMessage(teamID, standup.ID, userID, encrypted, utils.MessageHash(userID, final))
+}
+
+// SendMessage posts text to a channel, or to a user's DM when channel is a
+// user ID.
+func SendMessage(ctx context.Context, accessToken, channel, text string) error {
+	if _, err := slackClient.PostMessage(ctx, accessToken, channel, text); err != nil {
+		return fmt.Errorf("SendMessage: %w", err)
+	}
 	return nil
 }
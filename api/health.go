@@ -1,10 +1,89 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 )
 
-func HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
-}
\ No newline at end of file
+// readinessTimeout bounds each readiness check so a hung dependency fails
+// the probe instead of stalling it.
+const readinessTimeout = 2 * time.Second
+
+type readinessCheck struct {
+	name  string
+	check func(context.Context) error
+}
+
+var readinessChecks []readinessCheck
+
+// AddReadinessCheck registers a dependency /readyz checks. Checks run
+// concurrently and the instance is ready only when all of them pass.
+func AddReadinessCheck(name string, check func(context.Context) error) {
+	readinessChecks = append(readinessChecks, readinessCheck{name: name, check: check})
+}
+
+type checkResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// HandleLiveness reports that the process is up. It checks no dependencies,
+// so an outage of Postgres or Redis doesn't get every instance restarted.
+func HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// HandleReadiness runs the registered checks and answers 503 with the
+// failing ones if any fail.
+func HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	results := make(map[string]checkResult, len(readinessChecks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range readinessChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			defer cancel()
+
+			start := time.Now()
+			result := checkResult{Status: "ok"}
+			if err := c.check(ctx); err != nil {
+				result = checkResult{Status: "error", Error: err.Error()}
+				slog.WarnContext(r.Context(), "Readiness check failed", "check", c.name, "error", err)
+			}
+			result.DurationMS = time.Since(start).Milliseconds()
+
+			mu.Lock()
+			results[c.name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	resp, status := healthResponse{Status: "ok", Checks: results}, http.StatusOK
+	for _, result := range results {
+		if result.Status != "ok" {
+			resp.Status, status = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeHealth(w, status, resp)
+}
+
+func writeHealth(w http.ResponseWriter, status int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"MidayBrief/metrics"
+	"MidayBrief/slack"
+	"MidayBrief/utils"
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+type tokensRevokedEvent struct {
+	Event struct {
+		Tokens struct {
+			OAuth []string `json:"oauth"`
+			Bot   []string `json:"bot"`
+		} `json:"tokens"`
+	} `json:"event"`
+}
+
+type userChangeEvent struct {
+	Event struct {
+		User slack.User `json:"user"`
+	} `json:"event"`
+}
+
+// handleAppUninstalled deactivates the installation and crypto-shreds its
+// data by destroying its data key; for org-wide installs, where installID
+// is the enterprise ID, the keys of the workspaces it covered go too. When
+// no retention period is configured the rows are deleted straight away;
+// otherwise the scheduler's purge job removes them once the retention
+// period has passed.
+func handleAppUninstalled(ctx context.Context, installID string, enterprise bool) {
+	deactivate, purge := stores.Teams.DeactivateTeam, stores.Teams.PurgeTeam
+	if enterprise {
+		deactivate, purge = stores.Teams.DeactivateEnterprise, stores.Teams.PurgeEnterprise
+	}
+
+	if err := deactivate(installID); err != nil {
+		slog.ErrorContext(ctx, "Failed to deactivate installation", "install_id", installID, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "App uninstalled; installation deactivated", "install_id", installID)
+	recordAudit(ctx, installID, "", db.AuditActionUninstalled, "")
+	metrics.ForgetTeam(installID)
+
+	for _, teamID := range uninstalledTeams(ctx, installID, enterprise) {
+		if err := utils.DestroyTeamDataKey(teamID); err != nil {
+			slog.ErrorContext(ctx, "Failed to destroy data key", "install_id", installID, "team_id", teamID, "error", err)
+		}
+	}
+
+	if db.RetentionPeriod() == 0 {
+		if err := purge(installID); err != nil {
+			slog.ErrorContext(ctx, "Failed to purge installation", "install_id", installID, "error", err)
+			return
+		}
+		slog.InfoContext(ctx, "Purged installation data", "install_id", installID)
+	}
+}
+
+// uninstalledTeams lists the teams whose data an uninstall of installID
+// covers: the installation itself and, for an org-wide install, the
+// workspaces deactivated with it.
+func uninstalledTeams(ctx context.Context, installID string, enterprise bool) []string {
+	teamIDs := []string{installID}
+	if !enterprise {
+		return teamIDs
+	}
+	teams, err := stores.Teams.GetAllTeamConfigs()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list the enterprise's workspaces", "install_id", installID, "error", err)
+		return teamIDs
+	}
+	for _, team := range teams {
+		if team.EnterpriseID == installID && team.TeamID != installID && !team.IsActive {
+			teamIDs = append(teamIDs, team.TeamID)
+		}
+	}
+	return teamIDs
+}
+
+// handleTokensRevoked treats revocation of the bot token as an uninstall.
+// Revoked user tokens are ignored because the app only stores the bot token.
+func handleTokensRevoked(ctx context.Context, installID string, enterprise bool, body []byte) {
+	var event tokensRevokedEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		slog.WarnContext(ctx, "Invalid tokens_revoked payload", "install_id", installID, "error", err)
+		return
+	}
+
+	if len(event.Event.Tokens.Bot) == 0 {
+		return
+	}
+	handleAppUninstalled(ctx, installID, enterprise)
+}
+
+// handleUserChange removes deactivated users from every standup so they are
+// no longer prompted.
+func handleUserChange(ctx context.Context, teamID string, body []byte) {
+	var event userChangeEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		slog.WarnContext(ctx, "Invalid user_change payload", "error", err)
+		return
+	}
+
+	user := event.Event.User
+	if !user.Deleted {
+		return
+	}
+
+	if err := stores.Participants.RemovePromptUserFromTeam(teamID, user.ID); err != nil {
+		slog.ErrorContext(ctx, "Failed to remove deactivated user", "user_id", user.ID, "error", err)
+		return
+	}
+	if err := stores.State.DeletePromptState(teamID, user.ID, ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to clear prompt state", "user_id", user.ID, "error", err)
+	}
+	slog.InfoContext(ctx, "Removed deactivated user", "user_id", user.ID)
+}
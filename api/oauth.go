@@ -2,111 +2,239 @@ package api
 
 import (
 	"MidayBrief/db"
+	"MidayBrief/logging"
+	"MidayBrief/slack"
 	"MidayBrief/utils"
-	"encoding/json"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"strings"
+
+	"gorm.io/gorm"
 )
 
 func HandleSlackInstall(w http.ResponseWriter, r *http.Request) {
-	clientID := os.Getenv("SLACK_CLIENT_ID")
-	baseURL := os.Getenv("BASE_URL")
+	clientID, baseURL := cfg.Slack.ClientID, cfg.BaseURL
 	if clientID == "" || baseURL == "" {
 		http.Error(w, "Slack Client ID or Base URL not configured", http.StatusInternalServerError)
 		return
 	}
 
-	redirect := fmt.Sprintf(
-		"%s?client_id=%s&scope=%s&redirect_uri=%s/slack/oauth/callback",
-		slackOAuthAuthorizeURL,
-		clientID,
-		slackOAuthAuthorizeScope,
-		baseURL,
-	)
+	state, err := newOAuthState()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to generate OAuth state", "error", err)
+		http.Error(w, "Failed to start installation", http.StatusInternalServerError)
+		return
+	}
+	if err := stores.State.SaveOAuthState(state, oauthStateTTL, r.Context()); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to store OAuth state", "error", err)
+		http.Error(w, "Failed to start installation", http.StatusInternalServerError)
+		return
+	}
 
-	http.Redirect(w, r, redirect, http.StatusFound)
+	setOAuthStateCookie(w, oauthStateCookie, slackCallbackEndpoint, state)
+
+	params := url.Values{
+		"client_id":    {clientID},
+		"scope":        {slackOAuthAuthorizeScope},
+		"redirect_uri": {baseURL + slackCallbackEndpoint},
+		"state":        {state},
+	}
+	http.Redirect(w, r, slackOAuthAuthorizeURL+"?"+params.Encode(), http.StatusFound)
 }
 
 func HandleSlackOAuthCallback(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Query().Get("code")
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		slog.InfoContext(r.Context(), "OAuth install was not completed", "error_code", errCode)
+		renderInstallError(w, http.StatusBadRequest, "The installation was cancelled or denied in Slack.")
+		return
+	}
+
+	if !verifyOAuthState(w, r, oauthStateCookie, slackCallbackEndpoint) {
+		renderInstallError(w, http.StatusBadRequest, "This installation link is invalid or has expired. Please start the installation again.")
+		return
+	}
+
+	code := query.Get("code")
 	if code == "" {
-		http.Error(w, "Missing authorization code", http.StatusBadRequest)
+		renderInstallError(w, http.StatusBadRequest, "Slack didn't return an authorization code.")
 		return
 	}
 
-	clientID := os.Getenv("SLACK_CLIENT_ID")
-	clientSecret := os.Getenv("SLACK_CLIENT_SECRET")
-	baseURL := os.Getenv("BASE_URL")
+	clientID, clientSecret, baseURL := cfg.Slack.ClientID, cfg.Slack.ClientSecret, cfg.BaseURL
 	if clientID == "" || clientSecret == "" || baseURL == "" {
-		http.Error(w, "Missing Slack credentials or base URL", http.StatusInternalServerError)
+		renderInstallError(w, http.StatusInternalServerError, "The app is missing its Slack credentials. Please contact the administrator.")
 		return
 	}
 
 	redirectURI := baseURL + slackCallbackEndpoint
 
-	resp, err := http.PostForm(slackOAuthTokenURL, url.Values{
-		"code":          {code},
-		"client_id":     {clientID},
-		"client_secret": {clientSecret},
-		"redirect_uri":  {redirectURI},
-	})
+	oauthResp, err := slackClient.OAuthV2Access(r.Context(), clientID, clientSecret, code, redirectURI)
 	if err != nil {
-		log.Printf("OAuth request error: %v", err)
-		http.Error(w, "OAuth request failed", http.StatusInternalServerError)
+		slog.WarnContext(r.Context(), "OAuth exchange failed", "error", err)
+		if code := slack.ErrorCode(err); code != "" {
+			renderInstallError(w, http.StatusBadRequest, fmt.Sprintf("Slack rejected the installation (%s).", code))
+		} else {
+			renderInstallError(w, http.StatusBadGateway, "We couldn't reach Slack to complete the installation.")
+		}
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		http.Error(w, "Failed to read OAuth response", http.StatusInternalServerError)
+	// An org-wide install has no team; its record is keyed by the enterprise
+	// ID and workspaces get their own configuration on first use.
+	installerID := oauthResp.AuthedUser.ID
+	installID, installName, enterpriseID := oauthResp.Team.ID, oauthResp.Team.Name, ""
+	if oauthResp.Enterprise != nil {
+		enterpriseID = oauthResp.Enterprise.ID
+		if oauthResp.IsEnterpriseInstall {
+			installID, installName = oauthResp.Enterprise.ID, oauthResp.Enterprise.Name
+		}
+	}
+	if installID == "" {
+		renderInstallError(w, http.StatusBadGateway, "Slack didn't say which workspace the app was installed to.")
 		return
 	}
 
-	var oauthResp OAuthResponse
-	if err := json.Unmarshal(body, &oauthResp); err != nil {
-		http.Error(w, "Failed to parse Slack OAuth response", http.StatusInternalServerError)
+	// Tokens that can no longer be decrypted are replaced by this install.
+	var decryptErr *db.DecryptError
+	ctx := logging.WithTeam(r.Context(), installID)
+	existing, err := stores.Teams.GetTeamConfig(installID)
+	if errors.As(err, &decryptErr) {
+		slog.WarnContext(ctx, "Replacing unreadable tokens", "error", err)
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.ErrorContext(ctx, "Failed to look up existing team config", "error", err)
+		renderInstallError(w, http.StatusInternalServerError, "We couldn't save your workspace configuration.")
 		return
 	}
+	reinstall := existing != nil
 
-	if !oauthResp.Ok {
-		http.Error(w, fmt.Sprintf("Slack error: %s", oauthResp.Error), http.StatusBadRequest)
-		return
+	var timezone string
+	if reinstall && existing.Timezone != "" {
+		timezone = existing.Timezone
+	} else if timezone, err = getUserTimeZone(ctx, &db.TeamConfig{TeamID: installID, AccessToken: oauthResp.AccessToken}, installerID); err != nil {
+		slog.WarnContext(ctx, "Could not fetch team timezone", "error", err)
+		timezone = "UTC"
 	}
 
-	encryptedToken, err := utils.Encrypt(oauthResp.AccessToken)
+	encryptedToken, err := utils.EncryptForTeam(installID, oauthResp.AccessToken)
 	if err != nil {
-		log.Printf("Access token encryption failed: %s", err)
-		encryptedToken = oauthResp.AccessToken
+		slog.ErrorContext(ctx, "Access token encryption failed", "error", err)
+		renderInstallError(w, http.StatusInternalServerError, "We couldn't save your workspace configuration.")
+		return
 	}
 
-	timezone, err := getUserTimeZone(oauthResp.AccessToken, oauthResp.AuthedUser.ID)
-	if err != nil {
-		log.Println("Could not fetch team timezone:", err)
-		timezone = "UTC"
+	var encryptedRefreshToken string
+	if oauthResp.RefreshToken != "" {
+		if encryptedRefreshToken, err = utils.EncryptForTeam(installID, oauthResp.RefreshToken); err != nil {
+			slog.ErrorContext(ctx, "Refresh token encryption failed", "error", err)
+			renderInstallError(w, http.StatusInternalServerError, "We couldn't save your workspace configuration.")
+			return
+		}
 	}
 
 	team := db.TeamConfig{
-		TeamID:      oauthResp.Team.ID,
-		AccessToken: encryptedToken,
-		BotUserID:   oauthResp.BotUserID,
-		AdminUserID: oauthResp.AuthedUser.ID,
-		Timezone:    timezone,
+		TeamID:              installID,
+		EnterpriseID:        enterpriseID,
+		IsEnterpriseInstall: oauthResp.IsEnterpriseInstall,
+		AccessToken:         encryptedToken,
+		RefreshToken:        encryptedRefreshToken,
+		TokenExpiresAt:      tokenExpiry(oauthResp.ExpiresIn),
+		BotUserID:           oauthResp.BotUserID,
+		InstallerUserID:     installerID,
+		Timezone:            timezone,
 	}
 
-	if err := db.SaveTeamConfig(team); err != nil {
-		log.Printf("Failed to save team config: %v", err)
-		http.Error(w, "Failed to save team configuration", http.StatusInternalServerError)
+	if err := stores.Teams.SaveTeamConfig(team); err != nil {
+		slog.ErrorContext(ctx, "Failed to save team config", "error", err)
+		renderInstallError(w, http.StatusInternalServerError, "We couldn't save your workspace configuration.")
 		return
 	}
-	welcomeMsg := fmt.Sprintf(slackWelcomeMessage, timezone)
-	sendDM(oauthResp.Team.ID, oauthResp.AuthedUser.ID, welcomeMsg)
 
-	log.Printf("OAuth successful for team %s (%s)", oauthResp.Team.Name, oauthResp.Team.ID)
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Slack app installed successfully"))
+	if reinstall {
+		recordReinstall(ctx, existing, installerID)
+		sendDM(ctx, installID, installerID, welcomeBackMessage(ctx, installID, installerID, timezone))
+	} else {
+		if err := stores.Teams.AddTeamAdmin(installID, installerID); err != nil {
+			slog.ErrorContext(ctx, "Failed to add installer as admin", "error", err)
+		}
+		if err := stores.Teams.RecordAudit(installID, installerID, db.AuditActionInstalled, ""); err != nil {
+			slog.ErrorContext(ctx, "Failed to record install", "error", err)
+		}
+		welcomeMsg := fmt.Sprintf(slackWelcomeMessage, timezone)
+		if oauthResp.IsEnterpriseInstall {
+			welcomeMsg = fmt.Sprintf(slackEnterpriseWelcomeMessage, installName) + welcomeMsg
+		}
+		sendDM(ctx, installID, installerID, welcomeMsg)
+	}
+
+	slog.InfoContext(ctx, "OAuth install succeeded", "team_name", installName, "reinstall", reinstall)
+
+	// Deep links need a workspace; org-wide installs fall back to letting
+	// Slack pick one.
+	webLink := fmt.Sprintf(slackAppRedirectURL, url.QueryEscape(oauthResp.AppID)) + "&team=" + url.QueryEscape(oauthResp.Team.ID)
+	appLink := slackAppLink(oauthResp.Team.ID, oauthResp.AppID)
+	if oauthResp.IsEnterpriseInstall {
+		webLink = fmt.Sprintf(slackAppRedirectURL, url.QueryEscape(oauthResp.AppID))
+		appLink = ""
+	}
+
+	renderInstallPage(w, http.StatusOK, installPage{
+		Success: true,
+		Title:   "MidayBrief is installed",
+		Message: fmt.Sprintf("MidayBrief has been added to %s. Check your DMs in Slack for the setup steps.", installName),
+		AppLink: appLink,
+		WebLink: webLink,
+	})
+}
+
+func newOAuthState() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// setOAuthStateCookie stores state in the named cookie for the callback at
+// path. The cookie binds the state to this browser, so a callback URL
+// started by someone else can't be replayed here.
+func setOAuthStateCookie(w http.ResponseWriter, name, path, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    state,
+		Path:     path,
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.BaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// verifyOAuthState checks that the callback's state matches the cookie set
+// by setOAuthStateCookie and was issued by us and not used before.
+func verifyOAuthState(w http.ResponseWriter, r *http.Request, name, path string) bool {
+	state := r.URL.Query().Get("state")
+	cookie, err := r.Cookie(name)
+	if state == "" || err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		slog.WarnContext(r.Context(), "OAuth callback state mismatch")
+		return false
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: name, Value: "", Path: path, MaxAge: -1})
+
+	ok, err := stores.State.ConsumeOAuthState(state, r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to verify OAuth state", "error", err)
+		return false
+	}
+	if !ok {
+		slog.WarnContext(r.Context(), "OAuth callback state unknown or expired")
+	}
+	return ok
 }
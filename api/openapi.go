@@ -0,0 +1,161 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// pathParamPattern matches the chi URL parameters of a route pattern.
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// pathParams documents the URL parameters used by adminRoutes.
+var pathParams = map[string]map[string]any{
+	"standupID": {"type": "integer", "description": "ID of one of the team's standups."},
+	"userID":    {"type": "string", "description": "Slack user ID."},
+}
+
+// handleOpenAPISpec serves the OpenAPI 3 description of the admin API.
+func handleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	writeAPIJSON(w, http.StatusOK, openAPISpec())
+}
+
+// openAPISpec describes adminRoutes. Request and response schemas are
+// derived from the Go types of the bodies, with descriptions taken from
+// their doc struct tags.
+func openAPISpec() map[string]any {
+	errorContent := map[string]any{"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(errorResponse{}))}}
+	paths := map[string]any{}
+	for _, route := range adminRoutes {
+		item, ok := paths[route.Pattern].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[route.Pattern] = item
+		}
+
+		success := map[string]any{"description": http.StatusText(route.Status)}
+		if route.Response != nil {
+			success["content"] = map[string]any{"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(route.Response))}}
+		}
+		responses := map[string]any{
+			fmt.Sprint(route.Status): success,
+			"401":                    map[string]any{"description": "Missing or invalid API key.", "content": errorContent},
+			"403":                    map[string]any{"description": "The key lacks the required scope.", "content": errorContent},
+		}
+		if strings.Contains(route.Pattern, "{") {
+			responses["404"] = map[string]any{"description": "Not found.", "content": errorContent}
+		}
+		if route.Request != nil {
+			responses["400"] = map[string]any{"description": "Invalid request.", "content": errorContent}
+		}
+
+		op := map[string]any{
+			"operationId": route.Name,
+			"summary":     route.Summary,
+			"description": fmt.Sprintf("Requires the `%s` scope.", route.Scope),
+			"responses":   responses,
+		}
+		var params []any
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Pattern, -1) {
+			schema := pathParams[match[1]]
+			params = append(params, map[string]any{
+				"name":        match[1],
+				"in":          "path",
+				"required":    true,
+				"description": schema["description"],
+				"schema":      map[string]any{"type": schema["type"]},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if route.Request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(route.Request))}},
+			}
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	spec := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "MidayBrief admin API",
+			"version": "1",
+			"description": "Manage a team's standups and read their updates. Authenticate with an API key created by a team admin " +
+				"(DM the app `create api key <name> <scopes>`) as a bearer token. Scopes: " + strings.Join(db.APIScopes, ", ") + ".",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"securitySchemes": map[string]any{"apiKey": map[string]any{"type": "http", "scheme": "bearer"}},
+		},
+		"security": []any{map[string]any{"apiKey": []any{}}},
+	}
+	if cfg.BaseURL != "" {
+		spec["servers"] = []any{map[string]any{"url": cfg.BaseURL + "/api/v1"}}
+	}
+	return spec
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema returns the JSON schema of values of t as encoding/json
+// marshals them.
+func jsonSchema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		schema := jsonSchema(t.Elem())
+		schema["nullable"] = true
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			schema := jsonSchema(field.Type)
+			if doc := field.Tag.Get("doc"); doc != "" {
+				schema["description"] = doc
+			}
+			properties[name] = schema
+			if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]any{}
+}
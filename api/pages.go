@@ -0,0 +1,77 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"regexp"
+)
+
+type installPage struct {
+	Success bool
+	Title   string
+	Message string
+	// AppLink is trusted as is, as html/template would otherwise replace a
+	// slack:// link with #ZgotmplZ; build it with slackAppLink. WebLink is
+	// used without it.
+	AppLink template.URL
+	WebLink string
+}
+
+var slackIDPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]+$`)
+
+// slackAppLink returns the deep link that opens appID in teamID, or ""
+// unless both are plain Slack IDs.
+func slackAppLink(teamID, appID string) template.URL {
+	if !slackIDPattern.MatchString(teamID) || !slackIDPattern.MatchString(appID) {
+		return ""
+	}
+	return template.URL(fmt.Sprintf(slackAppDeepLink, teamID, appID))
+}
+
+var installPageTemplate = template.Must(template.New("install").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>MidayBrief — {{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: #f8f8fa; color: #1d1c1d; display: flex; justify-content: center; padding-top: 10vh; margin: 0; }
+main { background: #fff; border-radius: 12px; box-shadow: 0 2px 12px rgba(0,0,0,.08); padding: 2.5rem; max-width: 28rem; text-align: center; }
+h1 { font-size: 1.5rem; margin-top: 0; }
+a.button { display: inline-block; margin-top: 1rem; padding: .75rem 1.5rem; border-radius: 8px; background: #4a154b; color: #fff; text-decoration: none; font-weight: 600; }
+p.small { font-size: .875rem; color: #616061; }
+</style>
+</head>
+<body>
+<main>
+{{if .Success}}
+<h1>✅ {{.Title}}</h1>
+<p>{{.Message}}</p>
+<a class="button" href="{{if .AppLink}}{{.AppLink}}{{else}}{{.WebLink}}{{end}}">Open MidayBrief in Slack</a>
+<p class="small">Slack didn't open? <a href="{{.WebLink}}">Continue in your browser</a>.</p>
+{{else}}
+<h1>⚠️ {{.Title}}</h1>
+<p>{{.Message}}</p>
+<a class="button" href="/slack/install">Try again</a>
+{{end}}
+</main>
+</body>
+</html>
+`))
+
+func renderInstallPage(w http.ResponseWriter, status int, page installPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := installPageTemplate.Execute(w, page); err != nil {
+		slog.Error("Failed to render install page", "error", err)
+	}
+}
+
+func renderInstallError(w http.ResponseWriter, status int, message string) {
+	renderInstallPage(w, status, installPage{
+		Title:   "Installation failed",
+		Message: message,
+	})
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInstallPageLinksToSlackApp(t *testing.T) {
+	rec := httptest.NewRecorder()
+	renderInstallPage(rec, http.StatusOK, installPage{
+		Success: true,
+		Title:   "MidayBrief is installed",
+		AppLink: slackAppLink("T1", "A1"),
+		WebLink: "https://slack.com/app_redirect?app=A1&team=T1",
+	})
+
+	body := rec.Body.String()
+	if want := `href="slack://app?team=T1&amp;id=A1"`; !strings.Contains(body, want) {
+		t.Errorf("install page is missing %s:\n%s", want, body)
+	}
+	if strings.Contains(body, "ZgotmplZ") {
+		t.Error("install page has a link html/template rejected")
+	}
+}
+
+func TestInstallPageFallsBackToWebLink(t *testing.T) {
+	rec := httptest.NewRecorder()
+	renderInstallPage(rec, http.StatusOK, installPage{
+		Success: true,
+		AppLink: slackAppLink(`T1"><script>`, "A1"),
+		WebLink: "https://slack.com/app_redirect?app=A1",
+	})
+
+	body := rec.Body.String()
+	if strings.Contains(body, "slack://") || strings.Contains(body, "<script>") {
+		t.Errorf("install page links to an unvalidated team:\n%s", body)
+	}
+	if want := `class="button" href="https://slack.com/app_redirect?app=A1"`; !strings.Contains(body, want) {
+		t.Errorf("install page is missing %s:\n%s", want, body)
+	}
+}
@@ -1,162 +1,87 @@
 package api
 
 import (
-	"MidayBrief/db"
-	"bytes"
-	"encoding/json"
+	"MidayBrief/config"
+	"MidayBrief/metrics"
+	"MidayBrief/slack"
+	"MidayBrief/store"
+	"MidayBrief/tracing"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
-func sendDM(teamID, userChannelID, message string) {
-	team, err := db.GetTeamConfig(teamID)
-	if err != nil {
-		log.Printf("sendDM: failed to get team config for %s: %v", teamID, err)
-		return
-	}
+// cfg holds the settings the handlers use; see SetConfig.
+var cfg = &config.Config{}
 
-	payload := map[string]string{
-		"channel": userChannelID,
-		"text":    message,
-	}
+// SetConfig replaces the handlers' settings and points the Slack client at
+// the configured API root.
+func SetConfig(c *config.Config) {
+	cfg = c
+	slackClient = newSlackClient(c.Slack.APIURL)
+}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("sendDM: failed to marshal payload: %v", err)
-		return
-	}
+// slackClient is used for every Web API call.
+var slackClient = newSlackClient("")
+
+// newSlackClient returns a client for the Web API at baseURL, or Slack's
+// when it is empty, e.g. to point at a fake server.
+func newSlackClient(baseURL string) *slack.Client {
+	return slack.New(
+		slack.WithBaseURL(baseURL),
+		slack.WithObserver(metrics.ObserveSlackRequest),
+		slack.WithTransport(tracing.Transport(http.DefaultTransport, "slack")),
+	)
+}
 
-	req, err := http.NewRequest("POST", slackPostMessagesURL, bytes.NewBuffer(body))
-	if err != nil {
-		log.Printf("sendDM: failed to create request: %v", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+team.AccessToken)
+// SetSlackClient replaces the Slack client, e.g. with one pointing at a test
+// server.
+func SetSlackClient(client *slack.Client) {
+	slackClient = client
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Printf("sendDM: failed to send request: %v", err)
-		return
-	}
-	defer resp.Body.Close()
+// stores holds everything the handlers persist. It defaults to Postgres and
+// Redis; tests and local development can swap in store.NewMemory.
+var stores = store.Default()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("sendDM: Slack API responded with status %s", resp.Status)
-	}
+// SetStores replaces the storage used by the handlers.
+func SetStores(s *store.Stores) {
+	stores = s
 }
 
-func getUserTimeZone(accessToken, userID string) (string, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s?user=%s", slackUserInfoURL, userID), nil)
+func sendDM(ctx context.Context, teamID, userChannelID, message string) {
+	team, err := stores.Teams.GetTeamConfig(teamID)
 	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
+		slog.ErrorContext(ctx, "Failed to load team to send DM", "error", err)
+		return
 	}
-	defer resp.Body.Close()
 
-	var result struct {
-		OK   bool `json:"ok"`
-		User struct {
-			TZ string `json:"tz"`
-		} `json:"user"`
+	if err := SendTeamMessage(ctx, team, userChannelID, message); err != nil {
+		slog.ErrorContext(ctx, "Failed to send DM", "channel", userChannelID, "error", err)
 	}
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
-	}
+func getChannelMembers(ctx context.Context, token, channelID string) ([]string, error) {
+	var members []string
+	cursor := ""
+	for {
+		resp, err := slackClient.ConversationsMembers(ctx, token, channelID, cursor, 200)
+		if err != nil {
+			return nil, fmt.Errorf("list channel members failed: %w", err)
+		}
 
-	if !result.OK {
-		return "", fmt.Errorf("slack api error: could not get timezone")
+		members = append(members, resp.Members...)
+		if cursor = resp.ResponseMetadata.NextCursor; cursor == "" {
+			return members, nil
+		}
 	}
-
-	return result.User.TZ, nil
 }
 
-func getAllTeamUsers(token string) ([]string, error) {
-	req, err := http.NewRequest("GET", slackUsersListURL, nil)
+func getUserGroupMembers(ctx context.Context, token, groupID string) ([]string, error) {
+	users, err := slackClient.UserGroupsUsersList(ctx, token, groupID)
 	if err != nil {
-		return nil, fmt.Errorf("create request failed: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request to Slack failed: %w", err)
-	}
-	defer resp.Body.Close()
-	var result struct {
-		OK      bool `json:"ok"`
-		Members []struct {
-			ID      string `json:"id"`
-			IsBot   bool   `json:"is_bot"`
-			Name    string `json:"name"`
-			Deleted bool   `json:"deleted"`
-		} `json:"members"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decode response failed: %w", err)
-	}
-	if !result.OK {
-		return nil, fmt.Errorf("slack api returned not OK")
+		return nil, fmt.Errorf("list user group members failed: %w", err)
 	}
-
-	var userIDs []string
-	for _, member := range result.Members {
-		if !member.IsBot && !member.Deleted && member.Name != "slackbot" {
-			userIDs = append(userIDs, member.ID)
-		}
-	}
-	return userIDs, nil
+	return users, nil
 }
-
-// func postToStandUpsChannel(teamID, userID, message string) {
-// 	team, err := db.GetTeamConfig(teamID)
-// 	if err != nil {
-// 		log.Printf("postToStandUpsChannel: failed to get team config for %s: %v", teamID, err)
-// 		return
-// 	}
-
-// 	if err := db.SaveUserMessage(teamID, userID, message); err != nil {
-// 		log.Printf("postToStandUpsChannel: failed to save user message: %v", err)
-// 	} else {
-// 		log.Printf("User message saved for team %s, user %s", teamID, userID)
-// 	}
-
-// 	payload := map[string]string{
-// 		"channel": team.ChannelID,
-// 		"text":    fmt.Sprintf("Update from <@%s>:\n%s", userID, message),
-// 	}
-
-// 	body, err := json.Marshal(payload)
-// 	if err != nil {
-// 		log.Printf("postToStandUpsChannel: failed to marshal payload: %v", err)
-// 		return
-// 	}
-
-// 	req, err := http.NewRequest("POST", slackPostMessagesURL, bytes.NewBuffer(body))
-// 	if err != nil {
-// 		log.Printf("postToStandUpsChannel: failed to create request: %v", err)
-// 		return
-// 	}
-// 	req.Header.Set("Content-Type", "application/json")
-// 	req.Header.Set("Authorization", "Bearer "+team.AccessToken)
-
-// 	resp, err := http.DefaultClient.Do(req)
-// 	if err != nil {
-// 		log.Printf("postToStandUpsChannel: failed to send request: %v", err)
-// 		return
-// 	}
-// 	defer resp.Body.Close()
-
-// 	if resp.StatusCode != http.StatusOK {
-// 		log.Printf("postToStandUpsChannel: Slack API responded with status %s", resp.Status)
-// 	}
-// }
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// EligibleUsers is the set of a team's users that pass its user filters.
+type EligibleUsers map[string]bool
+
+// GetEligibleUsers pages through the team's users.list. Fetch it once per
+// team and share it between the team's synced standups.
+func GetEligibleUsers(ctx context.Context, team *db.TeamConfig) (EligibleUsers, error) {
+	userIDs, err := getAllTeamUsers(ctx, team)
+	if err != nil {
+		return nil, fmt.Errorf("GetEligibleUsers: %w", err)
+	}
+	eligible := make(EligibleUsers, len(userIDs))
+	for _, userID := range userIDs {
+		eligible[userID] = true
+	}
+	return eligible, nil
+}
+
+// SyncStandupParticipants replaces a synced standup's participants with the
+// members of its channel or user group that are eligible.
+func SyncStandupParticipants(ctx context.Context, team *db.TeamConfig, standup *db.Standup, eligible EligibleUsers) (added, removed int, err error) {
+	var members []string
+	switch standup.SyncSource {
+	case db.SyncSourceChannel:
+		members, err = getChannelMembers(ctx, team.AccessToken, standup.SyncID)
+	case db.SyncSourceUserGroup:
+		members, err = getUserGroupMembers(ctx, team.AccessToken, standup.SyncID)
+	default:
+		return 0, 0, fmt.Errorf("SyncStandupParticipants: standup %d is not synced", standup.ID)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("SyncStandupParticipants: failed to fetch %s %s: %w", standup.SyncSource, standup.SyncID, err)
+	}
+
+	var participants []string
+	for _, userID := range members {
+		if eligible[userID] {
+			participants = append(participants, userID)
+		}
+	}
+
+	return stores.Participants.SyncPromptUsers(team.TeamID, standup.ID, participants)
+}
+
+// handleMembershipEvent applies channel and user group membership changes to
+// the standups that follow them.
+func handleMembershipEvent(ctx context.Context, event SlackEvent, team *db.TeamConfig) {
+	data := event.Event
+
+	var source, syncID string
+	var added, removed []string
+	switch data.Type {
+	case "member_joined_channel":
+		source, syncID, added = db.SyncSourceChannel, data.Channel, []string{data.User}
+	case "member_left_channel":
+		source, syncID, removed = db.SyncSourceChannel, data.Channel, []string{data.User}
+	case "subteam_members_changed":
+		source, syncID, added, removed = db.SyncSourceUserGroup, data.SubteamID, data.AddedUsers, data.RemovedUsers
+	default:
+		return
+	}
+
+	standups, err := stores.Standups.GetStandupsSyncedTo(team.TeamID, source, syncID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to find standups synced to membership source", "source", source, "sync_id", syncID, "error", err)
+		return
+	}
+	if len(standups) == 0 {
+		return
+	}
+
+	var eligible []string
+	for _, userID := range added {
+		if userID == team.BotUserID {
+			continue
+		}
+		user, err := getUserInfo(ctx, team.AccessToken, userID)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to look up new member", "user_id", userID, "error", err)
+			continue
+		}
+		if includeUser(team, *user) {
+			eligible = append(eligible, userID)
+		}
+	}
+
+	for _, standup := range standups {
+		for _, userID := range eligible {
+			if err := stores.Participants.AddPromptUser(team.TeamID, standup.ID, userID); err != nil {
+				slog.ErrorContext(ctx, "Failed to add participant", "standup", standup.Name, "user_id", userID, "error", err)
+			}
+		}
+		for _, userID := range removed {
+			if err := stores.Participants.RemovePromptUser(standup.ID, userID); err != nil {
+				slog.ErrorContext(ctx, "Failed to remove participant", "standup", standup.Name, "user_id", userID, "error", err)
+			}
+		}
+		slog.InfoContext(ctx, "Synced standup participants", "standup", standup.Name, "event_type", data.Type, "added", len(eligible), "removed", len(removed))
+	}
+}
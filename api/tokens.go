@@ -0,0 +1,158 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"MidayBrief/logging"
+	"MidayBrief/slack"
+	"MidayBrief/utils"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// tokenRefreshMargin is how long before expiry a rotating token is
+	// refreshed.
+	tokenRefreshMargin  = 1 * time.Hour
+	tokenRefreshLockTTL = 30 * time.Second
+	tokenRefreshWait    = 500 * time.Millisecond
+)
+
+// tokenNeedsRefresh reports whether the team uses token rotation and its
+// access token expires within the refresh margin.
+func tokenNeedsRefresh(team *db.TeamConfig) bool {
+	return team.RefreshToken != "" && team.TokenExpiresAt != nil &&
+		time.Until(*team.TokenExpiresAt) < tokenRefreshMargin
+}
+
+// EnsureFreshToken refreshes the team's access token in place if it is
+// about to expire. Teams without token rotation are left untouched.
+func EnsureFreshToken(ctx context.Context, team *db.TeamConfig) error {
+	if !tokenNeedsRefresh(team) {
+		return nil
+	}
+	return RefreshTeamToken(ctx, team)
+}
+
+// RefreshTeamToken exchanges the team's refresh token for a new token pair
+// and updates team in place. A Redis lock ensures only one caller refreshes
+// at a time; the others wait for it and reload the stored tokens, because
+// Slack invalidates a refresh token once it has been used.
+func RefreshTeamToken(ctx context.Context, team *db.TeamConfig) error {
+	if team.RefreshToken == "" {
+		return fmt.Errorf("RefreshTeamToken: team %s has no refresh token", team.TeamID)
+	}
+
+	// Workspaces under an org-wide install share the org's token, so lock
+	// and store by the record the token belongs to.
+	lockKey := "token_refresh:" + team.TokenTeamID
+	for {
+		lockToken, err := stores.State.AcquireLock(lockKey, tokenRefreshLockTTL, ctx)
+		if err != nil {
+			return fmt.Errorf("RefreshTeamToken: failed to acquire lock for team %s: %w", team.TeamID, err)
+		}
+		if lockToken != "" {
+			defer stores.State.ReleaseLock(lockKey, lockToken, context.Background())
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("RefreshTeamToken: waiting for refresh of team %s: %w", team.TeamID, ctx.Err())
+		case <-time.After(tokenRefreshWait):
+		}
+
+		if refreshedByOther(team) {
+			return nil
+		}
+	}
+
+	// Someone may have finished refreshing between our last check and
+	// taking the lock.
+	if refreshedByOther(team) {
+		return nil
+	}
+
+	resp, err := slackClient.OAuthV2Refresh(ctx, cfg.Slack.ClientID, cfg.Slack.ClientSecret, team.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("RefreshTeamToken: team %s: %w", team.TeamID, err)
+	}
+
+	encryptedAccess, err := utils.EncryptForTeam(team.TokenTeamID, resp.AccessToken)
+	if err != nil {
+		return fmt.Errorf("RefreshTeamToken: failed to encrypt access token: %w", err)
+	}
+	encryptedRefresh, err := utils.EncryptForTeam(team.TokenTeamID, resp.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("RefreshTeamToken: failed to encrypt refresh token: %w", err)
+	}
+	expiresAt := tokenExpiry(resp.ExpiresIn)
+	if err := stores.Teams.UpdateTeamTokens(team.TokenTeamID, encryptedAccess, encryptedRefresh, expiresAt); err != nil {
+		return fmt.Errorf("RefreshTeamToken: %w", err)
+	}
+
+	team.AccessToken, team.RefreshToken, team.TokenExpiresAt = resp.AccessToken, resp.RefreshToken, expiresAt
+	slog.InfoContext(ctx, "Refreshed Slack token")
+	return nil
+}
+
+// refreshedByOther reloads the team and, if its stored token is no longer
+// due for refresh, copies the new tokens into team.
+func refreshedByOther(team *db.TeamConfig) bool {
+	stored, err := stores.Teams.GetTeamConfig(team.TeamID)
+	if err != nil || stored.AccessToken == team.AccessToken || tokenNeedsRefresh(stored) {
+		return false
+	}
+	team.AccessToken, team.RefreshToken, team.TokenExpiresAt = stored.AccessToken, stored.RefreshToken, stored.TokenExpiresAt
+	return true
+}
+
+func tokenExpiry(expiresIn int) *time.Time {
+	if expiresIn <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().UTC().Add(time.Duration(expiresIn) * time.Second)
+	return &expiresAt
+}
+
+// SendTeamMessage posts a message with the team's bot token, refreshing the
+// token first if it is about to expire and once more if Slack reports it
+// expired.
+func SendTeamMessage(ctx context.Context, team *db.TeamConfig, channel, text string) error {
+	if err := EnsureFreshToken(ctx, team); err != nil {
+		slog.WarnContext(ctx, "Failed to refresh token before sending", "error", err)
+	}
+
+	err := SendMessage(ctx, team.AccessToken, channel, text)
+	if !slack.IsTokenExpired(err) || team.RefreshToken == "" {
+		return err
+	}
+
+	if err := RefreshTeamToken(ctx, team); err != nil {
+		return err
+	}
+	return SendMessage(ctx, team.AccessToken, channel, text)
+}
+
+// RefreshExpiringTokens refreshes every rotating token that expires within
+// the refresh margin.
+func RefreshExpiringTokens(ctx context.Context) {
+	teams, err := stores.Teams.GetTeamsWithTokensExpiringBefore(time.Now().UTC().Add(tokenRefreshMargin))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to find expiring tokens", "error", err)
+		return
+	}
+
+	for _, t := range teams {
+		teamCtx := logging.WithTeam(ctx, t.TeamID)
+		team, err := stores.Teams.GetTeamConfig(t.TeamID)
+		if err != nil {
+			slog.ErrorContext(teamCtx, "Failed to load team to refresh its token", "error", err)
+			continue
+		}
+		if err := EnsureFreshToken(teamCtx, team); err != nil {
+			slog.ErrorContext(teamCtx, "Failed to refresh token", "error", err)
+		}
+	}
+}
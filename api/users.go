@@ -0,0 +1,157 @@
+package api
+
+import (
+	"MidayBrief/db"
+	"MidayBrief/slack"
+	"MidayBrief/utils"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const usersListPageSize = 200
+
+var userFilterPattern = regexp.MustCompile(`(?i)^\s*(include|exclude)\s+(guests|bots|apps)\s*$`)
+
+var userFilterColumns = map[string]string{
+	"guests": "include_guests",
+	"bots":   "include_bots",
+	"apps":   "include_app_users",
+}
+
+var userFilterLabels = map[string]string{
+	"guests": "Guests",
+	"bots":   "Bots",
+	"apps":   "App users",
+}
+
+// includeUser reports whether the account should be treated as a standup
+// participant under the team's filters. Deactivated users and Slackbot are
+// never included.
+func includeUser(team *db.TeamConfig, u slack.User) bool {
+	if u.Deleted || u.ID == "USLACKBOT" || u.Name == "slackbot" || u.ID == team.BotUserID {
+		return false
+	}
+	if u.IsBot && !team.IncludeBots {
+		return false
+	}
+	if u.IsAppUser && !team.IncludeAppUsers {
+		return false
+	}
+	if (u.IsRestricted || u.IsUltraRestricted) && !team.IncludeGuests {
+		return false
+	}
+	return true
+}
+
+func profileFromUser(u slack.User) utils.UserProfile {
+	name := u.Profile.DisplayName
+	if name == "" {
+		name = u.Profile.RealName
+	}
+	if name == "" {
+		name = u.Name
+	}
+	return utils.UserProfile{
+		ID:          u.ID,
+		DisplayName: name,
+		TZ:          u.TZ,
+		AvatarURL:   u.Profile.Image72,
+	}
+}
+
+func getUserInfo(ctx context.Context, accessToken, userID string) (*slack.User, error) {
+	return slackClient.UsersInfo(ctx, accessToken, userID)
+}
+
+// GetUserProfile returns the user's cached profile, fetching and caching it
+// from Slack on a miss.
+func GetUserProfile(ctx context.Context, team *db.TeamConfig, userID string) (*utils.UserProfile, error) {
+	if profile, err := stores.State.GetCachedUserProfile(team.TeamID, userID, ctx); err == nil {
+		return profile, nil
+	}
+
+	user, err := getUserInfo(ctx, team.AccessToken, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetUserProfile: %w", err)
+	}
+	profile := profileFromUser(*user)
+	if err := stores.State.CacheUserProfiles(team.TeamID, []utils.UserProfile{profile}, ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to cache user profile", "user_id", userID, "error", err)
+	}
+	return &profile, nil
+}
+
+// GetUserProfiles looks up several profiles, leaving out users that can't
+// be resolved.
+func GetUserProfiles(ctx context.Context, team *db.TeamConfig, userIDs []string) map[string]*utils.UserProfile {
+	profiles := make(map[string]*utils.UserProfile, len(userIDs))
+	for _, userID := range userIDs {
+		profile, err := GetUserProfile(ctx, team, userID)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to load user profile", "user_id", userID, "error", err)
+			continue
+		}
+		profiles[userID] = profile
+	}
+	return profiles
+}
+
+func getUserTimeZone(ctx context.Context, team *db.TeamConfig, userID string) (string, error) {
+	profile, err := GetUserProfile(ctx, team, userID)
+	if err != nil {
+		return "", fmt.Errorf("could not get timezone: %w", err)
+	}
+	return profile.TZ, nil
+}
+
+// getAllTeamUsers pages through users.list, caching every profile it sees,
+// and returns the IDs of the users the team's filters include.
+func getAllTeamUsers(ctx context.Context, team *db.TeamConfig) ([]string, error) {
+	var userIDs []string
+	cursor := ""
+	for {
+		resp, err := slackClient.UsersList(ctx, team.AccessToken, cursor, usersListPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("list users failed: %w", err)
+		}
+
+		profiles := make([]utils.UserProfile, 0, len(resp.Members))
+		for _, member := range resp.Members {
+			profiles = append(profiles, profileFromUser(member))
+			if includeUser(team, member) {
+				userIDs = append(userIDs, member.ID)
+			}
+		}
+		if err := stores.State.CacheUserProfiles(team.TeamID, profiles, ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to cache user profiles", "error", err)
+		}
+
+		if cursor = resp.ResponseMetadata.NextCursor; cursor == "" {
+			return userIDs, nil
+		}
+	}
+}
+
+// handleUserFilterCommand handles `include|exclude guests|bots|apps`. It
+// reports false when text isn't one of them.
+func handleUserFilterCommand(ctx context.Context, team *db.TeamConfig, text string) (string, bool) {
+	matches := userFilterPattern.FindStringSubmatch(text)
+	if matches == nil {
+		return "", false
+	}
+
+	include := strings.EqualFold(matches[1], "include")
+	kind := strings.ToLower(matches[2])
+	if err := stores.Teams.UpdateUserFilter(team.TeamID, userFilterColumns[kind], include); err != nil {
+		slog.ErrorContext(ctx, "Failed to update user filter", "filter", kind, "error", err)
+		return fmt.Sprintf("Failed to update the %s filter.", kind), true
+	}
+
+	if include {
+		return fmt.Sprintf("✅ %s will now be included by `add all users` and participant sync.", userFilterLabels[kind]), true
+	}
+	return fmt.Sprintf("✅ %s will now be left out by `add all users` and participant sync.", userFilterLabels[kind]), true
+}
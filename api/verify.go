@@ -0,0 +1,43 @@
+package api
+
+import (
+	"MidayBrief/slack"
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// VerifySlackSignature rejects requests that aren't signed with
+// SLACK_SIGNING_SECRET, and every request while it isn't set unless
+// SLACK_SKIP_VERIFICATION is.
+func VerifySlackSignature(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := cfg.Slack.SigningSecret
+		if secret == "" {
+			if cfg.Slack.SkipVerification {
+				next.ServeHTTP(w, r)
+				return
+			}
+			slog.WarnContext(r.Context(), "Rejected Slack request: SLACK_SIGNING_SECRET is not set")
+			http.Error(w, "Request verification is not configured", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Unable to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := slack.VerifySignature(secret, r.Header, body, time.Now()); err != nil {
+			slog.WarnContext(r.Context(), "Rejected Slack request", "error", err)
+			http.Error(w, "Invalid request signature", http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
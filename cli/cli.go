@@ -0,0 +1,155 @@
+// Package cli implements the midaybrief operator commands. They use the
+// same configuration, storage and jobs as the server, so they act on a
+// deployment exactly as it would.
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"MidayBrief/api"
+	"MidayBrief/config"
+	"MidayBrief/db"
+	"MidayBrief/logging"
+	"MidayBrief/scheduler"
+	"MidayBrief/store"
+	"MidayBrief/tracing"
+	"MidayBrief/utils"
+)
+
+const usage = `usage: midaybrief <command> [arguments]
+
+Commands:
+  teams list                            list installed teams
+  teams show <team-id>                  show a team's configuration
+  prompt [-standup name] <team-id>      send a standup's prompts now
+  summary [-standup name] [-date YYYY-MM-DD] <team-id>
+                                        post a standup's summary now, or
+                                        post a past day's again
+  rerun [-since YYYY-MM-DD] [-dry-run]  post the summaries that failed
+  keys rotate                           re-encrypt all data with the
+                                        primary keys
+  user export <team-id> <user-id>       print a user's data as JSON
+  user delete -yes <team-id> <user-id>  delete a user's data
+  migrate [up | down [steps] | status]  apply or revert schema migrations
+  config check                          validate the configuration
+
+Commands read the same environment as the server.`
+
+// usageError is returned for bad arguments; it holds the usage to print.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+type command func(ctx context.Context, cfg *config.Config, args []string) error
+
+var commands = map[string]command{
+	"teams":   runTeams,
+	"prompt":  runPrompt,
+	"summary": runSummary,
+	"rerun":   runRerun,
+	"keys":    runKeys,
+	"user":    runUser,
+	"migrate": runMigrate,
+}
+
+// tracingFlushTimeout bounds exporting the spans still buffered on exit.
+const tracingFlushTimeout = 5 * time.Second
+
+// Run runs the command named by args[0] and returns the process exit code:
+// 2 for bad arguments, 1 if the command failed.
+func Run(args []string) int {
+	switch {
+	case len(args) == 0:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	case args[0] == "help" || args[0] == "-h" || args[0] == "--help":
+		fmt.Println(usage)
+		return 0
+	}
+
+	// config check reports configuration errors itself, so it runs before
+	// the configuration is loaded.
+	if args[0] == "config" {
+		return exitCode(runConfig(args[1:]))
+	}
+
+	run, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "midaybrief: unknown command %q\n\n%s\n", args[0], usage)
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return exitCode(fmt.Errorf("invalid configuration; run `midaybrief config check` for details:%s", bulleted(err)))
+	}
+	logging.Init(cfg.LogLevel, cfg.LogFormat)
+
+	// Tracing is a no-op unless OTEL_EXPORTER_OTLP_ENDPOINT is set.
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		return exitCode(fmt.Errorf("failed to set up tracing: %w", err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("Failed to flush traces", "error", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return exitCode(run(ctx, cfg, args[1:]))
+}
+
+func exitCode(err error) int {
+	var usage usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &usage):
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	default:
+		fmt.Fprintln(os.Stderr, "midaybrief:", err)
+		return 1
+	}
+}
+
+// stores holds the deployment's data once connect has run.
+var stores *store.Stores
+
+// connect validates the configuration and connects to Postgres and Redis
+// the way the server does at startup.
+func connect(cfg *config.Config) error {
+	if cfg.Storage == config.StorageMemory {
+		return errors.New("STORAGE=memory keeps no data between runs; point DATABASE_URL and REDIS_URL at the deployment's")
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration; run `midaybrief config check` for details:%s", bulleted(err))
+	}
+	if err := utils.InitCrypto(cfg.Crypto); err != nil {
+		return fmt.Errorf("invalid encryption keys: %w", err)
+	}
+	db.SetRetentionPeriod(cfg.DataRetention)
+	db.SetHistoryRetention(cfg.HistoryRetention)
+	api.SetConfig(cfg)
+
+	db.Init(cfg.Database)
+	utils.InitRedis(cfg.RedisURL)
+	stores = store.Default()
+	api.SetStores(stores)
+	scheduler.SetStores(stores)
+	utils.SetDataKeyStore(stores.Crypto)
+	return nil
+}
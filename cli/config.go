@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"MidayBrief/config"
+	"MidayBrief/utils"
+)
+
+const configUsage = "usage: midaybrief config check"
+
+// runConfig implements `midaybrief config check`, which loads and
+// validates the configuration the way the server would at startup, prints
+// it with secrets masked and fails if anything is wrong.
+func runConfig(args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return usageError(configUsage)
+	}
+
+	cfg, err := config.Load()
+	if cfg != nil {
+		printConfig(cfg)
+		fmt.Println()
+	}
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err == nil {
+		err = utils.InitCrypto(cfg.Crypto)
+	}
+	if err != nil {
+		return fmt.Errorf("configuration is invalid:%s", bulleted(err))
+	}
+	fmt.Println("Configuration is valid.")
+	return nil
+}
+
+// bulleted lists the errors joined in err, one per line.
+func bulleted(err error) string {
+	return "\n  - " + strings.ReplaceAll(err.Error(), "\n", "\n  - ")
+}
+
+func printConfig(cfg *config.Config) {
+	secret := func(value string) string {
+		if value == "" {
+			return "(not set)"
+		}
+		return "(set)"
+	}
+	plain := func(value string) string {
+		if value == "" {
+			return "(not set)"
+		}
+		return value
+	}
+
+	rows := [][2]string{
+		{"PORT", cfg.Port},
+		{"BASE_URL", plain(cfg.BaseURL)},
+		{"STORAGE", cfg.Storage},
+		{"DATABASE_URL", secret(cfg.Database.URL)},
+		{"MIGRATE_ON_START", fmt.Sprint(cfg.Database.MigrateOnStart)},
+		{"REDIS_URL", secret(cfg.RedisURL)},
+		{"SLACK_CLIENT_ID", plain(cfg.Slack.ClientID)},
+		{"SLACK_CLIENT_SECRET", secret(cfg.Slack.ClientSecret)},
+		{"SLACK_SIGNING_SECRET", secret(cfg.Slack.SigningSecret)},
+		{"SLACK_API_URL", plain(cfg.Slack.APIURL)},
+		{"SLACK_SKIP_VERIFICATION", fmt.Sprint(cfg.Slack.SkipVerification)},
+		{"ENCRYPTION_KEYS", secret(cfg.Crypto.Keys)},
+		{"ENCRYPTION_KEY_ID", plain(cfg.Crypto.KeyID)},
+		{"ENCRYPTION_KEY", secret(cfg.Crypto.LegacyKey)},
+		{"HASH_KEYS", secret(cfg.Crypto.HashKeys)},
+		{"HASH_KEY_ID", plain(cfg.Crypto.HashKeyID)},
+		{"KMS_PROVIDER", plain(cfg.Crypto.KMSProvider)},
+		{"KMS_KEY_ID", plain(cfg.Crypto.KMSKeyID)},
+		{"VAULT_ADDR", plain(cfg.Crypto.VaultAddr)},
+		{"VAULT_TOKEN", secret(cfg.Crypto.VaultToken)},
+		{"DATA_RETENTION_DAYS", fmt.Sprint(int(cfg.DataRetention.Hours() / 24))},
+		{"HISTORY_RETENTION_DAYS", fmt.Sprint(int(cfg.HistoryRetention.Hours() / 24))},
+		{"METRICS_MAX_TEAMS", fmt.Sprint(cfg.MetricsMaxTeams)},
+		{"LOG_LEVEL", cfg.LogLevel.String()},
+		{"LOG_FORMAT", cfg.LogFormat},
+	}
+	for _, row := range rows {
+		fmt.Printf("%-22s %s\n", row[0], row[1])
+	}
+}
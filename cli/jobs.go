@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"MidayBrief/config"
+	"MidayBrief/db"
+	"MidayBrief/scheduler"
+
+	"gorm.io/gorm"
+)
+
+const (
+	promptUsage  = "usage: midaybrief prompt [-standup name] <team-id>"
+	summaryUsage = "usage: midaybrief summary [-standup name] [-date YYYY-MM-DD] <team-id>"
+	rerunUsage   = "usage: midaybrief rerun [-since YYYY-MM-DD] [-dry-run]"
+)
+
+// rerunWindow is how far back rerun looks for failed summaries by default.
+const rerunWindow = 7 * 24 * time.Hour
+
+// newFlagSet returns a flag set that leaves reporting bad arguments to
+// exitCode.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+// runPrompt implements `midaybrief prompt`, which sends a standup's first
+// question to its participants as its prompt time would.
+func runPrompt(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := newFlagSet("prompt")
+	standupName := flags.String("standup", db.DefaultStandupName, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return usageError(promptUsage)
+	}
+	if err := connect(cfg); err != nil {
+		return err
+	}
+
+	team, standup, err := loadStandup(flags.Arg(0), *standupName)
+	if err != nil {
+		return err
+	}
+	prompted, err := scheduler.TriggerPrompt(ctx, *team, *standup)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Prompted %d participant(s) of standup %s.\n", prompted, standup.Name)
+	return nil
+}
+
+// runSummary implements `midaybrief summary`, which posts a standup's
+// summary as its post time would, or posts a past day's again.
+func runSummary(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := newFlagSet("summary")
+	standupName := flags.String("standup", db.DefaultStandupName, "")
+	date := flags.String("date", "", "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return usageError(summaryUsage)
+	}
+	if *date != "" {
+		if _, err := time.Parse(time.DateOnly, *date); err != nil {
+			return usageError(summaryUsage)
+		}
+	}
+	if err := connect(cfg); err != nil {
+		return err
+	}
+
+	team, standup, err := loadStandup(flags.Arg(0), *standupName)
+	if err != nil {
+		return err
+	}
+	location, err := time.LoadLocation(standup.Timezone)
+	if err != nil {
+		return fmt.Errorf("standup %s has an invalid timezone %q", standup.Name, standup.Timezone)
+	}
+	today := time.Now().In(location).Format(time.DateOnly)
+	if *date == "" {
+		*date = today
+	}
+	if *date > today {
+		return fmt.Errorf("%s is after today in %s", *date, standup.Timezone)
+	}
+
+	run, err := scheduler.PostSummary(ctx, *team, *standup, *date)
+	if err != nil {
+		return err
+	}
+	printRun(run, standup.Name)
+	return nil
+}
+
+// runRerun implements `midaybrief rerun`, which posts again the summaries
+// that failed since a date.
+func runRerun(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := newFlagSet("rerun")
+	since := flags.String("since", time.Now().UTC().Add(-rerunWindow).Format(time.DateOnly), "")
+	dryRun := flags.Bool("dry-run", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return usageError(rerunUsage)
+	}
+	if _, err := time.Parse(time.DateOnly, *since); err != nil {
+		return usageError(rerunUsage)
+	}
+	if err := connect(cfg); err != nil {
+		return err
+	}
+
+	runs, err := stores.Submissions.GetFailedStandupRuns(*since)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Printf("No summaries failed since %s.\n", *since)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TEAM\tSTANDUP\tDATE\tERROR\tRESULT")
+	var failed int
+	for _, run := range runs {
+		standup, result, err := rerun(ctx, run, *dryRun)
+		if err != nil {
+			result = "failed: " + err.Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", run.TeamID, standup, run.Date, run.Error, result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d summaries failed again", failed, len(runs))
+	}
+	return nil
+}
+
+// rerun posts the failed run's summary again, unless its team is gone or
+// dryRun is set, and returns the standup's name and the outcome.
+func rerun(ctx context.Context, run db.StandupRun, dryRun bool) (standupName, result string, err error) {
+	standup, err := stores.Standups.GetStandup(run.StandupID)
+	if err != nil {
+		return fmt.Sprint(run.StandupID), "", err
+	}
+	team, err := stores.Teams.GetTeamConfig(run.TeamID)
+	if err != nil {
+		return standup.Name, "", err
+	}
+	if !team.IsActive {
+		return standup.Name, "skipped: team uninstalled", nil
+	}
+	if dryRun {
+		return standup.Name, "would post", nil
+	}
+
+	posted, err := scheduler.PostSummary(ctx, *team, *standup, run.Date)
+	if err != nil {
+		return standup.Name, "", err
+	}
+	return standup.Name, fmt.Sprintf("%s, %d of %d", posted.Status, posted.Submitted, posted.Prompted), nil
+}
+
+// loadStandup returns the active team and its standup named name.
+func loadStandup(teamID, name string) (*db.TeamConfig, *db.Standup, error) {
+	team, err := stores.Teams.GetTeamConfig(teamID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("no team %s", teamID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !team.IsActive {
+		return nil, nil, fmt.Errorf("team %s is uninstalled", teamID)
+	}
+
+	standup, err := stores.Standups.GetStandupByName(team.TeamID, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("team %s has no standup %q", teamID, name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return team, standup, nil
+}
+
+func printRun(run db.StandupRun, standupName string) {
+	switch run.Status {
+	case db.RunStatusPosted:
+		fmt.Printf("Posted the %s summary of standup %s: updates from %d of %d participant(s).\n", run.Date, standupName, run.Submitted, run.Prompted)
+	case db.RunStatusEmpty:
+		fmt.Printf("Nothing to post for standup %s on %s: no updates.\n", standupName, run.Date)
+	}
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"MidayBrief/config"
+)
+
+const keysUsage = "usage: midaybrief keys rotate"
+
+// reencryptBatchSize bounds how many rows keys rotate loads at once.
+const reencryptBatchSize = 500
+
+// runKeys implements `midaybrief keys rotate`. Once a new key is added to
+// ENCRYPTION_KEYS or HASH_KEYS and made primary on every server, it moves
+// everything still using an older key to it straight away, rather than
+// waiting for the servers' hourly pass, so the older key can be retired.
+func runKeys(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "rotate" {
+		return usageError(keysUsage)
+	}
+	if err := connect(cfg); err != nil {
+		return err
+	}
+
+	result, err := stores.Crypto.ReencryptStaleCiphertexts(reencryptBatchSize)
+	fmt.Printf("Re-wrapped %d data key(s), re-encrypted %d value(s), rehashed %d message(s).\n",
+		result.Rewrapped, result.Reencrypted, result.Rehashed)
+	if err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("%d value(s) couldn't be decrypted and still use an older key; see the log before retiring any key", result.Failed)
+	}
+	fmt.Println("Nothing uses an older key any more.")
+	return nil
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"MidayBrief/config"
+	"MidayBrief/db"
+)
+
+const migrateUsage = "usage: midaybrief migrate [up | down [steps] | status]"
+
+// runMigrate implements `midaybrief migrate`.
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	steps := 1
+	switch {
+	case command == "down" && len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return usageError(migrateUsage)
+		}
+		steps = n
+	case len(args) > 1 || (command != "up" && command != "down" && command != "status"):
+		return usageError(migrateUsage)
+	}
+
+	db.Connect(cfg.Database.URL)
+
+	switch command {
+	case "up":
+		applied, err := db.MigrateUp()
+		if err != nil {
+			return fmt.Errorf("migration failed after applying %d: %w", applied, err)
+		}
+		slog.Info("Applied migrations", "applied", applied, "version", db.LatestSchemaVersion())
+
+	case "down":
+		reverted, err := db.MigrateDown(steps)
+		if err != nil {
+			return fmt.Errorf("rollback failed after reverting %d: %w", reverted, err)
+		}
+		slog.Info("Reverted migrations", "reverted", reverted)
+
+	case "status":
+		status, err := db.GetMigrationStatus()
+		if err != nil {
+			return fmt.Errorf("failed to read migration status: %w", err)
+		}
+		for _, m := range status {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(os.Stdout, "%4d  %-28s %s\n", m.Version, m.Name, applied)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"MidayBrief/config"
+	"MidayBrief/db"
+)
+
+const teamsUsage = "usage: midaybrief teams list\n       midaybrief teams show <team-id>"
+
+// auditEventsShown is how many of a team's latest audit events teams show
+// prints.
+const auditEventsShown = 10
+
+// runTeams implements `midaybrief teams`.
+func runTeams(ctx context.Context, cfg *config.Config, args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		if err := connect(cfg); err != nil {
+			return err
+		}
+		return listTeams()
+	case len(args) == 2 && args[0] == "show":
+		if err := connect(cfg); err != nil {
+			return err
+		}
+		return showTeam(args[1])
+	default:
+		return usageError(teamsUsage)
+	}
+}
+
+func listTeams() error {
+	teams, err := stores.Teams.GetAllTeamConfigs()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TEAM\tENTERPRISE\tSTATUS\tSTANDUPS\tINSTALLED\tTOKEN")
+	for _, team := range teams {
+		standups, err := stores.Standups.GetStandupsForTeam(team.TeamID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", team.TeamID, orDash(team.EnterpriseID), teamStatus(team),
+			len(standups), team.CreatedAt.Format(time.DateOnly), tokenStatus(team))
+	}
+	return w.Flush()
+}
+
+func showTeam(teamID string) error {
+	team, err := findTeam(teamID)
+	if err != nil {
+		return err
+	}
+	admins, err := stores.Teams.GetTeamAdmins(team.TeamID)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	enterprise := orDash(team.EnterpriseID)
+	if team.IsEnterpriseInstall {
+		enterprise = "org-wide install"
+	}
+	fmt.Fprintf(w, "Team\t%s\n", team.TeamID)
+	fmt.Fprintf(w, "Enterprise\t%s\n", enterprise)
+	fmt.Fprintf(w, "Status\t%s\n", teamStatus(*team))
+	fmt.Fprintf(w, "Installed\t%s by %s\n", team.CreatedAt.Format(time.RFC3339), orDash(team.InstallerUserID))
+	fmt.Fprintf(w, "Timezone\t%s\n", orDash(team.Timezone))
+	fmt.Fprintf(w, "Token\t%s\n", tokenStatus(*team))
+	fmt.Fprintf(w, "Bot user\t%s\n", orDash(team.BotUserID))
+	fmt.Fprintf(w, "Includes\tguests %s, bots %s, app users %s\n", yesNo(team.IncludeGuests), yesNo(team.IncludeBots), yesNo(team.IncludeAppUsers))
+	fmt.Fprintf(w, "Admins\t%s\n", orDash(strings.Join(admins, ", ")))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	standups, err := stores.Standups.GetStandupsForTeam(team.TeamID)
+	if err != nil {
+		return err
+	}
+	for _, standup := range standups {
+		if err := showStandup(standup); err != nil {
+			return err
+		}
+	}
+
+	keys, err := stores.APIKeys.GetAPIKeys(team.TeamID)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		fmt.Println("\nAPI keys")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PREFIX\tNAME\tSCOPES\tCREATED BY\tLAST USED\tREVOKED")
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", key.Prefix, key.Name, key.Scopes, orDash(key.CreatedBy),
+				formatTimePtr(key.LastUsedAt), formatTimePtr(key.RevokedAt))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	events, err := stores.Teams.GetAuditEvents(team.TeamID, auditEventsShown)
+	if err != nil {
+		return err
+	}
+	if len(events) > 0 {
+		fmt.Println("\nRecent audit events")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tACTOR\tACTION\tDETAIL")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", event.CreatedAt.Format(time.RFC3339), orDash(event.ActorUserID), event.Action, event.Detail)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+func showStandup(standup db.Standup) error {
+	participants, err := stores.Participants.GetAllPromptUser(standup.ID)
+	if err != nil {
+		return err
+	}
+	runs, err := stores.Submissions.GetStandupRuns(standup.ID, "", "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nStandup %s\n", standup.Name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  Channel\t%s\n", orDash(standup.ChannelID))
+	schedule := "not scheduled"
+	if standup.PromptTime != "" && standup.PostTime != "" {
+		schedule = fmt.Sprintf("prompt %s, post %s", standup.PromptTime, standup.PostTime)
+	}
+	fmt.Fprintf(w, "  Schedule\t%s (%s)\n", schedule, orDash(standup.Timezone))
+	for i, question := range standup.QuestionList() {
+		label := ""
+		if i == 0 {
+			label = "Questions"
+		}
+		fmt.Fprintf(w, "  %s\t%d. %s\n", label, i+1, question)
+	}
+	synced := ""
+	if standup.SyncSource != "" {
+		synced = fmt.Sprintf(", following %s %s", standup.SyncSource, standup.SyncID)
+	}
+	fmt.Fprintf(w, "  Participants\t%d%s\n", len(participants), synced)
+	lastRun := "none yet"
+	if len(runs) > 0 {
+		run := runs[len(runs)-1]
+		lastRun = fmt.Sprintf("%s %s, %d of %d", run.Date, run.Status, run.Submitted, run.Prompted)
+		if run.Error != "" {
+			lastRun += ": " + run.Error
+		}
+	}
+	fmt.Fprintf(w, "  Last summary\t%s\n", lastRun)
+	return w.Flush()
+}
+
+// findTeam returns the team even if its tokens can't be decrypted, unlike
+// stores.Teams.GetTeamConfig.
+func findTeam(teamID string) (*db.TeamConfig, error) {
+	teams, err := stores.Teams.GetAllTeamConfigs()
+	if err != nil {
+		return nil, err
+	}
+	for _, team := range teams {
+		if team.TeamID == teamID {
+			return &team, nil
+		}
+	}
+	return nil, fmt.Errorf("no team %s", teamID)
+}
+
+func teamStatus(team db.TeamConfig) string {
+	if team.IsActive {
+		return "active"
+	}
+	if team.DeactivatedAt != nil {
+		return "uninstalled " + team.DeactivatedAt.Format(time.DateOnly)
+	}
+	return "inactive"
+}
+
+func tokenStatus(team db.TeamConfig) string {
+	switch {
+	case team.DecryptErr != nil:
+		return "unreadable"
+	case team.AccessToken == "":
+		return "none"
+	case team.TokenExpiresAt != nil:
+		return "rotating, expires " + team.TokenExpiresAt.Format(time.RFC3339)
+	default:
+		return "ok"
+	}
+}
+
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"MidayBrief/config"
+	"MidayBrief/db"
+)
+
+const userUsage = "usage: midaybrief user export <team-id> <user-id>\n       midaybrief user delete -yes <team-id> <user-id>"
+
+// userExport is everything MidayBrief holds about a user in a team.
+type userExport struct {
+	TeamID      string           `json:"team_id"`
+	UserID      string           `json:"user_id"`
+	ExportedAt  time.Time        `json:"exported_at"`
+	Admin       bool             `json:"admin"`
+	Standups    []string         `json:"standups"`
+	Updates     []exportedUpdate `json:"updates"`
+	InProgress  *exportedPrompt  `json:"in_progress,omitempty"`
+	APIKeys     []exportedAPIKey `json:"api_keys"`
+	AuditEvents []exportedEvent  `json:"audit_events"`
+}
+
+type exportedUpdate struct {
+	Standup     string    `json:"standup"`
+	Text        string    `json:"text"`
+	SentAt      time.Time `json:"sent_at"`
+	SummaryDate string    `json:"summary_date,omitempty"`
+}
+
+type exportedPrompt struct {
+	Standup   string            `json:"standup"`
+	Responses map[string]string `json:"responses"`
+}
+
+type exportedAPIKey struct {
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type exportedEvent struct {
+	Action    string    `json:"action"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// runUser implements `midaybrief user`.
+func runUser(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return usageError(userUsage)
+	}
+	flags := newFlagSet("user " + args[0])
+	yes := flags.Bool("yes", false, "")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 2 {
+		return usageError(userUsage)
+	}
+	teamID, userID := flags.Arg(0), flags.Arg(1)
+
+	switch {
+	case args[0] == "export" && !*yes:
+		if err := connect(cfg); err != nil {
+			return err
+		}
+		return exportUser(ctx, teamID, userID)
+	case args[0] == "delete":
+		if err := connect(cfg); err != nil {
+			return err
+		}
+		return deleteUser(ctx, teamID, userID, *yes)
+	default:
+		return usageError(userUsage)
+	}
+}
+
+// exportUser prints the user's data as JSON.
+func exportUser(ctx context.Context, teamID, userID string) error {
+	team, err := findTeam(teamID)
+	if err != nil {
+		return err
+	}
+	standups, err := stores.Standups.GetStandupsForTeam(team.TeamID)
+	if err != nil {
+		return err
+	}
+	standupNames := make(map[uint]string, len(standups))
+	for _, standup := range standups {
+		standupNames[standup.ID] = standup.Name
+	}
+
+	export := userExport{
+		TeamID:      team.TeamID,
+		UserID:      userID,
+		ExportedAt:  time.Now().UTC(),
+		Admin:       stores.Teams.IsTeamAdmin(team.TeamID, userID),
+		Standups:    []string{},
+		Updates:     []exportedUpdate{},
+		APIKeys:     []exportedAPIKey{},
+		AuditEvents: []exportedEvent{},
+	}
+
+	participating, err := stores.Standups.GetStandupsForUser(team.TeamID, userID)
+	if err != nil {
+		return err
+	}
+	for _, standup := range participating {
+		export.Standups = append(export.Standups, standup.Name)
+	}
+
+	messages, err := stores.Submissions.GetUserMessages(team.TeamID, userID)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		export.Updates = append(export.Updates, exportedUpdate{
+			Standup:     standupNames[msg.StandupID],
+			Text:        msg.Message,
+			SentAt:      msg.Timestamp,
+			SummaryDate: msg.SummaryDate,
+		})
+	}
+
+	if state, err := stores.State.GetPromptState(team.TeamID, userID, ctx); err == nil && state != nil {
+		export.InProgress = &exportedPrompt{Standup: standupNames[state.StandupID], Responses: state.Responses}
+	}
+
+	keys, err := stores.APIKeys.GetAPIKeys(team.TeamID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if key.CreatedBy == userID {
+			export.APIKeys = append(export.APIKeys, exportedAPIKey{Name: key.Name, Prefix: key.Prefix, CreatedAt: key.CreatedAt, RevokedAt: key.RevokedAt})
+		}
+	}
+
+	events, err := stores.Teams.GetUserAuditEvents(team.TeamID, userID)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		export.AuditEvents = append(export.AuditEvents, exportedEvent{Action: event.Action, Detail: event.Detail, CreatedAt: event.CreatedAt})
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+// deleteUser deletes the user's updates, in-progress answers, standup
+// memberships and admin rights in the team. With confirmed unset it only
+// says what would be deleted. The audit trail is kept, and the deletion is
+// added to it.
+func deleteUser(ctx context.Context, teamID, userID string, confirmed bool) error {
+	team, err := findTeam(teamID)
+	if err != nil {
+		return err
+	}
+	messages, err := stores.Submissions.GetUserMessages(team.TeamID, userID)
+	if err != nil {
+		return err
+	}
+	standups, err := stores.Standups.GetStandupsForUser(team.TeamID, userID)
+	if err != nil {
+		return err
+	}
+	admin := stores.Teams.IsTeamAdmin(team.TeamID, userID)
+
+	if !confirmed {
+		fmt.Printf("Would delete %d update(s) by %s, remove them from %d standup(s)", len(messages), userID, len(standups))
+		if admin {
+			fmt.Print(" and revoke their admin rights")
+		}
+		fmt.Println(".")
+		return errors.New("nothing deleted; run again with -yes to delete")
+	}
+
+	// Revoking admin rights fails for the team's last admin, so it goes
+	// first to leave everything in place if it does.
+	if admin {
+		if err := stores.Teams.RemoveTeamAdmin(team.TeamID, userID); err != nil {
+			return fmt.Errorf("nothing deleted: %w", err)
+		}
+	}
+	if err := stores.State.DeletePromptState(team.TeamID, userID, ctx); err != nil {
+		return err
+	}
+	if err := stores.Participants.RemovePromptUserFromTeam(team.TeamID, userID); err != nil {
+		return err
+	}
+	deleted, err := stores.Submissions.DeleteUserMessages(team.TeamID, userID)
+	if err != nil {
+		return err
+	}
+	if err := stores.Teams.RecordAudit(team.TeamID, "", db.AuditActionUserDataDeleted, userID); err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted %d update(s) by %s and removed them from %d standup(s).\n", deleted, userID, len(standups))
+	for _, standup := range standups {
+		if standup.SyncSource != "" {
+			fmt.Printf("Standup %s follows %s %s and will add them back while they're a member.\n", standup.Name, standup.SyncSource, standup.SyncID)
+		}
+	}
+	return nil
+}
@@ -1,29 +1,147 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"MidayBrief/api"
+	"MidayBrief/cli"
+	"MidayBrief/config"
 	"MidayBrief/db"
+	"MidayBrief/logging"
+	"MidayBrief/metrics"
 	"MidayBrief/scheduler"
+	"MidayBrief/store"
+	"MidayBrief/tracing"
 	"MidayBrief/utils"
 )
 
 func main() {
-	db.Init()
-	utils.InitRedis()
-	utils.InitCrypto()
-	go scheduler.StartScheduler()
-	router := SetupRouter()
+	// Operator commands are built into the server too, so they can be run
+	// from its image.
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	logging.Init(cfg.LogLevel, cfg.LogFormat)
+
+	// Tracing is a no-op unless OTEL_EXPORTER_OTLP_ENDPOINT is set.
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("Failed to flush traces", "error", err)
+		}
+	}()
+
+	if err := cfg.Validate(); err != nil {
+		logging.Fatal("Invalid configuration; run `midaybrief config check` for details", "error", err)
+	}
+	if err := utils.InitCrypto(cfg.Crypto); err != nil {
+		logging.Fatal("Invalid encryption keys", "error", err)
+	}
+	db.SetRetentionPeriod(cfg.DataRetention)
+	db.SetHistoryRetention(cfg.HistoryRetention)
+	metrics.SetMaxTeams(cfg.MetricsMaxTeams)
+	api.SetConfig(cfg)
+	if cfg.Slack.SigningSecret == "" {
+		if cfg.Slack.SkipVerification {
+			slog.Warn("SLACK_SKIP_VERIFICATION is set; Slack requests are accepted without a signature")
+		} else {
+			slog.Warn("SLACK_SIGNING_SECRET is not set; Slack requests will be rejected")
+		}
+	}
+
+	// STORAGE=memory runs without Postgres and Redis for local development;
+	// everything is lost on restart.
+	stores := store.Default()
+	if cfg.Storage == config.StorageMemory {
+		stores = store.NewMemory().Stores()
+		slog.Info("Using in-memory storage")
+	} else {
+		db.Init(cfg.Database)
+		utils.InitRedis(cfg.RedisURL)
+		api.AddReadinessCheck("postgres", db.Ping)
+		api.AddReadinessCheck("redis", utils.PingRedis)
+	}
+	api.AddReadinessCheck("scheduler", scheduler.CheckHeartbeat)
+	api.AddReadinessCheck("encryption", func(context.Context) error { return utils.CheckKeys() })
+	api.SetStores(stores)
+	scheduler.SetStores(stores)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Team data keys are wrapped with the provider InitCrypto picked from
+	// KMS_PROVIDER.
+	utils.SetDataKeyStore(stores.Crypto)
+
+	// SIGTERM (sent on deploy) and SIGINT stop the scheduler and the server;
+	// a second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		scheduler.StartScheduler(ctx)
+	}()
+
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: SetupRouter()}
+	go func() {
+		slog.Info("Server running", "port", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("Server failed", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	slog.Info("Shutting down", "timeout", shutdownTimeout)
+	shutdown(server, schedulerDone)
+}
+
+// shutdownTimeout bounds how long in-flight requests and scheduler jobs get
+// to finish after a shutdown signal.
+const shutdownTimeout = 25 * time.Second
+
+// tracingFlushTimeout bounds exporting the spans still buffered on exit.
+const tracingFlushTimeout = 5 * time.Second
+
+// shutdown stops accepting requests, then waits for in-flight requests and
+// the tasks they started, the scheduler loop and its running jobs, giving
+// up on what's still running after shutdownTimeout.
+func shutdown(server *http.Server, schedulerDone <-chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Failed to drain HTTP requests", "error", err)
+	}
+	if err := api.Drain(ctx); err != nil {
+		slog.Error("Failed to drain background tasks", "error", err)
 	}
 
-	log.Println("Server running on port", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		log.Fatal("Server failed:", err)
+	select {
+	case <-schedulerDone:
+		if err := scheduler.Drain(ctx); err != nil {
+			slog.Error("Failed to drain scheduler jobs", "error", err)
+			return
+		}
+	case <-ctx.Done():
+		slog.Error("Scheduler tick still running at shutdown deadline")
+		return
 	}
+	slog.Info("Shutdown complete")
 }
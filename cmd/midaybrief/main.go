@@ -0,0 +1,13 @@
+// Command midaybrief runs operator commands against a MidayBrief
+// deployment; see package cli. The server binary accepts the same commands.
+package main
+
+import (
+	"os"
+
+	"MidayBrief/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:]))
+}
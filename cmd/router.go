@@ -4,18 +4,27 @@ import (
 	"net/http"
 
 	"MidayBrief/api"
+	"MidayBrief/metrics"
+	"MidayBrief/tracing"
 
 	"github.com/go-chi/chi/v5"
 )
 
 func SetupRouter() http.Handler {
 	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
 
-	r.Get("/health", api.HandleHealthCheck)
+	r.Get("/health", api.HandleLiveness)
+	r.Get("/healthz", api.HandleLiveness)
+	r.Get("/readyz", api.HandleReadiness)
+	r.Handle("/metrics", metrics.Handler())
 
 	r.Get("/slack/install", api.HandleSlackInstall)
 	r.Get("/slack/oauth/callback", api.HandleSlackOAuthCallback)
-	r.Post("/slack/events", api.HandleSlackEvents)
+	r.With(api.VerifySlackSignature).Post("/slack/events", api.HandleSlackEvents)
+
+	r.Mount("/api/v1", api.AdminAPI())
+	r.Mount("/dashboard", api.Dashboard())
 
 	return r
 }
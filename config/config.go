@@ -0,0 +1,272 @@
+// Package config loads MidayBrief's settings once at startup, from the
+// environment, .env and an optional CONFIG_FILE, and validates them before
+// any subsystem starts.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Storage backends selectable with STORAGE.
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+// Config is the application configuration. Each field notes the variable
+// it is read from.
+type Config struct {
+	Port    string // PORT, default 8080
+	BaseURL string // BASE_URL, the public URL Slack redirects to
+
+	// Storage is StoragePostgres, the default, or StorageMemory to run
+	// without Postgres and Redis for local development.
+	Storage string // STORAGE
+
+	Database Database
+	RedisURL string // REDIS_URL
+	Slack    Slack
+	Crypto   Crypto
+
+	DataRetention time.Duration // DATA_RETENTION_DAYS, default 30 days
+	// HistoryRetention is how long summarized updates are kept for the
+	// dashboard; zero keeps them forever.
+	HistoryRetention time.Duration // HISTORY_RETENTION_DAYS, default 365 days
+	MetricsMaxTeams  int           // METRICS_MAX_TEAMS, default 50
+
+	LogLevel  slog.Level // LOG_LEVEL: debug, info (default), warn or error
+	LogFormat string     // LOG_FORMAT: text (default) or json
+}
+
+// Database configures the Postgres connection.
+type Database struct {
+	URL            string // DATABASE_URL
+	MigrateOnStart bool   // MIGRATE_ON_START
+}
+
+// Slack holds the app's Slack credentials.
+type Slack struct {
+	ClientID      string // SLACK_CLIENT_ID
+	ClientSecret  string // SLACK_CLIENT_SECRET
+	SigningSecret string // SLACK_SIGNING_SECRET; requests are rejected without it
+	APIURL        string // SLACK_API_URL, overriding the Web API root
+	// SkipVerification accepts unsigned requests while SigningSecret isn't
+	// set, for local development with STORAGE=memory.
+	SkipVerification bool // SLACK_SKIP_VERIFICATION
+}
+
+// Crypto holds the encryption and message hash keys; see utils.InitCrypto
+// for their formats.
+type Crypto struct {
+	// Keys is ENCRYPTION_KEYS followed by the keys in ENCRYPTION_KEYS_FILE.
+	Keys      string
+	KeyID     string // ENCRYPTION_KEY_ID
+	LegacyKey string // ENCRYPTION_KEY
+	HashKeys  string // HASH_KEYS
+	HashKeyID string // HASH_KEY_ID
+
+	// KMSProvider keeps the master key that wraps team data keys in a KMS
+	// instead of the keyring: empty for the keyring, or KMSProviderVault.
+	// Data keys wrapped by one provider can't be unwrapped by another.
+	KMSProvider string // KMS_PROVIDER
+	KMSKeyID    string // KMS_KEY_ID, the master key's name in the KMS
+	VaultAddr   string // VAULT_ADDR
+	VaultToken  string // VAULT_TOKEN
+}
+
+// KMSProviderVault wraps data keys with Vault's transit secrets engine.
+const KMSProviderVault = "vault"
+
+const (
+	defaultPort            = "8080"
+	defaultRetentionDays   = 30
+	defaultHistoryDays     = 365
+	defaultMetricsMaxTeams = 50
+)
+
+// Load reads the configuration. Values that can't be parsed are reported
+// together in the returned error; call Validate to check the result is
+// complete.
+func Load() (*Config, error) {
+	if err := loadEnvFiles(); err != nil {
+		return nil, fmt.Errorf("config.Load: %w", err)
+	}
+
+	var errs []error
+	cfg := &Config{
+		Port:     getenv("PORT", defaultPort),
+		BaseURL:  strings.TrimRight(os.Getenv("BASE_URL"), "/"),
+		Storage:  getenv("STORAGE", StoragePostgres),
+		RedisURL: strings.TrimSpace(os.Getenv("REDIS_URL")),
+		Database: Database{
+			URL: os.Getenv("DATABASE_URL"),
+		},
+		Slack: Slack{
+			ClientID:      os.Getenv("SLACK_CLIENT_ID"),
+			ClientSecret:  os.Getenv("SLACK_CLIENT_SECRET"),
+			SigningSecret: os.Getenv("SLACK_SIGNING_SECRET"),
+			APIURL:        os.Getenv("SLACK_API_URL"),
+		},
+		Crypto: Crypto{
+			Keys:      os.Getenv("ENCRYPTION_KEYS"),
+			KeyID:     os.Getenv("ENCRYPTION_KEY_ID"),
+			LegacyKey: os.Getenv("ENCRYPTION_KEY"),
+			HashKeys:  os.Getenv("HASH_KEYS"),
+			HashKeyID: os.Getenv("HASH_KEY_ID"),
+
+			KMSProvider: strings.TrimSpace(os.Getenv("KMS_PROVIDER")),
+			KMSKeyID:    os.Getenv("KMS_KEY_ID"),
+			VaultAddr:   strings.TrimSpace(os.Getenv("VAULT_ADDR")),
+			VaultToken:  os.Getenv("VAULT_TOKEN"),
+		},
+		LogFormat: getenv("LOG_FORMAT", "text"),
+	}
+
+	if value := os.Getenv("MIGRATE_ON_START"); value != "" {
+		migrate, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("MIGRATE_ON_START must be true or false, got %q", value))
+		}
+		cfg.Database.MigrateOnStart = migrate
+	}
+
+	if value := os.Getenv("SLACK_SKIP_VERIFICATION"); value != "" {
+		skip, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("SLACK_SKIP_VERIFICATION must be true or false, got %q", value))
+		}
+		cfg.Slack.SkipVerification = skip
+	}
+
+	if path := os.Getenv("ENCRYPTION_KEYS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ENCRYPTION_KEYS_FILE: %w", err))
+		}
+		cfg.Crypto.Keys = joinKeys(cfg.Crypto.Keys, string(data))
+	}
+
+	days, err := getenvInt("DATA_RETENTION_DAYS", defaultRetentionDays)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.DataRetention = time.Duration(days) * 24 * time.Hour
+
+	if days, err = getenvInt("HISTORY_RETENTION_DAYS", defaultHistoryDays); err != nil {
+		errs = append(errs, err)
+	}
+	cfg.HistoryRetention = time.Duration(days) * 24 * time.Hour
+
+	if cfg.MetricsMaxTeams, err = getenvInt("METRICS_MAX_TEAMS", defaultMetricsMaxTeams); err != nil {
+		errs = append(errs, err)
+	}
+
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := cfg.LogLevel.UnmarshalText([]byte(value)); err != nil {
+			errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", value))
+		}
+	}
+
+	if len(errs) > 0 {
+		return cfg, fmt.Errorf("config.Load: %w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+// Validate reports every missing or invalid setting at once.
+func (c *Config) Validate() error {
+	errs := c.Crypto.validate()
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be a port number, got %q", c.Port))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be text or json, got %q", c.LogFormat))
+	}
+	if c.BaseURL != "" {
+		if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("BASE_URL must be an http(s) URL, got %q", c.BaseURL))
+		}
+	}
+	if c.Slack.APIURL != "" {
+		if u, err := url.Parse(c.Slack.APIURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("SLACK_API_URL must be an absolute URL, got %q", c.Slack.APIURL))
+		}
+	}
+
+	switch c.Storage {
+	case StorageMemory:
+		// Local development can run without Slack credentials; installs are
+		// unavailable and Slack requests rejected until they're set, unless
+		// SLACK_SKIP_VERIFICATION is.
+	case StoragePostgres:
+		if c.Slack.SkipVerification {
+			errs = append(errs, fmt.Errorf("SLACK_SKIP_VERIFICATION is only allowed with STORAGE=%s", StorageMemory))
+		}
+		if c.Database.URL == "" {
+			errs = append(errs, errors.New("DATABASE_URL is required"))
+		}
+		if c.RedisURL == "" {
+			errs = append(errs, errors.New("REDIS_URL is required"))
+		} else if _, err := redis.ParseURL(c.RedisURL); err != nil {
+			errs = append(errs, fmt.Errorf("REDIS_URL: %w", err))
+		}
+		required := []struct{ name, value string }{
+			{"BASE_URL", c.BaseURL},
+			{"SLACK_CLIENT_ID", c.Slack.ClientID},
+			{"SLACK_CLIENT_SECRET", c.Slack.ClientSecret},
+			{"SLACK_SIGNING_SECRET", c.Slack.SigningSecret},
+		}
+		for _, v := range required {
+			if v.value == "" {
+				errs = append(errs, fmt.Errorf("%s is required", v.name))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE must be %s or %s, got %q", StoragePostgres, StorageMemory, c.Storage))
+	}
+
+	return errors.Join(errs...)
+}
+
+// joinKeys joins key lists separated by commas or newlines into one
+// comma-separated list, dropping empty entries.
+func joinKeys(lists ...string) string {
+	var entries []string
+	for _, list := range lists {
+		for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' }) {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+func getenv(name, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getenvInt(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fallback, fmt.Errorf("%s must be a non-negative integer, got %q", name, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testKey = "0123456789abcdef0123456789abcdef"
+
+func TestValidateReportsEveryKeyProblem(t *testing.T) {
+	cfg := &Config{
+		Port:      defaultPort,
+		Storage:   StorageMemory,
+		LogFormat: "text",
+		Crypto: Crypto{
+			Keys:      "k1:short,bad id:" + testKey + ",dek:" + testKey + ",k3:" + testKey,
+			KeyID:     "k2",
+			HashKeys:  "h1:" + testKey + ",h2:short",
+			HashKeyID: "h3",
+		},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted invalid keys")
+	}
+	for _, want := range []string{
+		"ENCRYPTION_KEYS: key k1: key must be 32 bytes long",
+		`ENCRYPTION_KEYS: entries must be id:key with an alphanumeric id, got "bad id"`,
+		"ENCRYPTION_KEYS: the key id dek is reserved",
+		"ENCRYPTION_KEY_ID k2 is not in the keyring",
+		"HASH_KEYS: key h2: key must be 32 bytes long",
+		"HASH_KEY_ID h3 is not in HASH_KEYS",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error is missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidateAcceptsLegacyKey(t *testing.T) {
+	cfg := &Config{Port: defaultPort, Storage: StorageMemory, LogFormat: "text", Crypto: Crypto{LegacyKey: testKey, KeyID: LegacyKeyID}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Crypto = Crypto{}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "no encryption key configured") {
+		t.Fatalf("Validate without keys: %v", err)
+	}
+}
+
+func TestLoadJoinsKeysFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("k1:"+testKey+"\n\nk2:"+testKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ENCRYPTION_KEYS_FILE", path)
+
+	t.Setenv("ENCRYPTION_KEYS", "")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "k1:" + testKey + ",k2:" + testKey; cfg.Crypto.Keys != want {
+		t.Errorf("Keys = %q, want %q", cfg.Crypto.Keys, want)
+	}
+
+	t.Setenv("ENCRYPTION_KEYS", "k0:"+testKey+",")
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "k0:" + testKey + ",k1:" + testKey + ",k2:" + testKey; cfg.Crypto.Keys != want {
+		t.Errorf("Keys = %q, want %q", cfg.Crypto.Keys, want)
+	}
+}
+
+func TestValidateChecksKMSSettings(t *testing.T) {
+	cfg := &Config{Port: defaultPort, Storage: StorageMemory, LogFormat: "text", Crypto: Crypto{LegacyKey: testKey, KMSProvider: KMSProviderVault}}
+	err := cfg.Validate()
+	for _, want := range []string{"KMS_KEY_ID is required", "VAULT_TOKEN is required", "VAULT_ADDR must be an http(s) URL"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error is missing %q:\n%v", want, err)
+		}
+	}
+
+	cfg.Crypto.KMSProvider = "aws"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "KMS_PROVIDER must be") {
+		t.Errorf("Validate with an unknown KMS provider: %v", err)
+	}
+}
@@ -1,19 +1,28 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 
 	"github.com/joho/godotenv"
 )
 
-func LoadEnv() {
+// loadEnvFiles adds the variables of .env and of the file named by
+// CONFIG_FILE to the environment. Neither overrides a variable that is
+// already set, so the environment wins over .env, which wins over the config
+// file. .env is skipped on Railway, where variables come from the service.
+func loadEnvFiles() error {
 	if os.Getenv("RAILWAY_ENVIRONMENT") == "" {
-		// Only load .env locally
-		_ = godotenv.Load()
-		err := godotenv.Load()
-		if err != nil {
-			log.Println("Warning: .env file not loaded")
+		if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("loadEnvFiles: .env: %w", err)
 		}
 	}
-}
\ No newline at end of file
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := godotenv.Load(path); err != nil {
+			return fmt.Errorf("loadEnvFiles: CONFIG_FILE %s: %w", path, err)
+		}
+	}
+	return nil
+}
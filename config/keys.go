@@ -0,0 +1,123 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Key IDs with a fixed meaning. LegacyKeyID names ENCRYPTION_KEY in the
+// keyring; ReservedKeyID can't name an encryption key because values sealed
+// with a team's data key are prefixed with it.
+const (
+	LegacyKeyID   = "legacy"
+	ReservedKeyID = "dek"
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// KeyEntry is one id:key entry of ENCRYPTION_KEYS or HASH_KEYS.
+type KeyEntry struct {
+	ID  string
+	Key []byte
+}
+
+// ParseKeyList parses an "id:key,id:key" list read from the variable name,
+// skipping blank entries. Every bad entry is reported.
+func ParseKeyList(name, list string) ([]KeyEntry, error) {
+	var entries []KeyEntry
+	var errs []error
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(entry, ":")
+		if !ok || !keyIDPattern.MatchString(id) {
+			errs = append(errs, fmt.Errorf("%s: entries must be id:key with an alphanumeric id, got %q", name, id))
+			continue
+		}
+		key, err := ParseKey(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: key %s: %w", name, id, err))
+			continue
+		}
+		entries = append(entries, KeyEntry{ID: id, Key: key})
+	}
+	return entries, errors.Join(errs...)
+}
+
+// ParseKey decodes a key given as 32 raw bytes or as base64 of 32 bytes.
+func ParseKey(value string) ([]byte, error) {
+	if len(value) == 32 {
+		return []byte(value), nil
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil && len(decoded) == 32 {
+		return decoded, nil
+	}
+	return nil, errors.New("key must be 32 bytes long (AES-256), raw or base64")
+}
+
+// validate checks the keys the way utils.InitCrypto loads them. Hash keys
+// derived from the encryption keys when HASH_KEYS isn't set are checked
+// when they're loaded.
+func (c Crypto) validate() []error {
+	var errs []error
+	ids := make(map[string]bool)
+
+	if c.LegacyKey != "" {
+		if _, err := ParseKey(c.LegacyKey); err != nil {
+			errs = append(errs, fmt.Errorf("ENCRYPTION_KEY: %w", err))
+		}
+		ids[LegacyKeyID] = true
+	}
+	entries, err := ParseKeyList("ENCRYPTION_KEYS", c.Keys)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, entry := range entries {
+		if entry.ID == ReservedKeyID {
+			errs = append(errs, fmt.Errorf("ENCRYPTION_KEYS: the key id %s is reserved", ReservedKeyID))
+			continue
+		}
+		ids[entry.ID] = true
+	}
+	if len(ids) == 0 && len(errs) == 0 {
+		errs = append(errs, errors.New("no encryption key configured: set ENCRYPTION_KEYS or ENCRYPTION_KEY"))
+	} else if c.KeyID != "" && len(ids) > 0 && !ids[c.KeyID] {
+		errs = append(errs, fmt.Errorf("ENCRYPTION_KEY_ID %s is not in the keyring", c.KeyID))
+	}
+
+	switch c.KMSProvider {
+	case "":
+	case KMSProviderVault:
+		if c.KMSKeyID == "" {
+			errs = append(errs, fmt.Errorf("KMS_KEY_ID is required with KMS_PROVIDER=%s", KMSProviderVault))
+		}
+		if c.VaultToken == "" {
+			errs = append(errs, fmt.Errorf("VAULT_TOKEN is required with KMS_PROVIDER=%s", KMSProviderVault))
+		}
+		if u, err := url.Parse(c.VaultAddr); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("VAULT_ADDR must be an http(s) URL with KMS_PROVIDER=%s, got %q", KMSProviderVault, c.VaultAddr))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("KMS_PROVIDER must be empty or %s, got %q", KMSProviderVault, c.KMSProvider))
+	}
+
+	hashEntries, err := ParseKeyList("HASH_KEYS", c.HashKeys)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(hashEntries) > 0 && c.HashKeyID != "" {
+		found := false
+		for _, entry := range hashEntries {
+			found = found || entry.ID == c.HashKeyID
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("HASH_KEY_ID %s is not in HASH_KEYS", c.HashKeyID))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	AuditActionInstalled    = "installed"
+	AuditActionReinstalled  = "reinstalled"
+	AuditActionUninstalled  = "uninstalled"
+	AuditActionAdminAdded   = "admin_added"
+	AuditActionAdminRemoved = "admin_removed"
+	// AuditActionUserDataDeleted is recorded, with no actor, when an
+	// operator deletes a user's data.
+	AuditActionUserDataDeleted = "user_data_deleted"
+)
+
+func AddTeamAdmin(teamID, userID string) error {
+	err := DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&TeamAdmin{TeamID: teamID, UserID: userID, CreatedAt: time.Now().UTC()}).Error
+	if err != nil {
+		return fmt.Errorf("AddTeamAdmin: failed for team %s, user %s: %w", teamID, userID, err)
+	}
+	return nil
+}
+
+// RemoveTeamAdmin removes the admin unless they are the team's last one.
+func RemoveTeamAdmin(teamID, userID string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&TeamAdmin{}).Where("team_id = ?", teamID).Count(&count).Error; err != nil {
+			return fmt.Errorf("RemoveTeamAdmin: failed to count admins for team %s: %w", teamID, err)
+		}
+		if count <= 1 {
+			return fmt.Errorf("RemoveTeamAdmin: can't remove the last admin of team %s", teamID)
+		}
+		if err := tx.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&TeamAdmin{}).Error; err != nil {
+			return fmt.Errorf("RemoveTeamAdmin: failed for team %s, user %s: %w", teamID, userID, err)
+		}
+		return nil
+	})
+}
+
+func GetTeamAdmins(teamID string) ([]string, error) {
+	var admins []string
+	err := DB.Model(&TeamAdmin{}).Where("team_id = ?", teamID).Order("created_at").Pluck("user_id", &admins).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetTeamAdmins: failed for team %s: %w", teamID, err)
+	}
+	return admins, nil
+}
+
+// IsAdmin reports whether the user administers the team, either directly or
+// as an admin of the org-wide install the team belongs to.
+func IsAdmin(team *TeamConfig, userID string) bool {
+	if IsTeamAdmin(team.TeamID, userID) {
+		return true
+	}
+	return team.EnterpriseID != "" && team.EnterpriseID != team.TeamID && IsTeamAdmin(team.EnterpriseID, userID)
+}
+
+func IsTeamAdmin(teamID, userID string) bool {
+	var count int64
+	DB.Model(&TeamAdmin{}).Where("team_id = ? AND user_id = ?", teamID, userID).Count(&count)
+	return count > 0
+}
+
+func RecordAudit(teamID, actorUserID, action, detail string) error {
+	event := AuditEvent{
+		TeamID:      teamID,
+		ActorUserID: actorUserID,
+		Action:      action,
+		Detail:      detail,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := DB.Create(&event).Error; err != nil {
+		return fmt.Errorf("RecordAudit: failed to record %s for team %s: %w", action, teamID, err)
+	}
+	return nil
+}
+
+func GetAuditEvents(teamID string, limit int) ([]AuditEvent, error) {
+	var events []AuditEvent
+	err := DB.Where("team_id = ?", teamID).Order("created_at DESC").Limit(limit).Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetAuditEvents: failed for team %s: %w", teamID, err)
+	}
+	return events, nil
+}
+
+// GetUserAuditEvents returns the team's audit events the user acted in,
+// newest first.
+func GetUserAuditEvents(teamID, userID string) ([]AuditEvent, error) {
+	var events []AuditEvent
+	err := DB.Where("team_id = ? AND actor_user_id = ?", teamID, userID).Order("created_at DESC").Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetUserAuditEvents: failed for team %s, user %s: %w", teamID, userID, err)
+	}
+	return events, nil
+}
+
+// migrateTeamAdmins seeds the admins list from the single admin_user_id of
+// teams installed before admins lists existed.
+func migrateTeamAdmins(tx *gorm.DB) error {
+	var teams []struct {
+		TeamID, AdminUserID, InstallerUserID string
+	}
+	err := tx.Table("team_configs").
+		Select("team_id, admin_user_id, installer_user_id").
+		Where("admin_user_id <> '' AND NOT EXISTS (SELECT 1 FROM team_admins WHERE team_admins.team_id = team_configs.team_id)").
+		Scan(&teams).Error
+	if err != nil {
+		return fmt.Errorf("migrateTeamAdmins: failed to list teams: %w", err)
+	}
+
+	for _, team := range teams {
+		admin := TeamAdmin{TeamID: team.TeamID, UserID: team.AdminUserID, CreatedAt: time.Now().UTC()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&admin).Error; err != nil {
+			return fmt.Errorf("migrateTeamAdmins: failed to add admin for team %s: %w", team.TeamID, err)
+		}
+		if team.InstallerUserID == "" {
+			if err := tx.Table("team_configs").Where("team_id = ?", team.TeamID).Update("installer_user_id", team.AdminUserID).Error; err != nil {
+				return fmt.Errorf("migrateTeamAdmins: failed to set installer for team %s: %w", team.TeamID, err)
+			}
+		}
+	}
+	return nil
+}
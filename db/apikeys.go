@@ -0,0 +1,82 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scopes an APIKey can be granted. Write scopes don't imply read.
+const (
+	APIScopeTeamRead          = "team:read"
+	APIScopeStandupsRead      = "standups:read"
+	APIScopeStandupsWrite     = "standups:write"
+	APIScopeParticipantsRead  = "participants:read"
+	APIScopeParticipantsWrite = "participants:write"
+	APIScopeSubmissionsRead   = "submissions:read"
+)
+
+// APIScopes lists every scope, in the order they are documented.
+var APIScopes = []string{
+	APIScopeTeamRead,
+	APIScopeStandupsRead,
+	APIScopeStandupsWrite,
+	APIScopeParticipantsRead,
+	APIScopeParticipantsWrite,
+	APIScopeSubmissionsRead,
+}
+
+const (
+	AuditActionAPIKeyCreated = "api_key_created"
+	AuditActionAPIKeyRevoked = "api_key_revoked"
+)
+
+// CreateAPIKey stores key, setting its ID.
+func CreateAPIKey(key *APIKey) error {
+	if err := DB.Create(key).Error; err != nil {
+		return fmt.Errorf("CreateAPIKey: failed for team %s: %w", key.TeamID, err)
+	}
+	return nil
+}
+
+// GetAPIKeyByHash returns the unrevoked key with the given hash.
+func GetAPIKeyByHash(hash string) (*APIKey, error) {
+	var key APIKey
+	if err := DB.Where("key_hash = ? AND revoked_at IS NULL", hash).First(&key).Error; err != nil {
+		return nil, fmt.Errorf("GetAPIKeyByHash: %w", err)
+	}
+	return &key, nil
+}
+
+// GetAPIKeys returns the team's unrevoked keys, oldest first.
+func GetAPIKeys(teamID string) ([]APIKey, error) {
+	var keys []APIKey
+	err := DB.Where("team_id = ? AND revoked_at IS NULL", teamID).Order("created_at").Find(&keys).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetAPIKeys: failed for team %s: %w", teamID, err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes the team's key with the given prefix.
+func RevokeAPIKey(teamID, prefix string) error {
+	result := DB.Model(&APIKey{}).
+		Where("team_id = ? AND prefix = ? AND revoked_at IS NULL", teamID, prefix).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		return fmt.Errorf("RevokeAPIKey: failed for team %s, key %s: %w", teamID, prefix, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("RevokeAPIKey: team %s has no key %s: %w", teamID, prefix, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// TouchAPIKey records that the key was used at usedAt.
+func TouchAPIKey(id uint, usedAt time.Time) error {
+	if err := DB.Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", usedAt).Error; err != nil {
+		return fmt.Errorf("TouchAPIKey: failed for key %d: %w", id, err)
+	}
+	return nil
+}
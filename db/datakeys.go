@@ -0,0 +1,40 @@
+package db
+
+import (
+	"MidayBrief/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func GetDataKey(teamID string) ([]byte, string, error) {
+	var key TeamDataKey
+	err := DB.Where("team_id = ?", teamID).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", utils.ErrDataKeyNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("GetDataKey: failed for team %s: %w", teamID, err)
+	}
+	return key.WrappedKey, key.MasterKeyID, nil
+}
+
+func CreateDataKey(teamID string, wrapped []byte, masterKeyID string) (bool, error) {
+	now := time.Now().UTC()
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&TeamDataKey{TeamID: teamID, WrappedKey: wrapped, MasterKeyID: masterKeyID, CreatedAt: now, UpdatedAt: now})
+	if result.Error != nil {
+		return false, fmt.Errorf("CreateDataKey: failed for team %s: %w", teamID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func DeleteDataKey(teamID string) error {
+	if err := DB.Where("team_id = ?", teamID).Delete(&TeamDataKey{}).Error; err != nil {
+		return fmt.Errorf("DeleteDataKey: failed for team %s: %w", teamID, err)
+	}
+	return nil
+}
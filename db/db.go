@@ -1,27 +1,82 @@
 package db
 
 import (
-	"log"
-	"os"
+	"MidayBrief/config"
+	"MidayBrief/logging"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
-func Init() {
-	dsn := os.Getenv("DATABASE_URL")
+// Connect opens the database at dsn without checking its schema; used by
+// the migrate subcommand.
+func Connect(dsn string) {
 	if dsn == "" {
-		log.Fatal("Environment variable DATABASE_URL is not set")
+		logging.Fatal("DATABASE_URL is not set")
 	}
 
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: newLogger()})
 	if err != nil {
-		log.Fatalf("Failed to connect to the database: %v", err)
+		logging.Fatal("Failed to connect to the database", "error", err)
 	}
-	log.Println("Database connection established")
+	if err := registerMetrics(DB); err != nil {
+		logging.Fatal("Failed to register database metrics", "error", err)
+	}
+	if err := registerTracing(DB); err != nil {
+		logging.Fatal("Failed to register database tracing", "error", err)
+	}
+	slog.Info("Database connection established")
+}
+
+// Init connects and refuses to start unless the schema is up to date.
+// With MigrateOnStart it applies pending migrations instead.
+func Init(cfg config.Database) {
+	Connect(cfg.URL)
 
-	DB.AutoMigrate(&TeamConfig{}, &UserMessage{}, &PromptUser{})
+	if cfg.MigrateOnStart {
+		applied, err := MigrateUp()
+		if err != nil {
+			logging.Fatal("Failed to migrate the database", "error", err)
+		}
+		if applied > 0 {
+			slog.Info("Applied database migrations", "applied", applied)
+		}
+	}
+
+	version, err := SchemaVersion()
+	if err != nil {
+		logging.Fatal("Failed to read the database schema version", "error", err)
+	}
+	if latest := LatestSchemaVersion(); version < latest {
+		logging.Fatal("Database schema is out of date; run `midaybrief migrate up`", "version", version, "required", latest)
+	}
+}
+
+// newLogger routes GORM's slow-query and error logs through slog. Queries
+// are logged without their parameters so tokens and messages stay out of
+// the logs.
+func newLogger() logger.Interface {
+	return logger.New(slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn), logger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+		ParameterizedQueries:      true,
+	})
+}
+
+// Ping checks that the database answers.
+func Ping(ctx context.Context) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("Ping: %w", err)
+	}
+	return sqlDB.PingContext(ctx)
 }
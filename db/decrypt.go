@@ -0,0 +1,76 @@
+package db
+
+import (
+	"MidayBrief/metrics"
+	"MidayBrief/utils"
+	"fmt"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// DecryptError reports a stored value that couldn't be decrypted, e.g.
+// because its key was rotated out or its team's data key destroyed.
+type DecryptError struct {
+	Table  string
+	Column string
+	RowID  uint
+	TeamID string
+	Err    error
+}
+
+func (e *DecryptError) Error() string {
+	return fmt.Sprintf("can't decrypt %s.%s of row %d (team %s): %v", e.Table, e.Column, e.RowID, e.TeamID, e.Err)
+}
+
+func (e *DecryptError) Unwrap() error {
+	return e.Err
+}
+
+// DecryptField decrypts *value in place with the team's key. Empty values
+// are left alone. On failure *value is cleared so ciphertext never leaks to
+// callers, and the failure is logged and counted.
+func DecryptField(table, column string, rowID uint, teamID string, value *string) *DecryptError {
+	if *value == "" {
+		return nil
+	}
+	plain, err := utils.DecryptForTeam(teamID, *value)
+	if err != nil {
+		*value = ""
+		decryptErr := &DecryptError{Table: table, Column: column, RowID: rowID, TeamID: teamID, Err: err}
+		metrics.DecryptionFailures.WithLabelValues(table, column).Inc()
+		slog.Error("Decryption failure", "table", table, "column", column, "row_id", rowID, "team_id", teamID, "error", err)
+		return decryptErr
+	}
+	*value = plain
+	return nil
+}
+
+// AfterFind decrypts the tokens of every loaded team. A failure is kept in
+// DecryptErr rather than failing the query, so one bad row doesn't hide the
+// others.
+func (t *TeamConfig) AfterFind(tx *gorm.DB) error {
+	if err := DecryptField("team_configs", "access_token", t.ID, t.TeamID, &t.AccessToken); err != nil {
+		t.DecryptErr = err
+		traceDecryptError(tx, err)
+	}
+	if err := DecryptField("team_configs", "refresh_token", t.ID, t.TeamID, &t.RefreshToken); err != nil {
+		t.DecryptErr = err
+		traceDecryptError(tx, err)
+	}
+	return nil
+}
+
+// AfterFind decrypts the message; see TeamConfig.AfterFind.
+func (m *UserMessage) AfterFind(tx *gorm.DB) error {
+	if err := DecryptField("user_messages", "message", m.ID, m.TeamID, &m.Message); err != nil {
+		m.DecryptErr = err
+		traceDecryptError(tx, err)
+	}
+	return nil
+}
+
+// rawDB skips the decryption hooks, for code that works on ciphertext.
+func rawDB() *gorm.DB {
+	return DB.Session(&gorm.Session{SkipHooks: true})
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Summary outcomes recorded in StandupRun.Status; they match the labels of
+// the summaries posted metric.
+const (
+	RunStatusPosted = "posted"
+	RunStatusEmpty  = "empty"
+	RunStatusFailed = "failed"
+)
+
+// historyRetention is how long summarized updates and runs are kept; see
+// SetHistoryRetention.
+var historyRetention = 365 * 24 * time.Hour
+
+// SetHistoryRetention replaces the history retention period, configured in
+// days by HISTORY_RETENTION_DAYS. Zero keeps history forever.
+func SetHistoryRetention(d time.Duration) {
+	historyRetention = d
+}
+
+// HistoryRetention is how long summarized updates and runs are kept.
+func HistoryRetention() time.Duration {
+	return historyRetention
+}
+
+// SaveStandupRun records the outcome of the standup's summary on run.Date,
+// replacing an earlier attempt on the same day.
+func SaveStandupRun(run *StandupRun) error {
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "standup_id"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"prompted", "submitted", "status", "error", "updated_at"}),
+	}).Create(run).Error
+	if err != nil {
+		return fmt.Errorf("SaveStandupRun: failed for standup %d on %s: %w", run.StandupID, run.Date, err)
+	}
+	return nil
+}
+
+// GetStandupRuns returns the standup's runs from from to to inclusive,
+// oldest first. Empty bounds are open.
+func GetStandupRuns(standupID uint, from, to string) ([]StandupRun, error) {
+	query := DB.Where("standup_id = ?", standupID)
+	if from != "" {
+		query = query.Where("date >= ?", from)
+	}
+	if to != "" {
+		query = query.Where("date <= ?", to)
+	}
+
+	var runs []StandupRun
+	if err := query.Order("date").Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("GetStandupRuns: failed for standup %d: %w", standupID, err)
+	}
+	return runs, nil
+}
+
+// GetFailedStandupRuns returns the failed runs of every standup dated since
+// or later, oldest first.
+func GetFailedStandupRuns(since string) ([]StandupRun, error) {
+	var runs []StandupRun
+	err := DB.Where("status = ? AND date >= ?", RunStatusFailed, since).Order("date, standup_id").Find(&runs).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetFailedStandupRuns: failed since %s: %w", since, err)
+	}
+	return runs, nil
+}
+
+// HistoryFilter selects summarized updates. Dates are YYYY-MM-DD and
+// inclusive; empty fields don't filter.
+type HistoryFilter struct {
+	StandupID uint
+	UserID    string
+	From      string
+	To        string
+}
+
+// GetHistory returns the decrypted summarized updates matching filter,
+// newest summary first. Unreadable updates are left out.
+func GetHistory(ctx context.Context, filter HistoryFilter) ([]UserMessage, error) {
+	query := DB.WithContext(ctx).Where("standup_id = ? AND summary_date <> ''", filter.StandupID)
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.From != "" {
+		query = query.Where("summary_date >= ?", filter.From)
+	}
+	if filter.To != "" {
+		query = query.Where("summary_date <= ?", filter.To)
+	}
+
+	var messages []UserMessage
+	if err := query.Order("summary_date DESC, timestamp").Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("GetHistory: failed for standup %d: %w", filter.StandupID, err)
+	}
+	return readableMessages(messages), nil
+}
+
+// PruneHistory deletes summarized updates and runs dated before cutoff.
+func PruneHistory(cutoff time.Time) (int64, error) {
+	date := cutoff.UTC().Format(time.DateOnly)
+	var deleted int64
+	result := DB.Where("summary_date <> '' AND summary_date < ?", date).Delete(&UserMessage{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("PruneHistory: failed to delete updates before %s: %w", date, result.Error)
+	}
+	deleted += result.RowsAffected
+
+	result = DB.Where("date < ?", date).Delete(&StandupRun{})
+	if result.Error != nil {
+		return deleted, fmt.Errorf("PruneHistory: failed to delete runs before %s: %w", date, result.Error)
+	}
+	return deleted + result.RowsAffected, nil
+}
@@ -1,19 +1,21 @@
 package db
 
 import (
-	"MidayBrief/utils"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 )
 
-func SaveUserMessage(teamID, userID, text string) error {
+// SaveUserMessage stores an encrypted update with its utils.MessageHash.
+func SaveUserMessage(teamID string, standupID uint, userID, text, hash string) error {
 	message := UserMessage{
 		TeamID:      teamID,
+		StandupID:   standupID,
 		UserID:      userID,
 		Message:     text,
 		Timestamp:   time.Now().UTC(),
-		MessageHash: utils.Hash(text),
+		MessageHash: hash,
 	}
 
 	if err := DB.Create(&message).Error; err != nil {
@@ -22,27 +24,70 @@ func SaveUserMessage(teamID, userID, text string) error {
 	return nil
 }
 
-func GetMessagesForTeamToday(teamID string, location *time.Location) ([]UserMessage, error) {
+// GetMessagesForStandupToday returns the standup's decrypted updates not
+// yet summarized. Updates that can't be decrypted are left out rather than
+// shown as ciphertext; they are logged and counted by the decryption hook.
+func GetMessagesForStandupToday(ctx context.Context, standupID uint) ([]UserMessage, error) {
 	var messages []UserMessage
-	err := DB.Where("team_id = ?", teamID).Find(&messages).Error
+	err := DB.WithContext(ctx).Where("standup_id = ? AND summary_date = ''", standupID).Order("timestamp").Find(&messages).Error
 
 	if err != nil {
-		return nil, fmt.Errorf("GetMessagesForTeamToday: failed to fetch messages for team %s: %w", teamID, err)
+		return nil, fmt.Errorf("GetMessagesForStandupToday: failed to fetch messages for standup %d: %w", standupID, err)
 	}
+
+	return readableMessages(messages), nil
+}
+
+// readableMessages drops the messages that couldn't be decrypted.
+func readableMessages(messages []UserMessage) []UserMessage {
+	readable := messages[:0]
 	for _, msg := range messages {
-		msg.Message, _ = utils.Decrypt(msg.Message)
+		if msg.DecryptErr == nil {
+			readable = append(readable, msg)
+		}
 	}
-	return messages, nil
+	return readable
 }
 
-func CleanupMessages(teamID string) error {
-	return DB.Where("team_id = ?", teamID).Delete(&UserMessage{}).Error
+// ArchiveMessages moves the standup's pending updates into its history as
+// those of the summary on date.
+func ArchiveMessages(standupID uint, date string) error {
+	err := DB.Model(&UserMessage{}).
+		Where("standup_id = ? AND summary_date = ''", standupID).
+		Update("summary_date", date).Error
+	if err != nil {
+		return fmt.Errorf("ArchiveMessages: failed for standup %d: %w", standupID, err)
+	}
+	return nil
+}
+
+// GetUserMessages returns the user's decrypted updates to the team's
+// standups, summarized or not, oldest first. Unreadable updates are left
+// out.
+func GetUserMessages(teamID, userID string) ([]UserMessage, error) {
+	var messages []UserMessage
+	if err := DB.Where("team_id = ? AND user_id = ?", teamID, userID).Order("timestamp").Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("GetUserMessages: failed for team %s, user %s: %w", teamID, userID, err)
+	}
+	return readableMessages(messages), nil
+}
+
+// DeleteUserMessages deletes the user's updates to the team's standups,
+// summarized or not, and reports how many there were.
+func DeleteUserMessages(teamID, userID string) (int64, error) {
+	result := DB.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&UserMessage{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("DeleteUserMessages: failed for team %s, user %s: %w", teamID, userID, result.Error)
+	}
+	return result.RowsAffected, nil
 }
 
-func IsDuplicateMessage(teamID, userID, hash, timezone string) bool {
+// IsDuplicateMessage reports whether the user already sent an update with
+// one of hashes to the standup today.
+func IsDuplicateMessage(standupID uint, userID string, hashes []string, timezone string) bool {
 	location, err := time.LoadLocation(timezone)
 	if err != nil {
-		log.Printf("Invalid timezone for duplicate check: %s. Defaulting to UTC", timezone)
+		slog.Warn("Invalid timezone for duplicate check; defaulting to UTC", "timezone", timezone)
 		location = time.UTC
 	}
 
@@ -51,7 +96,7 @@ func IsDuplicateMessage(teamID, userID, hash, timezone string) bool {
 
 	var count int64
 	DB.Model(&UserMessage{}).
-		Where("team_id = ? AND user_id = ? AND message_hash = ? AND timestamp >= ?", teamID, userID, hash, startOfDayUTC).
+		Where("standup_id = ? AND user_id = ? AND message_hash IN ? AND timestamp >= ?", standupID, userID, hashes, startOfDayUTC).
 		Count(&count)
 
 	return count > 0
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"MidayBrief/metrics"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// registerMetrics counts failed queries by operation. Missing records are
+// expected and not counted.
+func registerMetrics(db *gorm.DB) error {
+	callbacks := db.Callback()
+	count := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				metrics.StorageErrors.WithLabelValues("postgres", operation).Inc()
+			}
+		}
+	}
+
+	if err := callbacks.Create().After("gorm:create").Register("metrics:create", count("create")); err != nil {
+		return err
+	}
+	if err := callbacks.Query().After("gorm:query").Register("metrics:query", count("query")); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("metrics:update", count("update")); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:delete").Register("metrics:delete", count("delete")); err != nil {
+		return err
+	}
+	if err := callbacks.Row().After("gorm:row").Register("metrics:row", count("row")); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("gorm:raw").Register("metrics:raw", count("raw"))
+}
@@ -0,0 +1,478 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migration is one versioned schema change. Up and Down run in their own
+// transaction together with the bookkeeping in schema_migrations.
+type migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration.
+type SchemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+// MigrationStatus describes a known migration and whether it has run.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// migrationLockID keys the advisory lock that serialises migrations run by
+// several instances at once.
+const migrationLockID = 7_265_140
+
+// migrations must stay ordered by version; never edit one that has shipped,
+// add a new one instead.
+var migrations = []migration{
+	{Version: 1, Name: "baseline", Up: migrateBaselineUp, Down: migrateBaselineDown},
+	{Version: 2, Name: "standups", Up: migrateStandupsUp, Down: migrateStandupsDown},
+	{Version: 3, Name: "team_lifecycle", Up: migrateTeamLifecycleUp, Down: migrateTeamLifecycleDown},
+	{Version: 4, Name: "admins_and_audit", Up: migrateAdminsAndAuditUp, Down: migrateAdminsAndAuditDown},
+	{Version: 5, Name: "token_rotation", Up: migrateTokenRotationUp, Down: migrateTokenRotationDown},
+	{Version: 6, Name: "enterprise_installs", Up: migrateEnterpriseInstallsUp, Down: migrateEnterpriseInstallsDown},
+	{Version: 7, Name: "user_filters", Up: migrateUserFiltersUp, Down: migrateUserFiltersDown},
+	{Version: 8, Name: "default_standups", Up: migrateDefaultStandups, Down: noDown},
+	{Version: 9, Name: "team_admins", Up: migrateTeamAdmins, Down: noDown},
+	{Version: 10, Name: "unique_prompt_users", Up: migrateUniquePromptUsersUp, Down: migrateUniquePromptUsersDown},
+	{Version: 11, Name: "drop_legacy_team_columns", Up: migrateDropLegacyColumnsUp, Down: migrateDropLegacyColumnsDown},
+	{Version: 12, Name: "team_data_keys", Up: migrateTeamDataKeysUp, Down: migrateTeamDataKeysDown},
+	{Version: 13, Name: "api_keys", Up: migrateAPIKeysUp, Down: migrateAPIKeysDown},
+	{Version: 14, Name: "standup_history", Up: migrateStandupHistoryUp, Down: migrateStandupHistoryDown},
+}
+
+// LatestSchemaVersion is the version the code expects the database to be at.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func ensureMigrationsTable() error {
+	return DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    integer PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`).Error
+}
+
+func SchemaVersion() (int, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return 0, fmt.Errorf("SchemaVersion: %w", err)
+	}
+	var version int
+	if err := DB.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("SchemaVersion: %w", err)
+	}
+	return version, nil
+}
+
+func GetMigrationStatus() ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("GetMigrationStatus: %w", err)
+	}
+	var applied []SchemaMigration
+	if err := DB.Find(&applied).Error; err != nil {
+		return nil, fmt.Errorf("GetMigrationStatus: %w", err)
+	}
+	appliedAt := make(map[int]time.Time, len(applied))
+	for _, m := range applied {
+		appliedAt[m.Version] = m.AppliedAt
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := appliedAt[m.Version]; ok {
+			s.AppliedAt = &at
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// MigrateUp applies every pending migration in order and returns how many
+// ran.
+func MigrateUp() (int, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return 0, fmt.Errorf("MigrateUp: %w", err)
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		ran, err := runMigration(m, true)
+		if err != nil {
+			return applied, fmt.Errorf("MigrateUp: migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the latest steps migrations and returns how many ran.
+func MigrateDown(steps int) (int, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return 0, fmt.Errorf("MigrateDown: %w", err)
+	}
+
+	ordered := append([]migration(nil), migrations...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Version > ordered[j].Version })
+
+	reverted := 0
+	for _, m := range ordered {
+		if reverted == steps {
+			break
+		}
+		ran, err := runMigration(m, false)
+		if err != nil {
+			return reverted, fmt.Errorf("MigrateDown: migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			reverted++
+		}
+	}
+	return reverted, nil
+}
+
+// runMigration applies (up) or reverts (down) m unless it already is, and
+// reports whether it did anything.
+func runMigration(m migration, up bool) (bool, error) {
+	ran := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&SchemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if (count > 0) == up {
+			return nil
+		}
+
+		if up {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+		}
+
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		ran = true
+		return tx.Where("version = ?", m.Version).Delete(&SchemaMigration{}).Error
+	})
+	return ran, err
+}
+
+var errIrreversible = errors.New("migration can't be reverted")
+
+// noDown is used by data migrations whose changes can't be undone; reverting
+// past them needs a restore from backup.
+func noDown(*gorm.DB) error {
+	return errIrreversible
+}
+
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateBaselineUp creates the schema as AutoMigrate left it before
+// versioned migrations, so existing databases are adopted without changes.
+func migrateBaselineUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS team_configs (
+			id            bigserial PRIMARY KEY,
+			team_id       text NOT NULL,
+			access_token  text NOT NULL,
+			bot_user_id   text,
+			admin_user_id text,
+			channel_id    text,
+			post_time     text,
+			timezone      text,
+			prompt_time   text,
+			created_at    timestamptz,
+			updated_at    timestamptz
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_team_configs_team_id ON team_configs (team_id)`,
+		`CREATE TABLE IF NOT EXISTS user_messages (
+			id           bigserial PRIMARY KEY,
+			team_id      text NOT NULL,
+			user_id      text NOT NULL,
+			message      text NOT NULL,
+			message_hash text NOT NULL,
+			timestamp    timestamptz
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_messages_team_id ON user_messages (team_id)`,
+		`CREATE TABLE IF NOT EXISTS prompt_users (
+			id         bigserial PRIMARY KEY,
+			team_id    text NOT NULL,
+			user_id    text NOT NULL,
+			is_active  boolean NOT NULL,
+			created_at timestamptz
+		)`,
+	)
+}
+
+func migrateBaselineDown(tx *gorm.DB) error {
+	return execAll(tx,
+		`DROP TABLE IF EXISTS prompt_users`,
+		`DROP TABLE IF EXISTS user_messages`,
+		`DROP TABLE IF EXISTS team_configs`,
+	)
+}
+
+// migrateStandupsUp adds standups and ties participants and updates to
+// one; migrateDefaultStandups fills in the standup of existing rows.
+func migrateStandupsUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS standups (
+			id          bigserial PRIMARY KEY,
+			team_id     text NOT NULL,
+			name        text NOT NULL,
+			channel_id  text,
+			post_time   text,
+			prompt_time text,
+			timezone    text,
+			questions   text,
+			sync_source text,
+			sync_id     text,
+			created_at  timestamptz,
+			updated_at  timestamptz
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_standup_team_name ON standups (team_id, name)`,
+		`ALTER TABLE user_messages ADD COLUMN IF NOT EXISTS standup_id bigint`,
+		`CREATE INDEX IF NOT EXISTS idx_user_messages_standup_id ON user_messages (standup_id)`,
+		`ALTER TABLE prompt_users ADD COLUMN IF NOT EXISTS standup_id bigint`,
+		`CREATE INDEX IF NOT EXISTS idx_prompt_users_standup_id ON prompt_users (standup_id)`,
+	)
+}
+
+func migrateStandupsDown(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE prompt_users DROP COLUMN IF EXISTS standup_id`,
+		`ALTER TABLE user_messages DROP COLUMN IF EXISTS standup_id`,
+		`DROP TABLE IF EXISTS standups`,
+	)
+}
+
+func migrateTeamLifecycleUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS is_active boolean NOT NULL DEFAULT true`,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS deactivated_at timestamptz`,
+	)
+}
+
+func migrateTeamLifecycleDown(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS deactivated_at`,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS is_active`,
+	)
+}
+
+// migrateAdminsAndAuditUp adds the admin list and audit log;
+// migrateTeamAdmins moves the single admin of existing teams into it.
+func migrateAdminsAndAuditUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS installer_user_id text`,
+		`CREATE TABLE IF NOT EXISTS team_admins (
+			id         bigserial PRIMARY KEY,
+			team_id    text NOT NULL,
+			user_id    text NOT NULL,
+			created_at timestamptz
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_team_admin ON team_admins (team_id, user_id)`,
+		`CREATE TABLE IF NOT EXISTS audit_events (
+			id            bigserial PRIMARY KEY,
+			team_id       text NOT NULL,
+			actor_user_id text,
+			action        text NOT NULL,
+			detail        text,
+			created_at    timestamptz
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_events_team_id ON audit_events (team_id)`,
+	)
+}
+
+func migrateAdminsAndAuditDown(tx *gorm.DB) error {
+	return execAll(tx,
+		`DROP TABLE IF EXISTS audit_events`,
+		`DROP TABLE IF EXISTS team_admins`,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS installer_user_id`,
+	)
+}
+
+func migrateTokenRotationUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS refresh_token text`,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS token_expires_at timestamptz`,
+	)
+}
+
+func migrateTokenRotationDown(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS token_expires_at`,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS refresh_token`,
+	)
+}
+
+func migrateEnterpriseInstallsUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS enterprise_id text`,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS is_enterprise_install boolean NOT NULL DEFAULT false`,
+		`CREATE INDEX IF NOT EXISTS idx_team_configs_enterprise_id ON team_configs (enterprise_id)`,
+	)
+}
+
+func migrateEnterpriseInstallsDown(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS is_enterprise_install`,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS enterprise_id`,
+	)
+}
+
+func migrateUserFiltersUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS include_guests boolean NOT NULL DEFAULT true`,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS include_bots boolean NOT NULL DEFAULT false`,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS include_app_users boolean NOT NULL DEFAULT false`,
+	)
+}
+
+func migrateUserFiltersDown(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS include_app_users`,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS include_bots`,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS include_guests`,
+	)
+}
+
+// migrateUniquePromptUsersUp removes duplicate participants, keeping the
+// oldest row, and adds the unique index AddPromptUser's ON CONFLICT relies on.
+func migrateUniquePromptUsersUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`DELETE FROM prompt_users a USING prompt_users b
+			WHERE a.standup_id = b.standup_id AND a.user_id = b.user_id AND a.id > b.id`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_prompt_user_standup_user ON prompt_users (standup_id, user_id)`,
+	)
+}
+
+func migrateUniquePromptUsersDown(tx *gorm.DB) error {
+	return tx.Exec(`DROP INDEX IF EXISTS idx_prompt_user_standup_user`).Error
+}
+
+// migrateDropLegacyColumnsUp drops the single-schedule and single-admin
+// columns once their values have moved to standups and team_admins.
+func migrateDropLegacyColumnsUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS admin_user_id`,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS channel_id`,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS post_time`,
+		`ALTER TABLE team_configs DROP COLUMN IF EXISTS prompt_time`,
+	)
+}
+
+// migrateDropLegacyColumnsDown restores the columns empty; their old values
+// live on in standups and team_admins.
+func migrateDropLegacyColumnsDown(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS admin_user_id text`,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS channel_id text`,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS post_time text`,
+		`ALTER TABLE team_configs ADD COLUMN IF NOT EXISTS prompt_time text`,
+	)
+}
+
+func migrateTeamDataKeysUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`CREATE TABLE team_data_keys (
+			id            bigserial PRIMARY KEY,
+			team_id       text NOT NULL,
+			wrapped_key   bytea NOT NULL,
+			master_key_id text NOT NULL,
+			created_at    timestamptz,
+			updated_at    timestamptz
+		)`,
+		`CREATE UNIQUE INDEX idx_team_data_keys_team_id ON team_data_keys (team_id)`,
+	)
+}
+
+func migrateTeamDataKeysDown(tx *gorm.DB) error {
+	return tx.Exec(`DROP TABLE IF EXISTS team_data_keys`).Error
+}
+
+func migrateAPIKeysUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`CREATE TABLE api_keys (
+			id           bigserial PRIMARY KEY,
+			team_id      text NOT NULL,
+			name         text NOT NULL,
+			prefix       text NOT NULL,
+			key_hash     text NOT NULL,
+			scopes       text NOT NULL,
+			created_by   text,
+			last_used_at timestamptz,
+			revoked_at   timestamptz,
+			created_at   timestamptz
+		)`,
+		`CREATE INDEX idx_api_keys_team_id ON api_keys (team_id)`,
+		`CREATE UNIQUE INDEX idx_api_keys_key_hash ON api_keys (key_hash)`,
+	)
+}
+
+func migrateAPIKeysDown(tx *gorm.DB) error {
+	return tx.Exec(`DROP TABLE IF EXISTS api_keys`).Error
+}
+
+func migrateStandupHistoryUp(tx *gorm.DB) error {
+	return execAll(tx,
+		`ALTER TABLE user_messages ADD COLUMN summary_date text NOT NULL DEFAULT ''`,
+		`CREATE INDEX idx_user_messages_summary_date ON user_messages (summary_date)`,
+		`CREATE TABLE standup_runs (
+			id         bigserial PRIMARY KEY,
+			team_id    text NOT NULL,
+			standup_id bigint NOT NULL,
+			date       text NOT NULL,
+			prompted   integer NOT NULL DEFAULT 0,
+			submitted  integer NOT NULL DEFAULT 0,
+			status     text NOT NULL,
+			error      text,
+			created_at timestamptz,
+			updated_at timestamptz
+		)`,
+		`CREATE INDEX idx_standup_runs_team_id ON standup_runs (team_id)`,
+		`CREATE UNIQUE INDEX idx_standup_run_date ON standup_runs (standup_id, date)`,
+	)
+}
+
+// migrateStandupHistoryDown deletes the history, as older versions delete
+// updates once summarized and would otherwise summarize them again.
+func migrateStandupHistoryDown(tx *gorm.DB) error {
+	return execAll(tx,
+		`DROP TABLE IF EXISTS standup_runs`,
+		`DELETE FROM user_messages WHERE summary_date <> ''`,
+		`ALTER TABLE user_messages DROP COLUMN IF EXISTS summary_date`,
+	)
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// The models as they shipped before versioned migrations, when the schema
+// was created with AutoMigrate.
+type shippedTeamConfig struct {
+	ID          uint   `gorm:"primaryKey"`
+	TeamID      string `gorm:"uniqueIndex;not null"`
+	AccessToken string `gorm:"not null"`
+	BotUserID   string
+	AdminUserID string
+	ChannelID   string
+	PostTime    string
+	Timezone    string
+	PromptTime  string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (shippedTeamConfig) TableName() string { return "team_configs" }
+
+type shippedUserMessage struct {
+	ID          uint   `gorm:"primaryKey"`
+	TeamID      string `gorm:"index;not null"`
+	UserID      string `gorm:"not null"`
+	Message     string `gorm:"not null"`
+	MessageHash string `gorm:"not null"`
+	Timestamp   time.Time
+}
+
+func (shippedUserMessage) TableName() string { return "user_messages" }
+
+type shippedPromptUser struct {
+	ID        uint   `gorm:"primaryKey"`
+	TeamID    string `gorm:"not null"`
+	UserID    string `gorm:"not null"`
+	IsActive  bool   `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+func (shippedPromptUser) TableName() string { return "prompt_users" }
+
+// useTestSchema points DB at a new, empty schema of the database at
+// DATABASE_URL, dropped when the test ends.
+func useTestSchema(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One connection, so SET search_path applies to every statement.
+	sqlDB.SetMaxOpenConns(1)
+
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if err := execAll(conn, "CREATE SCHEMA "+schema, "SET search_path TO "+schema); err != nil {
+		t.Fatal(err)
+	}
+	previous := DB
+	DB = conn
+	t.Cleanup(func() {
+		DB = previous
+		conn.Exec("DROP SCHEMA " + schema + " CASCADE")
+		sqlDB.Close()
+	})
+}
+
+func TestMigrateUpgradesShippedSchema(t *testing.T) {
+	useTestSchema(t)
+	if err := DB.AutoMigrate(&shippedTeamConfig{}, &shippedUserMessage{}, &shippedPromptUser{}); err != nil {
+		t.Fatal(err)
+	}
+	team := shippedTeamConfig{TeamID: "T1", AccessToken: "token", AdminUserID: "U1", ChannelID: "C1", PostTime: "17:00", Timezone: "UTC"}
+	if err := DB.Create(&team).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Create(&shippedPromptUser{TeamID: "T1", UserID: "U2", IsActive: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Create(&shippedUserMessage{TeamID: "T1", UserID: "U2", Message: "m", MessageHash: "h", Timestamp: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", applied, len(migrations))
+	}
+	if version, err := SchemaVersion(); err != nil || version != LatestSchemaVersion() {
+		t.Fatalf("schema version %d (err %v), want %d", version, err, LatestSchemaVersion())
+	}
+
+	var migrated TeamConfig
+	if err := DB.Where("team_id = ?", "T1").First(&migrated).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !migrated.IsActive || !migrated.IncludeGuests || migrated.InstallerUserID != "U1" {
+		t.Errorf("migrated team: active %v, guests %v, installer %q", migrated.IsActive, migrated.IncludeGuests, migrated.InstallerUserID)
+	}
+	var standup Standup
+	if err := DB.Where("team_id = ? AND name = ?", "T1", DefaultStandupName).First(&standup).Error; err != nil {
+		t.Fatal(err)
+	}
+	if standup.ChannelID != "C1" || standup.PostTime != "17:00" {
+		t.Errorf("default standup: channel %q, post time %q", standup.ChannelID, standup.PostTime)
+	}
+	var participant PromptUser
+	if err := DB.Where("team_id = ?", "T1").First(&participant).Error; err != nil {
+		t.Fatal(err)
+	}
+	var message UserMessage
+	if err := DB.Where("team_id = ?", "T1").First(&message).Error; err != nil {
+		t.Fatal(err)
+	}
+	if participant.StandupID != standup.ID || message.StandupID != standup.ID {
+		t.Errorf("participant and update are in standups %d and %d, want %d", participant.StandupID, message.StandupID, standup.ID)
+	}
+	var admins int64
+	if err := DB.Model(&TeamAdmin{}).Where("team_id = ? AND user_id = ?", "T1", "U1").Count(&admins).Error; err != nil || admins != 1 {
+		t.Errorf("found %d admins (err %v), want the shipped admin", admins, err)
+	}
+}
@@ -1,34 +1,182 @@
 package db
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type TeamConfig struct {
+	ID uint `gorm:"primaryKey"`
+	// TeamID is the workspace ID, or the enterprise ID for the installation
+	// record of an org-wide install.
+	TeamID string `gorm:"uniqueIndex;not null"`
+	// EnterpriseID is set for workspaces in an Enterprise Grid org. Workspaces
+	// covered by an org-wide install have no token of their own and use the
+	// one stored on the org's installation record.
+	EnterpriseID        string `gorm:"index"`
+	IsEnterpriseInstall bool   `gorm:"not null;default:false"`
+	// AccessToken and RefreshToken are stored encrypted and decrypted when
+	// loaded; TokenTeamID is the TeamID of the record they were loaded from.
+	TokenTeamID string `gorm:"-"`
+	AccessToken string `gorm:"not null"`
+	// RefreshToken and TokenExpiresAt are only set when the Slack app has
+	// token rotation enabled; AccessToken then expires and must be refreshed.
+	RefreshToken   string
+	TokenExpiresAt *time.Time
+	BotUserID      string
+	// InstallerUserID is whoever most recently installed the app; it grants
+	// no permissions on its own, see TeamAdmin.
+	InstallerUserID string
+	Timezone        string
+	// IncludeGuests, IncludeBots and IncludeAppUsers control which kinds of
+	// accounts `add all users` and participant sync pick up.
+	IncludeGuests   bool `gorm:"not null;default:true"`
+	IncludeBots     bool `gorm:"not null;default:false"`
+	IncludeAppUsers bool `gorm:"not null;default:false"`
+	// IsActive is cleared when the app is uninstalled or its token revoked;
+	// the team's data is purged once DeactivatedAt is older than the
+	// retention period.
+	IsActive      bool `gorm:"not null;default:true"`
+	DeactivatedAt *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	// DecryptErr is set when a token couldn't be decrypted on load; the
+	// token is then empty.
+	DecryptErr *DecryptError `gorm:"-"`
+}
+
+// TeamDataKey is a team's data encryption key, wrapped by the master key
+// provider. Deleting it makes everything encrypted with it unreadable.
+type TeamDataKey struct {
 	ID          uint   `gorm:"primaryKey"`
 	TeamID      string `gorm:"uniqueIndex;not null"`
-	AccessToken string `gorm:"not null"`
-	BotUserID   string
-	AdminUserID string
-	ChannelID   string
-	PostTime    string
-	Timezone    string
-	PromptTime  string
+	WrappedKey  []byte `gorm:"not null"`
+	MasterKeyID string `gorm:"not null"`
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
 
-type UserMessage struct {
+type TeamAdmin struct {
+	ID        uint   `gorm:"primaryKey"`
+	TeamID    string `gorm:"not null;uniqueIndex:idx_team_admin"`
+	UserID    string `gorm:"not null;uniqueIndex:idx_team_admin"`
+	CreatedAt time.Time
+}
+
+type AuditEvent struct {
 	ID          uint   `gorm:"primaryKey"`
 	TeamID      string `gorm:"index;not null"`
-	UserID      string `gorm:"not null"`
+	ActorUserID string
+	Action      string `gorm:"not null"`
+	Detail      string
+	CreatedAt   time.Time
+}
+
+// APIKey lets a team's own tooling use the admin API with the listed
+// scopes. Only a hash of the key is stored; Prefix identifies it in
+// listings.
+type APIKey struct {
+	ID      uint   `gorm:"primaryKey"`
+	TeamID  string `gorm:"index;not null"`
+	Name    string `gorm:"not null"`
+	Prefix  string `gorm:"not null"`
+	KeyHash string `gorm:"uniqueIndex;not null"`
+	// Scopes is a space-separated list of APIScope* values.
+	Scopes     string `gorm:"not null"`
+	CreatedBy  string
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// ScopeList returns the key's scopes.
+func (k APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// HasScope reports whether the key was granted scope.
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type Standup struct {
+	ID         uint   `gorm:"primaryKey"`
+	TeamID     string `gorm:"not null;uniqueIndex:idx_standup_team_name"`
+	Name       string `gorm:"not null;uniqueIndex:idx_standup_team_name"`
+	ChannelID  string
+	PostTime   string
+	PromptTime string
+	Timezone   string
+	Questions  string
+	// SyncSource and SyncID make the participant list follow a channel's
+	// membership or a Slack user group instead of being managed by hand.
+	SyncSource string
+	SyncID     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// QuestionList returns the standup's prompt questions, falling back to the
+// default set when none have been configured.
+func (s Standup) QuestionList() []string {
+	var questions []string
+	for _, q := range strings.Split(s.Questions, "\n") {
+		if q = strings.TrimSpace(q); q != "" {
+			questions = append(questions, q)
+		}
+	}
+	if len(questions) == 0 {
+		return DefaultQuestions
+	}
+	return questions
+}
+
+type UserMessage struct {
+	ID        uint   `gorm:"primaryKey"`
+	TeamID    string `gorm:"index;not null"`
+	StandupID uint   `gorm:"index"`
+	UserID    string `gorm:"not null"`
+	// Message is stored encrypted and decrypted when loaded; DecryptErr is
+	// set if that failed.
 	Message     string `gorm:"not null"`
 	MessageHash string `gorm:"not null"`
 	Timestamp   time.Time
+	// SummaryDate is the day, in the standup's timezone, of the summary that
+	// included the update, and empty until then. Summarized updates are kept
+	// as the standup's history.
+	SummaryDate string        `gorm:"index;not null;default:''"`
+	DecryptErr  *DecryptError `gorm:"-"`
+}
+
+// StandupRun records how a standup's summary went on one day.
+type StandupRun struct {
+	ID        uint   `gorm:"primaryKey"`
+	TeamID    string `gorm:"index;not null"`
+	StandupID uint   `gorm:"not null;uniqueIndex:idx_standup_run_date"`
+	// Date is the day in the standup's timezone, as YYYY-MM-DD.
+	Date string `gorm:"not null;uniqueIndex:idx_standup_run_date"`
+	// Prompted counts the standup's participants and Submitted those of them
+	// who sent an update.
+	Prompted  int `gorm:"not null;default:0"`
+	Submitted int `gorm:"not null;default:0"`
+	// Status is one of the RunStatus* values; Error says why a summary
+	// failed.
+	Status    string `gorm:"not null"`
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 type PromptUser struct {
 	ID        uint   `gorm:"primaryKey"`
 	TeamID    string `gorm:"not null"`
-	UserID    string `gorm:"not null"`
+	StandupID uint   `gorm:"index;uniqueIndex:idx_prompt_user_standup_user"`
+	UserID    string `gorm:"not null;uniqueIndex:idx_prompt_user_standup_user"`
 	IsActive  bool   `gorm:"not null"`
 	CreatedAt time.Time
 }
@@ -1,22 +1,73 @@
 package db
 
 import (
+	"fmt"
 	"time"
 
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-func AddPromptUser(teamID, userID string) error {
+func AddPromptUser(teamID string, standupID uint, userID string) error {
 	return DB.Clauses(clause.OnConflict{DoNothing: true}).
-		Create(&PromptUser{TeamID: teamID, UserID: userID, IsActive: true, CreatedAt: time.Now().UTC()}).Error
+		Create(&PromptUser{TeamID: teamID, StandupID: standupID, UserID: userID, IsActive: true, CreatedAt: time.Now().UTC()}).Error
 }
 
-func RemovePromptUser(teamID, userID string) error {
+func RemovePromptUser(standupID uint, userID string) error {
+	return DB.Where("standup_id = ? AND user_id = ?", standupID, userID).Delete(&PromptUser{}).Error
+}
+
+// RemovePromptUserFromTeam drops the user from every standup in the team.
+func RemovePromptUserFromTeam(teamID, userID string) error {
 	return DB.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&PromptUser{}).Error
 }
 
-func GetAllPromptUser(teamID string) ([]PromptUser, error) {
+func GetAllPromptUser(standupID uint) ([]PromptUser, error) {
 	var users []PromptUser
-	err := DB.Where("team_id = ?", teamID).Find(&users).Error
+	err := DB.Where("standup_id = ?", standupID).Find(&users).Error
 	return users, err
 }
+
+// SyncPromptUsers makes the standup's participants exactly userIDs and
+// reports how many were added and removed.
+func SyncPromptUsers(teamID string, standupID uint, userIDs []string) (added, removed int, err error) {
+	wanted := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		wanted[userID] = true
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var existing []PromptUser
+		if err := tx.Where("standup_id = ?", standupID).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		current := make(map[string]bool, len(existing))
+		for _, user := range existing {
+			current[user.UserID] = true
+			if !wanted[user.UserID] {
+				if err := tx.Delete(&user).Error; err != nil {
+					return err
+				}
+				removed++
+			}
+		}
+
+		now := time.Now().UTC()
+		for userID := range wanted {
+			if current[userID] {
+				continue
+			}
+			user := PromptUser{TeamID: teamID, StandupID: standupID, UserID: userID, IsActive: true, CreatedAt: now}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&user).Error; err != nil {
+				return err
+			}
+			added++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("SyncPromptUsers: failed for standup %d: %w", standupID, err)
+	}
+	return added, removed, nil
+}
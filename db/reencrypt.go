@@ -0,0 +1,167 @@
+package db
+
+import (
+	"MidayBrief/utils"
+	"fmt"
+	"log/slog"
+)
+
+// ReencryptResult counts what a re-encryption pass did.
+type ReencryptResult struct {
+	Reencrypted int
+	Rewrapped   int
+	Rehashed    int
+	Failed      int
+}
+
+// ReencryptStaleCiphertexts brings data at rest up to date with the current
+// keys, batchSize rows at a time: team data keys wrapped with an old master
+// key are re-wrapped, and tokens and messages not yet encrypted the way
+// utils.EncryptForTeam would now are rewritten, and message hashes not made
+// with the current hash key are recomputed. Rows that can't be
+// decrypted are counted and left alone. Each update only applies if the
+// value hasn't changed since it was read, so it can't undo a concurrent
+// token refresh.
+func ReencryptStaleCiphertexts(batchSize int) (ReencryptResult, error) {
+	var result ReencryptResult
+	if err := rewrapDataKeys(batchSize, &result); err != nil {
+		return result, err
+	}
+
+	stale := utils.CiphertextPrefix() + "%"
+
+	var lastID uint
+	for {
+		var teams []TeamConfig
+		err := rawDB().Where("id > ? AND ((access_token <> '' AND access_token NOT LIKE ?) OR (refresh_token <> '' AND refresh_token NOT LIKE ?))", lastID, stale, stale).
+			Order("id").Limit(batchSize).Find(&teams).Error
+		if err != nil {
+			return result, fmt.Errorf("ReencryptStaleCiphertexts: failed to list teams: %w", err)
+		}
+		if len(teams) == 0 {
+			break
+		}
+
+		for _, team := range teams {
+			lastID = team.ID
+			for column, value := range map[string]string{"access_token": team.AccessToken, "refresh_token": team.RefreshToken} {
+				if err := reencryptColumn(&TeamConfig{}, team.ID, team.TeamID, column, value, &result); err != nil {
+					return result, fmt.Errorf("ReencryptStaleCiphertexts: team %s: %w", team.TeamID, err)
+				}
+			}
+		}
+	}
+
+	lastID = 0
+	for {
+		var messages []UserMessage
+		err := rawDB().Where("id > ? AND message NOT LIKE ?", lastID, stale).
+			Order("id").Limit(batchSize).Find(&messages).Error
+		if err != nil {
+			return result, fmt.Errorf("ReencryptStaleCiphertexts: failed to list messages: %w", err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, msg := range messages {
+			lastID = msg.ID
+			if err := reencryptColumn(&UserMessage{}, msg.ID, msg.TeamID, "message", msg.Message, &result); err != nil {
+				return result, fmt.Errorf("ReencryptStaleCiphertexts: message %d: %w", msg.ID, err)
+			}
+		}
+	}
+
+	if err := rehashMessages(batchSize, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// rehashMessages replaces message hashes made with an old hash key, or
+// before hashes were keyed, by decrypting the message and hashing it again.
+func rehashMessages(batchSize int, result *ReencryptResult) error {
+	current := utils.HashPrefix() + "%"
+
+	var lastID uint
+	for {
+		var messages []UserMessage
+		err := rawDB().Where("id > ? AND message_hash NOT LIKE ?", lastID, current).Order("id").Limit(batchSize).Find(&messages).Error
+		if err != nil {
+			return fmt.Errorf("rehashMessages: failed to list messages: %w", err)
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		for _, msg := range messages {
+			lastID = msg.ID
+			text, err := utils.DecryptForTeam(msg.TeamID, msg.Message)
+			if err != nil {
+				slog.Warn("Can't rehash message", "message_id", msg.ID, "team_id", msg.TeamID, "error", err)
+				result.Failed++
+				continue
+			}
+			update := DB.Model(&UserMessage{}).
+				Where("id = ? AND message_hash = ?", msg.ID, msg.MessageHash).
+				Update("message_hash", utils.MessageHash(msg.UserID, text))
+			if update.Error != nil {
+				return fmt.Errorf("rehashMessages: message %d: %w", msg.ID, update.Error)
+			}
+			result.Rehashed += int(update.RowsAffected)
+		}
+	}
+}
+
+func rewrapDataKeys(batchSize int, result *ReencryptResult) error {
+	current := utils.CurrentMasterKeyID()
+
+	var lastID uint
+	for {
+		var keys []TeamDataKey
+		err := DB.Where("id > ? AND master_key_id <> ?", lastID, current).Order("id").Limit(batchSize).Find(&keys).Error
+		if err != nil {
+			return fmt.Errorf("rewrapDataKeys: failed to list data keys: %w", err)
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		for _, key := range keys {
+			lastID = key.ID
+			wrapped, masterKeyID, err := utils.RewrapDataKey(key.WrappedKey, key.MasterKeyID)
+			if err != nil {
+				slog.Warn("Can't re-wrap data key", "team_id", key.TeamID, "error", err)
+				result.Failed++
+				continue
+			}
+			update := DB.Model(&TeamDataKey{}).
+				Where("id = ? AND master_key_id = ?", key.ID, key.MasterKeyID).
+				Updates(map[string]any{"wrapped_key": wrapped, "master_key_id": masterKeyID})
+			if update.Error != nil {
+				return fmt.Errorf("rewrapDataKeys: team %s: %w", key.TeamID, update.Error)
+			}
+			result.Rewrapped += int(update.RowsAffected)
+		}
+	}
+}
+
+func reencryptColumn(model any, id uint, teamID, column, value string, result *ReencryptResult) error {
+	if !utils.NeedsReencryption(value) {
+		return nil
+	}
+
+	reencrypted, err := utils.ReencryptForTeam(teamID, value)
+	if err != nil {
+		slog.Warn("Can't re-encrypt value", "column", column, "row_id", id, "team_id", teamID, "error", err)
+		result.Failed++
+		return nil
+	}
+
+	update := DB.Model(model).Where("id = ? AND "+column+" = ?", id, value).Update(column, reencrypted)
+	if update.Error != nil {
+		return update.Error
+	}
+	result.Reencrypted += int(update.RowsAffected)
+	return nil
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const DefaultStandupName = "default"
+
+const (
+	SyncSourceChannel   = "channel"
+	SyncSourceUserGroup = "usergroup"
+)
+
+var DefaultQuestions = []string{
+	"What did you work on yesterday?",
+	"What are your plans for today?",
+	"Do you have any blockers?",
+}
+
+func CreateStandup(teamID, name, timezone string) (*Standup, error) {
+	now := time.Now().UTC()
+	standup := Standup{
+		TeamID:    teamID,
+		Name:      strings.ToLower(name),
+		Timezone:  timezone,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := DB.Create(&standup).Error; err != nil {
+		return nil, fmt.Errorf("CreateStandup: failed to create standup %s for team %s: %w", name, teamID, err)
+	}
+	return &standup, nil
+}
+
+func GetStandup(id uint) (*Standup, error) {
+	var standup Standup
+	if err := DB.First(&standup, id).Error; err != nil {
+		return nil, fmt.Errorf("GetStandup: failed to retrieve standup %d: %w", id, err)
+	}
+	return &standup, nil
+}
+
+func GetStandupByName(teamID, name string) (*Standup, error) {
+	var standup Standup
+	err := DB.Where("team_id = ? AND name = ?", teamID, strings.ToLower(name)).First(&standup).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetStandupByName: failed to retrieve standup %s for team %s: %w", name, teamID, err)
+	}
+	return &standup, nil
+}
+
+// GetOrCreateDefaultStandup returns the team's default standup, creating it
+// on first use so unscoped commands keep working for new installs.
+func GetOrCreateDefaultStandup(teamID, timezone string) (*Standup, error) {
+	standup, err := GetStandupByName(teamID, DefaultStandupName)
+	if err == nil {
+		return standup, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return CreateStandup(teamID, DefaultStandupName, timezone)
+}
+
+func GetStandupsForTeam(teamID string) ([]Standup, error) {
+	var standups []Standup
+	err := DB.Where("team_id = ?", teamID).Order("name").Find(&standups).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetStandupsForTeam: failed for team %s: %w", teamID, err)
+	}
+	return standups, nil
+}
+
+func GetAllStandups() ([]Standup, error) {
+	var standups []Standup
+	if err := DB.Find(&standups).Error; err != nil {
+		return nil, fmt.Errorf("GetAllStandups: %w", err)
+	}
+	return standups, nil
+}
+
+// GetStandupsForUser returns the standups the user is a participant of,
+// oldest first.
+func GetStandupsForUser(teamID, userID string) ([]Standup, error) {
+	var standups []Standup
+	err := DB.Joins("JOIN prompt_users ON prompt_users.standup_id = standups.id").
+		Where("prompt_users.team_id = ? AND prompt_users.user_id = ?", teamID, userID).
+		Order("standups.id").
+		Find(&standups).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetStandupsForUser: failed for team %s, user %s: %w", teamID, userID, err)
+	}
+	return standups, nil
+}
+
+func DeleteStandup(standup *Standup) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("standup_id = ?", standup.ID).Delete(&PromptUser{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("standup_id = ?", standup.ID).Delete(&UserMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("standup_id = ?", standup.ID).Delete(&StandupRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(standup).Error
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteStandup: failed for standup %d: %w", standup.ID, err)
+	}
+	return nil
+}
+
+func UpdateChannelID(standupID uint, channelID string) error {
+	return updateStandup(standupID, "channel_id", channelID)
+}
+
+func UpdatePostTime(standupID uint, postTime string) error {
+	return updateStandup(standupID, "post_time", postTime)
+}
+
+func UpdatePromptTime(standupID uint, promptTime string) error {
+	return updateStandup(standupID, "prompt_time", promptTime)
+}
+
+func UpdateTimezone(standupID uint, timezone string) error {
+	return updateStandup(standupID, "timezone", timezone)
+}
+
+func UpdateQuestions(standupID uint, questions []string) error {
+	return updateStandup(standupID, "questions", strings.Join(questions, "\n"))
+}
+
+func UpdateSyncSource(standupID uint, source, syncID string) error {
+	now := time.Now().UTC()
+	err := DB.Model(&Standup{}).
+		Where("id = ?", standupID).
+		Updates(map[string]any{
+			"sync_source": source,
+			"sync_id":     syncID,
+			"updated_at":  now,
+		}).Error
+
+	if err != nil {
+		return fmt.Errorf("UpdateSyncSource: failed for standup %d: %w", standupID, err)
+	}
+	return nil
+}
+
+// GetStandupsSyncedTo returns the team's standups whose participants follow
+// the given channel or user group.
+func GetStandupsSyncedTo(teamID, source, syncID string) ([]Standup, error) {
+	var standups []Standup
+	err := DB.Where("team_id = ? AND sync_source = ? AND sync_id = ?", teamID, source, syncID).Find(&standups).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetStandupsSyncedTo: failed for team %s, %s %s: %w", teamID, source, syncID, err)
+	}
+	return standups, nil
+}
+
+func GetSyncedStandups() ([]Standup, error) {
+	var standups []Standup
+	if err := DB.Where("sync_source <> ''").Find(&standups).Error; err != nil {
+		return nil, fmt.Errorf("GetSyncedStandups: %w", err)
+	}
+	return standups, nil
+}
+
+func updateStandup(standupID uint, column, value string) error {
+	now := time.Now().UTC()
+	err := DB.Model(&Standup{}).
+		Where("id = ?", standupID).
+		Updates(map[string]any{
+			column:       value,
+			"updated_at": now,
+		}).Error
+
+	if err != nil {
+		return fmt.Errorf("updateStandup: failed to set %s for standup %d: %w", column, standupID, err)
+	}
+	return nil
+}
+
+// migrateDefaultStandups moves the single-schedule configuration stored on
+// team_configs into a default standup for teams that don't have one yet,
+// and attaches their existing participants and messages to it.
+func migrateDefaultStandups(tx *gorm.DB) error {
+	var teams []struct {
+		TeamID, ChannelID, PostTime, PromptTime, Timezone string
+	}
+	err := tx.Table("team_configs").
+		Select("team_id, channel_id, post_time, prompt_time, timezone").
+		Where("NOT EXISTS (SELECT 1 FROM standups WHERE standups.team_id = team_configs.team_id)").
+		Scan(&teams).Error
+	if err != nil {
+		return fmt.Errorf("migrateDefaultStandups: failed to list teams: %w", err)
+	}
+
+	for _, team := range teams {
+		now := time.Now().UTC()
+		standup := Standup{
+			TeamID:     team.TeamID,
+			Name:       DefaultStandupName,
+			ChannelID:  team.ChannelID,
+			PostTime:   team.PostTime,
+			PromptTime: team.PromptTime,
+			Timezone:   team.Timezone,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if err := tx.Create(&standup).Error; err != nil {
+			return fmt.Errorf("migrateDefaultStandups: failed for team %s: %w", team.TeamID, err)
+		}
+		for _, model := range []any{&PromptUser{}, &UserMessage{}} {
+			err := tx.Model(model).
+				Where("team_id = ? AND (standup_id IS NULL OR standup_id = 0)", team.TeamID).
+				Update("standup_id", standup.ID).Error
+			if err != nil {
+				return fmt.Errorf("migrateDefaultStandups: failed for team %s: %w", team.TeamID, err)
+			}
+		}
+	}
+	return nil
+}
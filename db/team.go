@@ -2,6 +2,7 @@ package db
 
 import (
 	"MidayBrief/utils"
+	"errors"
 	"fmt"
 	"time"
 
@@ -14,15 +15,37 @@ func SaveTeamConfig(team TeamConfig) error {
 	team.UpdatedAt = now
 
 	var existing TeamConfig
-	result := DB.Where("team_id = ?", team.TeamID).First(&existing)
+	result := rawDB().Where("team_id = ?", team.TeamID).First(&existing)
 	if result.Error == gorm.ErrRecordNotFound {
 		team.CreatedAt = now
 	}
 
-	err := DB.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "team_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"access_token", "bot_user_id", "updated_at", "admin_user_id"}),
-	}).Create(&team).Error
+	team.IsActive = true
+	team.DeactivatedAt = nil
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "team_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"access_token", "refresh_token", "token_expires_at", "bot_user_id", "updated_at", "installer_user_id", "is_active", "deactivated_at", "enterprise_id", "is_enterprise_install"}),
+		}).Create(&team).Error
+		if err != nil {
+			return err
+		}
+
+		// Reinstalling an org reactivates the workspaces DeactivateEnterprise
+		// turned off with it: those it still covers, uninstalled at the same
+		// moment.
+		if !team.IsEnterpriseInstall || result.Error != nil || existing.IsActive || existing.DeactivatedAt == nil {
+			return nil
+		}
+		return tx.Model(&TeamConfig{}).
+			Where("enterprise_id = ? AND access_token = '' AND is_active = ? AND deactivated_at = ?", team.TeamID, false, *existing.DeactivatedAt).
+			Updates(map[string]any{
+				"is_active":      true,
+				"deactivated_at": nil,
+				"updated_at":     now,
+			}).Error
+	})
 
 	if err != nil {
 		return fmt.Errorf("SaveTeamConfig: failed to save team %s: %w", team.TeamID, err)
@@ -30,83 +53,254 @@ func SaveTeamConfig(team TeamConfig) error {
 	return nil
 }
 
+// GetAllTeamConfigs returns every team, active or not. Teams whose tokens
+// couldn't be decrypted are included with DecryptErr set.
 func GetAllTeamConfigs() ([]TeamConfig, error) {
 	var teams []TeamConfig
-	err := DB.Find(&teams).Error
-	if len(teams) > 0 {
-		for _, team := range teams {
-			team.AccessToken, _ = utils.Decrypt(team.AccessToken)
+	if err := DB.Order("id").Find(&teams).Error; err != nil {
+		return nil, fmt.Errorf("GetAllTeamConfigs: %w", err)
+	}
+	for i := range teams {
+		loadTokens(&teams[i])
+	}
+	return teams, nil
+}
+
+// GetActiveTeamConfigs returns the active teams whose tokens could be
+// decrypted; the others are logged and counted by the decryption hook.
+func GetActiveTeamConfigs() ([]TeamConfig, error) {
+	var teams []TeamConfig
+	if err := DB.Where("is_active = ?", true).Find(&teams).Error; err != nil {
+		return nil, fmt.Errorf("GetActiveTeamConfigs: %w", err)
+	}
+
+	usable := teams[:0]
+	for _, team := range teams {
+		if err := loadTokens(&team); err == nil {
+			usable = append(usable, team)
 		}
 	}
-	return teams, err
+	return usable, nil
 }
 
+// GetTeamConfig returns a *DecryptError if the team's tokens can't be
+// decrypted.
 func GetTeamConfig(teamID string) (*TeamConfig, error) {
 	var team TeamConfig
 	err := DB.Where("team_id = ?", teamID).First(&team).Error
 	if err != nil {
 		return nil, fmt.Errorf("GetTeamConfig: failed to retrieve team %s: %w", teamID, err)
 	}
-	team.AccessToken, _ = utils.Decrypt(team.AccessToken)
+	if err := loadTokens(&team); err != nil {
+		return nil, fmt.Errorf("GetTeamConfig: team %s: %w", teamID, err)
+	}
 	return &team, nil
 }
 
-func UpdateChannelID(teamID, channelID string) error {
+// loadTokens takes the tokens from the org's installation record for
+// workspaces covered by an org-wide install, and reports tokens that
+// couldn't be decrypted.
+func loadTokens(team *TeamConfig) error {
+	team.TokenTeamID = team.TeamID
+	if team.EnterpriseID != "" && !team.IsEnterpriseInstall && team.AccessToken == "" && team.DecryptErr == nil {
+		var org TeamConfig
+		err := DB.Where("team_id = ? AND is_enterprise_install = ?", team.EnterpriseID, true).First(&org).Error
+		if err != nil {
+			return nil
+		}
+		team.TokenTeamID = org.TeamID
+		team.AccessToken, team.RefreshToken, team.TokenExpiresAt = org.AccessToken, org.RefreshToken, org.TokenExpiresAt
+		team.BotUserID = org.BotUserID
+		team.IsActive = team.IsActive && org.IsActive
+		team.DecryptErr = org.DecryptErr
+	}
+	if team.DecryptErr != nil {
+		return team.DecryptErr
+	}
+	return nil
+}
+
+// GetOrCreateWorkspaceConfig returns the configuration for a workspace. For
+// a workspace that is part of an org-wide install but hasn't been seen
+// before, it creates one that shares the org's token.
+func GetOrCreateWorkspaceConfig(enterpriseID, teamID string) (*TeamConfig, error) {
+	team, err := GetTeamConfig(teamID)
+	if err == nil || enterpriseID == "" || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return team, err
+	}
+
+	var org TeamConfig
+	err = rawDB().Where("team_id = ? AND is_enterprise_install = ?", enterpriseID, true).First(&org).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetOrCreateWorkspaceConfig: no installation for team %s in enterprise %s: %w", teamID, enterpriseID, err)
+	}
+	if !org.IsActive {
+		return nil, fmt.Errorf("GetOrCreateWorkspaceConfig: installation for enterprise %s is inactive", enterpriseID)
+	}
+
+	now := time.Now().UTC()
+	workspace := TeamConfig{
+		TeamID:          teamID,
+		EnterpriseID:    enterpriseID,
+		InstallerUserID: org.InstallerUserID,
+		Timezone:        org.Timezone,
+		IsActive:        true,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&workspace).Error; err != nil {
+		return nil, fmt.Errorf("GetOrCreateWorkspaceConfig: failed to create team %s: %w", teamID, err)
+	}
+	return GetTeamConfig(teamID)
+}
+
+// UpdateUserFilter sets one of the include_guests, include_bots or
+// include_app_users flags.
+func UpdateUserFilter(teamID, column string, include bool) error {
 	now := time.Now().UTC()
 	err := DB.Model(&TeamConfig{}).
 		Where("team_id = ?", teamID).
 		Updates(map[string]any{
-			"channel_id": channelID,
+			column:       include,
 			"updated_at": now,
 		}).Error
 
 	if err != nil {
-		return fmt.Errorf("UpdateChannelID: failed for team %s: %w", teamID, err)
+		return fmt.Errorf("UpdateUserFilter: failed to set %s for team %s: %w", column, teamID, err)
 	}
 	return nil
 }
 
-func UpdatePostTime(teamID, postTime string) error {
+// UpdateTeamTokens stores a rotated token pair; both tokens must already be
+// encrypted.
+func UpdateTeamTokens(teamID, accessToken, refreshToken string, expiresAt *time.Time) error {
 	now := time.Now().UTC()
 	err := DB.Model(&TeamConfig{}).
 		Where("team_id = ?", teamID).
 		Updates(map[string]any{
-			"post_time":  postTime,
-			"updated_at": now,
+			"access_token":     accessToken,
+			"refresh_token":    refreshToken,
+			"token_expires_at": expiresAt,
+			"updated_at":       now,
 		}).Error
 
 	if err != nil {
-		return fmt.Errorf("UpdatePostTime: failed for team %s: %w", teamID, err)
+		return fmt.Errorf("UpdateTeamTokens: failed for team %s: %w", teamID, err)
 	}
 	return nil
 }
 
-func UpdatePromptTime(teamID, postTime string) error {
+// GetTeamsWithTokensExpiringBefore returns active teams using token rotation
+// whose access token expires before the cutoff.
+func GetTeamsWithTokensExpiringBefore(cutoff time.Time) ([]TeamConfig, error) {
+	var teams []TeamConfig
+	err := DB.Where("is_active = ? AND refresh_token <> '' AND token_expires_at < ?", true, cutoff).Find(&teams).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetTeamsWithTokensExpiringBefore: %w", err)
+	}
+	return teams, nil
+}
+
+// DeactivateTeam marks the team as uninstalled and drops its access token,
+// which Slack no longer accepts.
+func DeactivateTeam(teamID string) error {
 	now := time.Now().UTC()
 	err := DB.Model(&TeamConfig{}).
 		Where("team_id = ?", teamID).
 		Updates(map[string]any{
-			"prompt_time": postTime,
-			"updated_at":  now,
+			"is_active":      false,
+			"access_token":   "",
+			"refresh_token":  "",
+			"deactivated_at": now,
+			"updated_at":     now,
 		}).Error
 
 	if err != nil {
-		return fmt.Errorf("UpdatePromptTime: failed for team %s: %w", teamID, err)
+		return fmt.Errorf("DeactivateTeam: failed for team %s: %w", teamID, err)
 	}
 	return nil
 }
 
-func UpdateTimezone(teamID, timezone string) error {
+// DeactivateEnterprise deactivates an org-wide install and every workspace
+// relying on its token.
+func DeactivateEnterprise(enterpriseID string) error {
 	now := time.Now().UTC()
 	err := DB.Model(&TeamConfig{}).
-		Where("team_id = ?", teamID).
+		Where("team_id = ? OR (enterprise_id = ? AND access_token = '')", enterpriseID, enterpriseID).
 		Updates(map[string]any{
-			"timezone":   timezone,
-			"updated_at": now,
+			"is_active":      false,
+			"access_token":   "",
+			"refresh_token":  "",
+			"deactivated_at": now,
+			"updated_at":     now,
 		}).Error
 
 	if err != nil {
-		return fmt.Errorf("UpdateTimezone: failed for team %s: %w", teamID, err)
+		return fmt.Errorf("DeactivateEnterprise: failed for enterprise %s: %w", enterpriseID, err)
 	}
 	return nil
 }
+
+// GetTeamsDeactivatedBefore returns inactive teams whose data is due for
+// deletion.
+func GetTeamsDeactivatedBefore(cutoff time.Time) ([]TeamConfig, error) {
+	var teams []TeamConfig
+	err := DB.Where("is_active = ? AND deactivated_at < ?", false, cutoff).Find(&teams).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetTeamsDeactivatedBefore: %w", err)
+	}
+	return teams, nil
+}
+
+// PurgeTeam deletes everything stored for the team, crypto-shredding any
+// copies left in backups by deleting its data key.
+func PurgeTeam(teamID string) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []any{&UserMessage{}, &StandupRun{}, &PromptUser{}, &Standup{}, &TeamAdmin{}, &AuditEvent{}, &TeamDataKey{}, &APIKey{}, &TeamConfig{}} {
+			if err := tx.Where("team_id = ?", teamID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("PurgeTeam: failed for team %s: %w", teamID, err)
+	}
+	utils.ForgetTeamDataKey(teamID)
+	return nil
+}
+
+// PurgeEnterprise deletes an org-wide install's record and the data of the
+// workspaces that relied on it.
+func PurgeEnterprise(enterpriseID string) error {
+	var teamIDs []string
+	err := DB.Model(&TeamConfig{}).
+		Where("enterprise_id = ? AND is_active = ? AND team_id <> ?", enterpriseID, false, enterpriseID).
+		Pluck("team_id", &teamIDs).Error
+	if err != nil {
+		return fmt.Errorf("PurgeEnterprise: failed to list teams of enterprise %s: %w", enterpriseID, err)
+	}
+
+	for _, teamID := range append(teamIDs, enterpriseID) {
+		if err := PurgeTeam(teamID); err != nil {
+			return fmt.Errorf("PurgeEnterprise: %w", err)
+		}
+	}
+	return nil
+}
+
+// retentionPeriod is how long a deactivated team's data is kept before it
+// is purged; see SetRetentionPeriod.
+var retentionPeriod = 30 * 24 * time.Hour
+
+// SetRetentionPeriod replaces the retention period, configured in days by
+// DATA_RETENTION_DAYS.
+func SetRetentionPeriod(d time.Duration) {
+	retentionPeriod = d
+}
+
+// RetentionPeriod is how long a deactivated team's data is kept before it is
+// purged.
+func RetentionPeriod() time.Duration {
+	return retentionPeriod
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"MidayBrief/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB returns a traced database handle that builds statements without
+// running them, so no server is needed.
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registerTracing(conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// recordSpans installs a tracer provider that keeps every ended span.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestTracingNestsQuerySpans(t *testing.T) {
+	recorder := recordSpans(t)
+	conn := dryRunDB(t)
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	var standups []Standup
+	conn.WithContext(ctx).Where("team_id = ?", "T1").Find(&standups)
+	conn.WithContext(ctx).Create(42)
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	query, create := spans[0], spans[1]
+	if query.Name() != "db.query" || create.Name() != "db.create" {
+		t.Fatalf("got spans %q and %q, want db.query and db.create", query.Name(), create.Name())
+	}
+	for _, span := range []sdktrace.ReadOnlySpan{query, create} {
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %q isn't a child of the caller's span", span.Name())
+		}
+	}
+
+	if query.Status().Code != codes.Unset {
+		t.Errorf("query span has status %v, want unset", query.Status().Code)
+	}
+	var statement string
+	for _, attr := range query.Attributes() {
+		if attr.Key == "db.statement" {
+			statement = attr.Value.AsString()
+		}
+	}
+	if want := `SELECT * FROM "standups" WHERE team_id = $1`; statement != want {
+		t.Errorf("db.statement = %q, want %q", statement, want)
+	}
+	if create.Status().Code != codes.Error {
+		t.Errorf("failed create span has status %v, want error", create.Status().Code)
+	}
+}
+
+func TestTracingStartsRootSpansWithoutContext(t *testing.T) {
+	recorder := recordSpans(t)
+	conn := dryRunDB(t)
+
+	var teams []TeamConfig
+	conn.Find(&teams)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Parent().IsValid() {
+		t.Fatalf("want one root span, got %d spans", len(spans))
+	}
+}
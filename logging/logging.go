@@ -0,0 +1,144 @@
+// Package logging configures the process-wide slog logger and carries the
+// IDs that correlate log lines (team, Slack event and scheduler job) in
+// contexts. Log with the slog.*Context functions so they are included.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Init installs the default logger, writing lines of level and above to
+// stderr as text, or JSON when format is "json".
+func Init(level slog.Leveler, format string) {
+	slog.SetDefault(slog.New(NewHandler(os.Stderr, format, level)))
+}
+
+// NewHandler returns a handler writing text, or JSON when format is "json",
+// that adds the correlation IDs from the context and redacts secrets.
+func NewHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	if strings.EqualFold(format, "json") {
+		return contextHandler{slog.NewJSONHandler(w, opts)}
+	}
+	return contextHandler{slog.NewTextHandler(w, opts)}
+}
+
+// Fatal logs msg at error level and exits.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type contextKey int
+
+const (
+	teamIDKey contextKey = iota
+	eventIDKey
+	jobKey
+	jobIDKey
+)
+
+// WithTeam returns a context whose log lines carry team_id.
+func WithTeam(ctx context.Context, teamID string) context.Context {
+	return context.WithValue(ctx, teamIDKey, teamID)
+}
+
+// WithEvent returns a context whose log lines carry the Slack event_id.
+func WithEvent(ctx context.Context, eventID string) context.Context {
+	return context.WithValue(ctx, eventIDKey, eventID)
+}
+
+// WithJob returns a context whose log lines carry the job name and a new
+// job_id, so the lines of one scheduler run can be told apart.
+func WithJob(ctx context.Context, job string) context.Context {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	ctx = context.WithValue(ctx, jobKey, job)
+	return context.WithValue(ctx, jobIDKey, hex.EncodeToString(buf))
+}
+
+// contextHandler adds the correlation IDs carried by the record's context,
+// including the trace and span IDs when tracing is enabled.
+type contextHandler struct {
+	slog.Handler
+}
+
+var contextAttrs = []struct {
+	key  contextKey
+	name string
+}{
+	{teamIDKey, "team_id"},
+	{eventIDKey, "event_id"},
+	{jobKey, "job"},
+	{jobIDKey, "job_id"},
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	for _, attr := range contextAttrs {
+		if value, ok := ctx.Value(attr.key).(string); ok && value != "" {
+			r.AddAttrs(slog.String(attr.name, value))
+		}
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// redactedKeys are attributes whose values are never logged: credentials
+// and the text of users' messages.
+var redactedKeys = map[string]bool{
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"client_secret": true,
+	"secret":        true,
+	"authorization": true,
+	"code":          true,
+	"text":          true,
+	"message":       true,
+	"body":          true,
+}
+
+// secretPattern matches Slack tokens (xoxb-, xoxp-, xoxe.xoxp-, ...) and
+// admin API keys that end up inside error strings or messages.
+var secretPattern = regexp.MustCompile(`xox[a-z]?(?:\.xox[a-z])?-[A-Za-z0-9-]+|mbk_[0-9a-f]+`)
+
+const redacted = "[REDACTED]"
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if s := a.Value.String(); secretPattern.MatchString(s) {
+			return slog.String(a.Key, secretPattern.ReplaceAllString(s, redacted))
+		}
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			if s := err.Error(); secretPattern.MatchString(s) {
+				return slog.String(a.Key, secretPattern.ReplaceAllString(s, redacted))
+			}
+		}
+	}
+	return a
+}
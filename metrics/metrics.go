@@ -0,0 +1,148 @@
+// Package metrics defines the Prometheus metrics MidayBrief exposes at
+// /metrics.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "midaybrief"
+
+var (
+	EventsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_events_received_total",
+		Help:      "Inbound Slack events by event type.",
+	}, []string{"type"})
+
+	SlackRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_api_requests_total",
+		Help:      "Slack Web API requests by method and status (ok, a Slack error code, rate_limited, http_<code> or error).",
+	}, []string{"method", "status"})
+
+	SlackRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "slack_api_request_duration_seconds",
+		Help:      "Latency of Slack Web API requests by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	PromptsSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "prompts_sent_total",
+		Help:      "Standup prompts sent to users.",
+	})
+
+	SubmissionsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "submissions_received_total",
+		Help:      "Standup updates saved, by source (message or prompt).",
+	}, []string{"source"})
+
+	SummariesPosted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "summaries_posted_total",
+		Help:      "Standup summaries by result (posted, empty or failed).",
+	}, []string{"result"})
+
+	SchedulerTickDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scheduler_tick_duration_seconds",
+		Help:      "Time spent processing a scheduler tick.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	SchedulerTickLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scheduler_tick_lag_seconds",
+		Help:      "Delay between a scheduler tick firing and its processing starting.",
+		Buckets:   []float64{.001, .01, .1, .5, 1, 5, 15, 30, 60},
+	})
+
+	StorageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_errors_total",
+		Help:      "Failed Postgres and Redis operations by backend and operation.",
+	}, []string{"backend", "operation"})
+
+	DecryptionFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "decryption_failures_total",
+		Help:      "Stored values that couldn't be decrypted, by table and column.",
+	}, []string{"table", "column"})
+
+	participation = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "standup_participation_ratio",
+		Help:      "Share of prompted users who submitted an update for the team's last summarized standup. Teams beyond METRICS_MAX_TEAMS are reported as \"other\".",
+	}, []string{"team_id"})
+)
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveSlackRequest records one Slack Web API request; it is passed to
+// the Slack client as its observer.
+func ObserveSlackRequest(method, status string, duration time.Duration) {
+	SlackRequests.WithLabelValues(method, status).Inc()
+	SlackRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+var (
+	teamsMu    sync.Mutex
+	teamLabels = make(map[string]bool)
+	maxTeams   = 50
+)
+
+// SetMaxTeams bounds how many teams get their own series, configured by
+// METRICS_MAX_TEAMS.
+func SetMaxTeams(n int) {
+	teamsMu.Lock()
+	defer teamsMu.Unlock()
+	maxTeams = n
+}
+
+// teamLabel returns the label value used for teamID. The first maxTeams
+// teams seen keep their ID; later ones share "other", and a maximum of 0
+// reports every team as "other".
+func teamLabel(teamID string) string {
+	teamsMu.Lock()
+	defer teamsMu.Unlock()
+
+	if teamLabels[teamID] {
+		return teamID
+	}
+	if len(teamLabels) < maxTeams {
+		teamLabels[teamID] = true
+		return teamID
+	}
+	return "other"
+}
+
+// SetParticipation records the share of prompted users of teamID who
+// submitted an update.
+func SetParticipation(teamID string, submitted, prompted int) {
+	if prompted == 0 {
+		return
+	}
+	participation.WithLabelValues(teamLabel(teamID)).Set(float64(submitted) / float64(prompted))
+}
+
+// ForgetTeam drops the team's series, e.g. after it uninstalls the app.
+func ForgetTeam(teamID string) {
+	teamsMu.Lock()
+	defer teamsMu.Unlock()
+	if teamLabels[teamID] {
+		delete(teamLabels, teamID)
+		participation.DeleteLabelValues(teamID)
+	}
+}
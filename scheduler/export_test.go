@@ -0,0 +1,3 @@
+package scheduler
+
+var ReconcileParticipants = reconcileParticipants
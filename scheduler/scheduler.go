@@ -3,126 +3,571 @@ package scheduler
 import (
 	"MidayBrief/api"
 	"MidayBrief/db"
+	"MidayBrief/logging"
+	"MidayBrief/metrics"
+	"MidayBrief/store"
+	"MidayBrief/tracing"
 	"MidayBrief/utils"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const promptMessage = "Good day! 👋\n\nHope you're doing well. Let's kick off your daily standup.\n\n🕐 First up — *What did you work on yesterday?*\nFeel free to share key highlights or any progress you made."
+const promptMessage = "Good day! 👋\n\nHope you're doing well. Let's kick off your *%s* standup.\n\n🕐 First up — *%s*"
+
+// maintenanceInterval is how often synced standups are fully re-read from
+// Slack, catching membership events that were missed, uninstalled teams
+// past their retention period are purged, history past its retention period
+// is pruned, and data encrypted with a retired key is re-encrypted.
+const maintenanceInterval = 1 * time.Hour
+
+// tokenRefreshInterval is how often rotating Slack tokens are checked and
+// refreshed ahead of expiry.
+const tokenRefreshInterval = 10 * time.Minute
+
+// promptUserTimeout bounds prompting one participant, including waiting
+// out Slack rate limits.
+const promptUserTimeout = 30 * time.Second
+
+// tickInterval is how often standups are checked for prompts and summaries
+// due.
+const tickInterval = 1 * time.Minute
+
+// heartbeatTimeout is how old the last tick may be before CheckHeartbeat
+// reports the scheduler stuck.
+const heartbeatTimeout = 3 * tickInterval
+
+// lastTick holds the Unix nanoseconds of the last completed tick, or of the
+// start; zero means the scheduler hasn't started.
+var lastTick atomic.Int64
+
+// CheckHeartbeat reports an error unless the scheduler is running and ticked
+// recently.
+func CheckHeartbeat(ctx context.Context) error {
+	last := lastTick.Load()
+	if last == 0 {
+		return errors.New("scheduler not started")
+	}
+	if age := time.Since(time.Unix(0, last)); age > heartbeatTimeout {
+		return fmt.Errorf("last scheduler tick was %s ago", age.Round(time.Second))
+	}
+	return nil
+}
+
+// stores holds the data the scheduler reads; see SetStores.
+var stores = store.Default()
+
+// SetStores replaces the storage used by scheduled jobs.
+func SetStores(s *store.Stores) {
+	stores = s
+}
+
+// jobs tracks the jobs running in the background so Drain can wait for
+// them.
+var jobs sync.WaitGroup
+
+// jobsCtx is the context jobs run with. It outlives the scheduler so jobs
+// already running can finish after StartScheduler returns; abortJobs cancels
+// it when Drain gives up waiting.
+var jobsCtx, abortJobs = context.WithCancel(context.Background())
+
+// goJob runs job in the background with ctx, which must derive from jobsCtx.
+func goJob(ctx context.Context, job func(context.Context)) {
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		job(ctx)
+	}()
+}
 
-func StartScheduler() {
-	ticker := time.NewTicker(1 * time.Minute)
+// StartScheduler runs scheduled jobs until ctx is cancelled. Jobs already
+// running are not stopped; call Drain once it returns to wait for them.
+func StartScheduler(ctx context.Context) {
+	ticker := time.NewTicker(tickInterval)
 	defer ticker.Stop()
 
-	log.Println("Scheduler started...")
+	maintenanceTicker := time.NewTicker(maintenanceInterval)
+	defer maintenanceTicker.Stop()
 
-	for now := range ticker.C {
-		processSchedule(now)
+	tokenTicker := time.NewTicker(tokenRefreshInterval)
+	defer tokenTicker.Stop()
+
+	lastTick.Store(time.Now().UnixNano())
+	slog.Info("Scheduler started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Scheduler stopped")
+			return
+		case now := <-ticker.C:
+			start := time.Now()
+			metrics.SchedulerTickLag.Observe(start.Sub(now).Seconds())
+			processSchedule(jobsCtx, now)
+			metrics.SchedulerTickDuration.Observe(time.Since(start).Seconds())
+			lastTick.Store(time.Now().UnixNano())
+		case <-maintenanceTicker.C:
+			goJob(jobsCtx, reconcileParticipants)
+			goJob(jobsCtx, purgeDeactivatedTeams)
+			goJob(jobsCtx, pruneHistory)
+			goJob(jobsCtx, reencryptStaleData)
+		case <-tokenTicker.C:
+			goJob(jobsCtx, refreshExpiringTokens)
+		}
 	}
 }
 
-func processSchedule(now time.Time) {
-	teams, err := db.GetAllTeamConfigs()
+// Drain waits for the jobs still running after StartScheduler returned. If
+// ctx is done first, the jobs are cancelled and ctx's error is returned.
+func Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		abortJobs()
+		return fmt.Errorf("scheduler.Drain: jobs still running: %w", ctx.Err())
+	}
+}
+
+// startJob starts a scheduler job: its log lines share a job ID and its
+// work is traced under one span.
+func startJob(ctx context.Context, job string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = logging.WithJob(ctx, job)
+	return tracing.Start(ctx, "scheduler."+job, trace.WithAttributes(attrs...))
+}
+
+func processSchedule(ctx context.Context, now time.Time) {
+	ctx, span := startJob(ctx, "schedule")
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	teams, err := stores.Teams.GetActiveTeamConfigs()
 	if err != nil {
-		log.Println("Failed to fetch team configs:", err)
+		slog.ErrorContext(ctx, "Failed to fetch team configs", "error", err)
 		return
 	}
 
+	teamsByID := make(map[string]db.TeamConfig, len(teams))
 	for _, team := range teams {
-		if team.PostTime == "" || team.PromptTime == "" || team.Timezone == "" || team.ChannelID == "" {
+		teamsByID[team.TeamID] = team
+	}
+
+	standups, err := stores.Standups.GetAllStandups()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch standups", "error", err)
+		return
+	}
+
+	for _, standup := range standups {
+		if standup.PostTime == "" || standup.PromptTime == "" || standup.Timezone == "" || standup.ChannelID == "" {
+			continue
+		}
+
+		team, ok := teamsByID[standup.TeamID]
+		if !ok {
 			continue
 		}
 
-		loc, err := time.LoadLocation(team.Timezone)
+		teamCtx := logging.WithTeam(ctx, standup.TeamID)
+		loc, err := time.LoadLocation(standup.Timezone)
 		if err != nil {
-			log.Printf("Invalid timezone for team %s: %s\n", team.TeamID, team.Timezone)
+			slog.WarnContext(teamCtx, "Invalid standup timezone", "standup", standup.Name, "timezone", standup.Timezone)
 			continue
 		}
 
 		localTime := now.In(loc).Format("15:04")
 
-		if localTime == team.PromptTime {
-			log.Printf("Triggering prompt for team %s at %s (%s)", team.TeamID, localTime, team.Timezone)
-			go triggerPromptForTeam(team)
+		if localTime == standup.PromptTime {
+			slog.InfoContext(teamCtx, "Triggering prompts", "standup", standup.Name, "local_time", localTime, "timezone", standup.Timezone)
+			goJob(teamCtx, func(ctx context.Context) { TriggerPrompt(ctx, team, standup) })
 		}
 
-		if localTime == team.PostTime {
-			log.Printf("Triggering post summary for team %s at %s (%s)", team.TeamID, localTime, team.Timezone)
-			go postSummaryForTeam(team, loc)
-			if err := db.CleanupMessages(team.TeamID); err != nil {
-				log.Printf("Failed to clean messages for team %s: %v", team.TeamID, err)
-			}
+		if localTime == standup.PostTime {
+			slog.InfoContext(teamCtx, "Triggering summary", "standup", standup.Name, "local_time", localTime, "timezone", standup.Timezone)
+			date := now.In(loc).Format(time.DateOnly)
+			goJob(teamCtx, func(ctx context.Context) { PostSummary(ctx, team, standup, date) })
 		}
 	}
 }
 
-func triggerPromptForTeam(team db.TeamConfig) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+func reconcileParticipants(ctx context.Context) {
+	ctx, span := startJob(ctx, "reconcile")
+	defer span.End()
 
-	users, err := db.GetAllPromptUser(team.TeamID)
+	standups, err := stores.Standups.GetSyncedStandups()
 	if err != nil {
-		log.Printf("Failed to get prompt users for %s: %v", team.TeamID, err)
+		slog.ErrorContext(ctx, "Failed to fetch synced standups", "error", err)
+		span.RecordError(err)
 		return
 	}
 
-	for _, user := range users {
-		state := utils.PromptState{
-			Step:      1,
-			Responses: make(map[string]string),
+	// users.list is paged once per team, however many of its standups sync.
+	var teamIDs []string
+	byTeam := make(map[string][]db.Standup)
+	for _, standup := range standups {
+		if byTeam[standup.TeamID] == nil {
+			teamIDs = append(teamIDs, standup.TeamID)
 		}
+		byTeam[standup.TeamID] = append(byTeam[standup.TeamID], standup)
+	}
 
-		if err := utils.SetPromptState(team.TeamID, user.UserID, state, ctx); err != nil {
-			log.Printf("Failed to set prompt state for user %s: %v", user.UserID, err)
+	for _, teamID := range teamIDs {
+		teamCtx := logging.WithTeam(ctx, teamID)
+		team, err := stores.Teams.GetTeamConfig(teamID)
+		if err != nil {
+			slog.ErrorContext(teamCtx, "Failed to load team for reconcile", "error", err)
 			continue
 		}
-
-		err := api.SendMessage(team.AccessToken, user.UserID, promptMessage)
+		if !team.IsActive {
+			continue
+		}
+		if err := api.EnsureFreshToken(teamCtx, team); err != nil {
+			slog.WarnContext(teamCtx, "Failed to refresh token", "error", err)
+		}
+		eligible, err := api.GetEligibleUsers(teamCtx, team)
 		if err != nil {
-			log.Printf("Failed to send first prompt to user %s: %v", user.UserID, err)
+			slog.ErrorContext(teamCtx, "Failed to fetch users for reconcile", "error", err)
+			continue
+		}
+
+		for _, standup := range byTeam[teamID] {
+			added, removed, err := api.SyncStandupParticipants(teamCtx, team, &standup, eligible)
+			if err != nil {
+				slog.ErrorContext(teamCtx, "Failed to reconcile participants", "standup", standup.Name, "error", err)
+				continue
+			}
+			if added > 0 || removed > 0 {
+				slog.InfoContext(teamCtx, "Reconciled participants", "standup", standup.Name, "added", added, "removed", removed)
+			}
 		}
 	}
 }
 
-func postSummaryForTeam(team db.TeamConfig, location *time.Location) {
-	if team.AccessToken == "" || team.ChannelID == "" {
-		log.Printf("PostSummaryForTeam: missing credentials for team %s", team.TeamID)
+// purgeDeactivatedTeams deletes the data of teams that were uninstalled more
+// than the retention period ago.
+func purgeDeactivatedTeams(ctx context.Context) {
+	ctx, span := startJob(ctx, "purge")
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	teams, err := stores.Teams.GetTeamsDeactivatedBefore(time.Now().UTC().Add(-db.RetentionPeriod()))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch deactivated teams", "error", err)
 		return
 	}
 
-	messages, err := db.GetMessagesForTeamToday(team.TeamID, location)
-	if err != nil {
-		log.Printf("PostSummaryForTeam: error fetching messages for team %s: %v", team.TeamID, err)
+	for _, team := range teams {
+		teamCtx := logging.WithTeam(ctx, team.TeamID)
+		if err := stores.Teams.PurgeTeam(team.TeamID); err != nil {
+			slog.ErrorContext(teamCtx, "Failed to purge team", "error", err)
+			continue
+		}
+		slog.InfoContext(teamCtx, "Purged data for deactivated team")
+	}
+}
+
+// pruneHistory deletes summarized updates and runs older than the history
+// retention period, unless history is kept forever.
+func pruneHistory(ctx context.Context) {
+	if db.HistoryRetention() == 0 {
 		return
 	}
+	ctx, span := startJob(ctx, "prune_history")
+	deleted, err := stores.Submissions.PruneHistory(time.Now().UTC().Add(-db.HistoryRetention()))
+	span.SetAttributes(attribute.Int64("deleted", deleted))
+	defer tracing.End(span, err)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to prune history", "error", err)
+	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, "Pruned history", "deleted", deleted)
+	}
+}
+
+// reencryptBatchSize bounds how many rows a re-encryption pass loads at
+// once.
+const reencryptBatchSize = 500
+
+// reencryptStaleData moves tokens, messages and message hashes still using
+// a retired key to the current one, so old keys can eventually be removed.
+func reencryptStaleData(ctx context.Context) {
+	ctx, span := startJob(ctx, "reencrypt")
+	result, err := stores.Crypto.ReencryptStaleCiphertexts(reencryptBatchSize)
+	span.SetAttributes(attribute.Int("reencrypted", result.Reencrypted), attribute.Int("rewrapped", result.Rewrapped),
+		attribute.Int("rehashed", result.Rehashed), attribute.Int("failed", result.Failed))
+	defer tracing.End(span, err)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to re-encrypt stale data", "error", err)
+	}
+	if result.Reencrypted > 0 || result.Rewrapped > 0 || result.Rehashed > 0 || result.Failed > 0 {
+		slog.InfoContext(ctx, "Re-encrypted stale data", "reencrypted", result.Reencrypted, "rewrapped", result.Rewrapped,
+			"rehashed", result.Rehashed, "failed", result.Failed)
+	}
+}
+
+// refreshExpiringTokens runs api.RefreshExpiringTokens as a job.
+func refreshExpiringTokens(ctx context.Context) {
+	ctx, span := startJob(ctx, "token_refresh")
+	defer span.End()
+	api.RefreshExpiringTokens(ctx)
+}
+
+// TriggerPrompt sends the standup's first question to its participants,
+// as happens at its prompt time, and reports how many were prompted.
+// Participants already answering another standup are skipped.
+func TriggerPrompt(ctx context.Context, team db.TeamConfig, standup db.Standup) (prompted int, err error) {
+	ctx, span := startJob(ctx, "prompt", attribute.String("team_id", team.TeamID), attribute.String("standup", standup.Name))
+	defer span.End()
+
+	users, err := stores.Participants.GetAllPromptUser(standup.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get prompt users", "standup", standup.Name, "error", err)
+		tracing.End(span, err)
+		return 0, fmt.Errorf("TriggerPrompt: failed to get participants of standup %s: %w", standup.Name, err)
+	}
+
+	text := fmt.Sprintf(promptMessage, standup.Name, standup.QuestionList()[0])
+	for _, user := range users {
+		if promptUser(ctx, &team, standup.ID, user.UserID, text) {
+			metrics.PromptsSent.Inc()
+			prompted++
+		}
+	}
+	span.SetAttributes(attribute.Int("prompted", prompted), attribute.Int("participants", len(users)))
+	return prompted, nil
+}
+
+// promptUser starts the standup for one participant and reports whether
+// the prompt was sent. Its Redis and Slack calls get promptUserTimeout of
+// their own, so a slow or rate-limited participant doesn't use up the time
+// of those after them.
+func promptUser(ctx context.Context, team *db.TeamConfig, standupID uint, userID, text string) bool {
+	ctx, cancel := context.WithTimeout(ctx, promptUserTimeout)
+	defer cancel()
+
+	if existing, err := stores.State.GetPromptState(team.TeamID, userID, ctx); err == nil && existing != nil && existing.StandupID != standupID {
+		slog.InfoContext(ctx, "Skipping prompt; user is already answering another standup", "user_id", userID, "standup_id", existing.StandupID)
+		return false
+	}
+
+	state := utils.PromptState{
+		StandupID: standupID,
+		Step:      1,
+		Responses: make(map[string]string),
+	}
+	if err := stores.State.SetPromptState(team.TeamID, userID, state, ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to set prompt state", "user_id", userID, "error", err)
+		return false
+	}
+
+	if err := api.SendTeamMessage(ctx, team, userID, text); err != nil {
+		slog.ErrorContext(ctx, "Failed to send first prompt", "user_id", userID, "error", err)
+		trace.SpanFromContext(ctx).RecordError(err)
+		return false
+	}
+	return true
+}
+
+// PostSummary posts the standup's summary for date, a YYYY-MM-DD day in
+// its timezone, and records the run. The summary covers the updates
+// already summarized on date, so a failed or past summary can be posted
+// again, and when date is today, the updates not yet summarized, which are
+// then archived under date.
+func PostSummary(ctx context.Context, team db.TeamConfig, standup db.Standup, date string) (run db.StandupRun, err error) {
+	ctx, span := startJob(ctx, "summary", attribute.String("team_id", team.TeamID), attribute.String("standup", standup.Name),
+		attribute.String("date", date))
+	defer func() { tracing.End(span, err) }()
+
+	run = db.StandupRun{
+		TeamID:    team.TeamID,
+		StandupID: standup.ID,
+		Date:      date,
+		Status:    db.RunStatusFailed,
+	}
+
+	// The run is recorded and the updates moved into the history once the
+	// summary has been attempted, not before, so they're never archived
+	// while still being read. Nothing is archived if today can't be told
+	// from the standup's timezone.
+	var today bool
+	defer func() {
+		if err != nil {
+			run.Error = err.Error()
+		}
+		if err := stores.Submissions.SaveStandupRun(&run); err != nil {
+			slog.ErrorContext(ctx, "Failed to record summary run", "standup", standup.Name, "error", err)
+		}
+		if !today {
+			return
+		}
+		if err := stores.Submissions.ArchiveMessages(standup.ID, run.Date); err != nil {
+			slog.ErrorContext(ctx, "Failed to archive messages", "standup", standup.Name, "error", err)
+		}
+	}()
+
+	location, err := time.LoadLocation(standup.Timezone)
+	if err != nil {
+		return run, fmt.Errorf("PostSummary: standup %s has an invalid timezone: %w", standup.Name, err)
+	}
+	today = date == time.Now().In(location).Format(time.DateOnly)
+
+	if team.AccessToken == "" || standup.ChannelID == "" {
+		slog.WarnContext(ctx, "Missing credentials for summary", "standup", standup.Name)
+		err = fmt.Errorf("standup %s of team %s has no token or channel", standup.Name, team.TeamID)
+		return run, err
+	}
+
+	earlier, err := stores.Submissions.GetStandupRuns(standup.ID, date, date)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch earlier summary runs", "standup", standup.Name, "error", err)
+		return run, err
+	}
+	posted := len(earlier) > 0 && earlier[0].Status == db.RunStatusPosted
+
+	messages, err := loadUpdates(ctx, standup, date, today)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch messages for summary", "standup", standup.Name, "error", err)
+		return run, err
+	}
+
+	userIDs := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		userIDs = append(userIDs, msg.UserID)
+	}
+	if !today && posted {
+		// Posting a past day again doesn't change who took part then.
+		run.Submitted, run.Prompted = earlier[0].Submitted, earlier[0].Prompted
+	} else {
+		run.Submitted, run.Prompted = recordParticipation(ctx, team.TeamID, standup.ID, userIDs)
+	}
 
 	if len(messages) == 0 {
-		log.Printf("PostSummaryForTeam: no messages found for team %s", team.TeamID)
-		return
+		slog.InfoContext(ctx, "No updates to summarize", "standup", standup.Name)
+		metrics.SummariesPosted.WithLabelValues(db.RunStatusEmpty).Inc()
+		run.Status = db.RunStatusEmpty
+		return run, nil
+	}
+
+	profiles := api.GetUserProfiles(ctx, &team, userIDs)
+
+	heading := ""
+	if !today {
+		heading = date
+	}
+	summary := formatSummary(standup, heading, messages, profiles)
+	if err = api.SendTeamMessage(ctx, &team, standup.ChannelID, summary); err != nil {
+		slog.ErrorContext(ctx, "Failed to post summary", "standup", standup.Name, "error", err)
+		metrics.SummariesPosted.WithLabelValues(db.RunStatusFailed).Inc()
+		return run, err
 	}
+	metrics.SummariesPosted.WithLabelValues(db.RunStatusPosted).Inc()
+	run.Status = db.RunStatusPosted
+	slog.InfoContext(ctx, "Posted summary", "standup", standup.Name, "date", date, "updates", len(messages))
+	return run, nil
+}
+
+// loadUpdates reads the updates summarized on date and, if pending is set,
+// those not yet summarized, in a span of its own so a failed summary shows
+// whether loading or posting failed.
+func loadUpdates(ctx context.Context, standup db.Standup, date string, pending bool) (messages []db.UserMessage, err error) {
+	ctx, span := tracing.Start(ctx, "scheduler.load_updates")
+	defer func() {
+		span.SetAttributes(attribute.Int("updates", len(messages)))
+		tracing.End(span, err)
+	}()
 
-	summary := formatSummary(messages)
-	if err := api.SendMessage(team.AccessToken, team.ChannelID, summary); err != nil {
-		log.Printf("PostSummaryForTeam: failed to post summary to Slack for team %s: %v", team.TeamID, err)
+	// History is newest summary first, but within a day it's in the order
+	// the updates were sent, ahead of those still pending.
+	messages, err = stores.Submissions.GetHistory(ctx, db.HistoryFilter{StandupID: standup.ID, From: date, To: date})
+	if err != nil || !pending {
+		return messages, err
 	}
+	updates, err := stores.Submissions.GetMessagesForStandupToday(ctx, standup.ID)
+	return append(messages, updates...), err
 }
 
-func formatSummary(messages []db.UserMessage) string {
+// recordParticipation reports the share of the standup's prompted users
+// among the authors of today's updates, and returns both counts.
+func recordParticipation(ctx context.Context, teamID string, standupID uint, authors []string) (submitted, prompted int) {
+	users, err := stores.Participants.GetAllPromptUser(standupID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get prompt users", "standup_id", standupID, "error", err)
+		return 0, 0
+	}
+	promptedUsers := make(map[string]bool, len(users))
+	for _, user := range users {
+		promptedUsers[user.UserID] = true
+	}
+	submittedUsers := make(map[string]bool)
+	for _, userID := range authors {
+		if promptedUsers[userID] {
+			submittedUsers[userID] = true
+		}
+	}
+	metrics.SetParticipation(teamID, len(submittedUsers), len(promptedUsers))
+	return len(submittedUsers), len(promptedUsers)
+}
+
+// formatSummary renders the standup's updates grouped by author, ordered by
+// display name, headed with date if it's set. Authors are named rather than
+// mentioned so posting the summary doesn't notify everyone; users whose
+// profile couldn't be loaded fall back to a mention.
+func formatSummary(standup db.Standup, date string, messages []db.UserMessage, profiles map[string]*utils.UserProfile) string {
 	userMap := make(map[string][]string)
+	var userIDs []string
 
 	for _, msg := range messages {
+		if _, seen := userMap[msg.UserID]; !seen {
+			userIDs = append(userIDs, msg.UserID)
+		}
 		userMap[msg.UserID] = append(userMap[msg.UserID], msg.Message)
 	}
 
+	name := func(userID string) string {
+		if profile, ok := profiles[userID]; ok && profile.DisplayName != "" {
+			return profile.DisplayName
+		}
+		return fmt.Sprintf("<@%s>", userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		return strings.ToLower(name(userIDs[i])) < strings.ToLower(name(userIDs[j]))
+	})
+
 	var summary strings.Builder
-	summary.WriteString("Team Daily Standup Summary:\n")
+	if standup.Name == db.DefaultStandupName {
+		summary.WriteString("Team Daily Standup Summary")
+	} else {
+		summary.WriteString(fmt.Sprintf("Daily Standup Summary — %s", standup.Name))
+	}
+	if date != "" {
+		summary.WriteString(" for " + date)
+	}
+	summary.WriteString(":\n")
 
-	for userID, updates := range userMap {
-		summary.WriteString(fmt.Sprintf("\n• <@%s>\n", userID))
-		for _, u := range updates {
+	for _, userID := range userIDs {
+		summary.WriteString(fmt.Sprintf("\n• *%s*", name(userID)))
+		if profile, ok := profiles[userID]; ok && profile.TZ != "" && profile.TZ != standup.Timezone {
+			summary.WriteString(fmt.Sprintf(" _(%s)_", profile.TZ))
+		}
+		summary.WriteString("\n")
+		for _, u := range userMap[userID] {
 			summary.WriteString(fmt.Sprintf("   - %s\n", u))
 		}
 	}
@@ -0,0 +1,94 @@
+package scheduler_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"MidayBrief/api"
+	"MidayBrief/config"
+	"MidayBrief/db"
+	"MidayBrief/scheduler"
+	"MidayBrief/slack"
+	"MidayBrief/slacktest"
+	"MidayBrief/store"
+	"MidayBrief/utils"
+)
+
+func TestPostSummaryRecordsRunWithInvalidTimezone(t *testing.T) {
+	if err := utils.InitCrypto(config.Crypto{LegacyKey: "0123456789abcdef0123456789abcdef"}); err != nil {
+		t.Fatal(err)
+	}
+	mem := store.NewMemory()
+	scheduler.SetStores(mem.Stores())
+	utils.SetDataKeyStore(mem)
+
+	standup, err := mem.CreateStandup("T1", db.DefaultStandupName, "Not/AZone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Now().UTC().Format(time.DateOnly)
+	if _, err := scheduler.PostSummary(context.Background(), db.TeamConfig{TeamID: "T1"}, *standup, date); err == nil {
+		t.Fatal("summary posted for a standup with an invalid timezone")
+	}
+
+	runs, err := mem.GetStandupRuns(standup.ID, date, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Status != db.RunStatusFailed || !strings.Contains(runs[0].Error, "invalid timezone") {
+		t.Fatalf("recorded runs %+v, want one failed for the invalid timezone", runs)
+	}
+}
+
+func TestReconcileListsUsersOncePerTeam(t *testing.T) {
+	if err := utils.InitCrypto(config.Crypto{LegacyKey: "0123456789abcdef0123456789abcdef"}); err != nil {
+		t.Fatal(err)
+	}
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	api.SetSlackClient(srv.Client())
+	mem := store.NewMemory()
+	api.SetStores(mem.Stores())
+	scheduler.SetStores(mem.Stores())
+	utils.SetDataKeyStore(mem)
+
+	for _, id := range []string{"U1", "U2", "U3"} {
+		srv.AddUser(slack.User{ID: id, TeamID: "T1", Name: strings.ToLower(id), TZ: "UTC"})
+	}
+	token, err := utils.EncryptForTeam("T1", "xoxb-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.SaveTeamConfig(db.TeamConfig{TeamID: "T1", AccessToken: token, IsActive: true}); err != nil {
+		t.Fatal(err)
+	}
+	members := map[string][]string{"C1": {"U1", "U2"}, "C2": {"U2", "U3"}}
+	for i, channel := range []string{"C1", "C2"} {
+		standup, err := mem.CreateStandup("T1", fmt.Sprintf("standup-%d", i), "UTC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mem.UpdateSyncSource(standup.ID, db.SyncSourceChannel, channel); err != nil {
+			t.Fatal(err)
+		}
+		srv.SetChannelMembers(channel, members[channel]...)
+	}
+
+	scheduler.ReconcileParticipants(context.Background())
+
+	if calls := srv.Calls("users.list"); len(calls) != 1 {
+		t.Errorf("users.list called %d times, want once for the team", len(calls))
+	}
+	standups, err := mem.GetSyncedStandups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, standup := range standups {
+		if users, err := mem.GetAllPromptUser(standup.ID); err != nil || len(users) != 2 {
+			t.Errorf("standup %s has %d participant(s), want 2 (err %v)", standup.Name, len(users), err)
+		}
+	}
+}
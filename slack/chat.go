@@ -0,0 +1,27 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+)
+
+type PostMessageResponse struct {
+	Response
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// PostMessage sends text to a channel, or to a user's DM when channel is a
+// user ID.
+func (c *Client) PostMessage(ctx context.Context, token, channel, text string) (*PostMessageResponse, error) {
+	params := url.Values{
+		"channel": {channel},
+		"text":    {text},
+	}
+
+	var resp PostMessageResponse
+	if err := c.call(ctx, "chat.postMessage", token, params, channel, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,241 @@
+// Package slack is a small typed client for the parts of the Slack Web API
+// MidayBrief uses. It applies per-method rate limits, honours Retry-After on
+// HTTP 429 responses and turns ok:false responses into *Error values.
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL    = "https://slack.com/api"
+	defaultTimeout    = 15 * time.Second
+	defaultMaxRetries = 3
+	maxBackoff        = 30 * time.Second
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	limiter    *rateLimiter
+	observe    func(method, status string, duration time.Duration)
+}
+
+type Option func(*Client)
+
+// WithBaseURL points the client at another Slack API root, such as a fake
+// server in tests. An empty url keeps the default.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sends requests through rt, e.g. to trace them, keeping the
+// client's timeout.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient = &http.Client{Timeout: c.httpClient.Timeout, Transport: rt}
+	}
+}
+
+// WithMaxRetries sets how many times a rate-limited or failed request is
+// retried before giving up. Methods that write are only retried when rate
+// limited.
+func WithMaxRetries(n int) Option {
+	return func(c *Client) {
+		c.maxRetries = n
+	}
+}
+
+// WithoutRateLimit disables client-side rate limiting. Retry-After handling
+// still applies.
+func WithoutRateLimit() Option {
+	return func(c *Client) {
+		c.limiter = nil
+	}
+}
+
+// WithObserver registers a function called after every request attempt
+// with the method, its status (see RequestStatus) and latency, e.g. to
+// record metrics.
+func WithObserver(observe func(method, status string, duration time.Duration)) Option {
+	return func(c *Client) {
+		c.observe = observe
+	}
+}
+
+func New(opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		maxRetries: defaultMaxRetries,
+		limiter:    newRateLimiter(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Response holds the fields common to every Web API response.
+type Response struct {
+	OK               bool             `json:"ok"`
+	Error            string           `json:"error,omitempty"`
+	Warning          string           `json:"warning,omitempty"`
+	ResponseMetadata ResponseMetadata `json:"response_metadata"`
+}
+
+type ResponseMetadata struct {
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Messages   []string `json:"messages,omitempty"`
+}
+
+func (r *Response) base() *Response {
+	return r
+}
+
+type response interface {
+	base() *Response
+}
+
+// writeMethods aren't safe to send twice: a timeout or lost response may
+// come after Slack acted on the request, so they're only retried on HTTP
+// 429, which means it wasn't processed.
+var writeMethods = map[string]bool{
+	"chat.postMessage":     true,
+	"oauth.v2.access":      true,
+	"openid.connect.token": true,
+	"views.open":           true,
+	"views.push":           true,
+}
+
+// call invokes a Web API method with form-encoded params and decodes the
+// reply into out. limitKey narrows the rate-limit bucket beyond the method
+// and token, e.g. to a channel for chat.postMessage.
+func (c *Client) call(ctx context.Context, method, token string, params url.Values, limitKey string, out response) error {
+	bucket := method + "|" + token + "|" + limitKey
+
+	for attempt := 0; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.wait(ctx, method, bucket); err != nil {
+				return err
+			}
+		}
+
+		start := time.Now()
+		retryAfter, err := c.do(ctx, method, token, params, out)
+		if c.observe != nil {
+			c.observe(method, RequestStatus(err), time.Since(start))
+		}
+		if err == nil {
+			return nil
+		}
+
+		if retryAfter < 0 || attempt >= c.maxRetries || ctx.Err() != nil {
+			return err
+		}
+		var rateLimited *RateLimitedError
+		if writeMethods[method] && !errors.As(err, &rateLimited) {
+			return err
+		}
+		if retryAfter == 0 {
+			retryAfter = backoff(attempt)
+		}
+		if c.limiter != nil {
+			c.limiter.pause(bucket, retryAfter)
+		}
+		if err := sleep(ctx, retryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+// do performs a single request. A non-negative retryAfter means the failure
+// is transient and the request may be retried after that delay (zero
+// meaning "use backoff"); -1 means it must not be retried.
+func (c *Client) do(ctx context.Context, method, token string, params url.Values, out response) (retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+method, strings.NewReader(params.Encode()))
+	if err != nil {
+		return -1, fmt.Errorf("slack: %s: failed to create request: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("slack: %s: request failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		io.Copy(io.Discard, resp.Body)
+		wait := parseRetryAfter(resp.Header.Get("Retry-After"))
+		return wait, &RateLimitedError{Method: method, RetryAfter: wait}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		io.Copy(io.Discard, resp.Body)
+		return 0, &HTTPError{Method: method, StatusCode: resp.StatusCode}
+	case resp.StatusCode != http.StatusOK:
+		io.Copy(io.Discard, resp.Body)
+		return -1, &HTTPError{Method: method, StatusCode: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return -1, fmt.Errorf("slack: %s: failed to decode response: %w", method, err)
+	}
+	if base := out.base(); !base.OK {
+		return -1, &Error{Method: method, Code: base.Error}
+	}
+	return -1, nil
+}
+
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds < 1 {
+		return time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func backoff(attempt int) time.Duration {
+	d := time.Duration(1<<attempt) * 500 * time.Millisecond
+	d += time.Duration(rand.Int64N(int64(250 * time.Millisecond)))
+	return min(d, maxBackoff)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,42 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+type ConversationsMembersResponse struct {
+	Response
+	Members []string `json:"members"`
+}
+
+// ConversationsMembers returns one page of a channel's member IDs.
+func (c *Client) ConversationsMembers(ctx context.Context, token, channel, cursor string, limit int) (*ConversationsMembersResponse, error) {
+	params := url.Values{"channel": {channel}}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	var resp ConversationsMembersResponse
+	if err := c.call(ctx, "conversations.members", token, params, "", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+type UserGroupsUsersListResponse struct {
+	Response
+	Users []string `json:"users"`
+}
+
+func (c *Client) UserGroupsUsersList(ctx context.Context, token, userGroup string) ([]string, error) {
+	var resp UserGroupsUsersListResponse
+	if err := c.call(ctx, "usergroups.users.list", token, url.Values{"usergroup": {userGroup}}, "", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Users, nil
+}
@@ -0,0 +1,98 @@
+package slack
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Slack error codes the application reacts to.
+const (
+	ErrCodeTokenExpired     = "token_expired"
+	ErrCodeTokenRevoked     = "token_revoked"
+	ErrCodeInvalidAuth      = "invalid_auth"
+	ErrCodeAccountInactive  = "account_inactive"
+	ErrCodeNotAuthed        = "not_authed"
+	ErrCodeChannelNotFound  = "channel_not_found"
+	ErrCodeNotInChannel     = "not_in_channel"
+	ErrCodeUserNotFound     = "user_not_found"
+	ErrCodeInvalidGrant     = "invalid_grant"
+	ErrCodeInvalidRefresh   = "invalid_refresh_token"
+	ErrCodeMissingScope     = "missing_scope"
+	ErrCodeRatelimited      = "ratelimited"
+	ErrCodeInvalidCode      = "invalid_code"
+	ErrCodeNoPermission     = "no_permission"
+	ErrCodeUsergroupMissing = "no_such_subteam"
+)
+
+// Error is an ok:false response from the Web API.
+type Error struct {
+	Method string
+	Code   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("slack: %s: %s", e.Method, e.Code)
+}
+
+// RateLimitedError is returned when Slack still answers HTTP 429 after all
+// retries.
+type RateLimitedError struct {
+	Method     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("slack: %s: rate limited, retry after %s", e.Method, e.RetryAfter)
+}
+
+// HTTPError is an unexpected HTTP status from the Web API.
+type HTTPError struct {
+	Method     string
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("slack: %s: unexpected HTTP status %d", e.Method, e.StatusCode)
+}
+
+// ErrorCode returns the Slack error code carried by err, or "".
+func ErrorCode(err error) string {
+	var slackErr *Error
+	if errors.As(err, &slackErr) {
+		return slackErr.Code
+	}
+	return ""
+}
+
+func IsTokenExpired(err error) bool {
+	return ErrorCode(err) == ErrCodeTokenExpired
+}
+
+// IsAuthError reports whether err means the token can no longer be used.
+func IsAuthError(err error) bool {
+	switch ErrorCode(err) {
+	case ErrCodeTokenRevoked, ErrCodeInvalidAuth, ErrCodeAccountInactive, ErrCodeNotAuthed:
+		return true
+	}
+	return false
+}
+
+// RequestStatus summarizes the outcome of a request for metrics: "ok", the
+// Slack error code, "rate_limited", "http_<status>" or "error".
+func RequestStatus(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	var rateLimited *RateLimitedError
+	var httpErr *HTTPError
+	switch {
+	case ErrorCode(err) != "":
+		return ErrorCode(err)
+	case errors.As(err, &rateLimited):
+		return "rate_limited"
+	case errors.As(err, &httpErr):
+		return fmt.Sprintf("http_%d", httpErr.StatusCode)
+	}
+	return "error"
+}
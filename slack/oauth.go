@@ -0,0 +1,65 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+)
+
+type OAuthV2Response struct {
+	Response
+	AppID               string      `json:"app_id"`
+	AuthedUser          AuthedUser  `json:"authed_user"`
+	Scope               string      `json:"scope"`
+	TokenType           string      `json:"token_type"`
+	AccessToken         string      `json:"access_token"`
+	RefreshToken        string      `json:"refresh_token"`
+	ExpiresIn           int         `json:"expires_in"`
+	BotUserID           string      `json:"bot_user_id"`
+	Team                Team        `json:"team"`
+	Enterprise          *Enterprise `json:"enterprise"`
+	IsEnterpriseInstall bool        `json:"is_enterprise_install"`
+}
+
+type AuthedUser struct {
+	ID string `json:"id"`
+}
+
+type Team struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Enterprise struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// OAuthV2Access exchanges an authorization code from the install flow for
+// a bot token.
+func (c *Client) OAuthV2Access(ctx context.Context, clientID, clientSecret, code, redirectURI string) (*OAuthV2Response, error) {
+	return c.oauthV2Access(ctx, url.Values{
+		"code":          {code},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"redirect_uri":  {redirectURI},
+	})
+}
+
+// OAuthV2Refresh exchanges a refresh token for a new token pair when token
+// rotation is enabled.
+func (c *Client) OAuthV2Refresh(ctx context.Context, clientID, clientSecret, refreshToken string) (*OAuthV2Response, error) {
+	return c.oauthV2Access(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+	})
+}
+
+func (c *Client) oauthV2Access(ctx context.Context, params url.Values) (*OAuthV2Response, error) {
+	var resp OAuthV2Response
+	if err := c.call(ctx, "oauth.v2.access", "", params, params.Get("client_id"), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
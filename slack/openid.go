@@ -0,0 +1,29 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+)
+
+type OpenIDConnectTokenResponse struct {
+	Response
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// OpenIDConnectToken exchanges an authorization code from Sign in with
+// Slack for an ID token.
+func (c *Client) OpenIDConnectToken(ctx context.Context, clientID, clientSecret, code, redirectURI string) (*OpenIDConnectTokenResponse, error) {
+	params := url.Values{
+		"code":          {code},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"redirect_uri":  {redirectURI},
+	}
+	var resp OpenIDConnectTokenResponse
+	if err := c.call(ctx, "openid.connect.token", "", params, clientID, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,130 @@
+package slack
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Tier is a Slack rate-limit tier, see https://api.slack.com/apis/rate-limits.
+type Tier struct {
+	PerMinute int
+	Burst     int
+}
+
+var (
+	Tier1 = Tier{PerMinute: 1, Burst: 1}
+	Tier2 = Tier{PerMinute: 20, Burst: 5}
+	Tier3 = Tier{PerMinute: 50, Burst: 10}
+	Tier4 = Tier{PerMinute: 100, Burst: 20}
+	// TierPostMessage approximates chat.postMessage's special limit of
+	// about one message per second per channel.
+	TierPostMessage = Tier{PerMinute: 60, Burst: 3}
+)
+
+var methodTiers = map[string]Tier{
+	"chat.postMessage":      TierPostMessage,
+	"conversations.members": Tier4,
+	"oauth.v2.access":       Tier4,
+	"usergroups.users.list": Tier2,
+	"users.info":            Tier4,
+	"users.list":            Tier2,
+	"views.open":            Tier4,
+	"views.publish":         Tier4,
+	"views.push":            Tier4,
+	"views.update":          Tier4,
+}
+
+// bucketIdleTTL is how long an unused bucket is kept before being pruned.
+const bucketIdleTTL = 10 * time.Minute
+
+type bucket struct {
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// rateLimiter is a token bucket per method, token and optional sub-key.
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*bucket), lastPrune: time.Now()}
+}
+
+// wait blocks until the bucket has capacity for one call of method.
+func (l *rateLimiter) wait(ctx context.Context, method, key string) error {
+	tier, ok := methodTiers[method]
+	if !ok {
+		tier = Tier3
+	}
+
+	for {
+		delay := l.reserve(tier, key)
+		if delay <= 0 {
+			return nil
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve takes a token if one is available and otherwise reports how long
+// to wait before trying again.
+func (l *rateLimiter) reserve(tier Tier, key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(tier.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now)
+	}
+
+	perSecond := float64(tier.PerMinute) / 60
+	b.tokens = min(float64(tier.Burst), b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+}
+
+// pause blocks the bucket for d, typically after Slack answered 429.
+func (l *rateLimiter) pause(key string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{last: time.Now()}
+		l.buckets[key] = b
+	}
+	b.blockedUntil = time.Now().Add(d)
+	b.tokens = 0
+}
+
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < bucketIdleTTL {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > bucketIdleTTL && now.After(b.blockedUntil) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastPrune = now
+}
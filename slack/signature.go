@@ -0,0 +1,57 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Slack-Signature"
+	HeaderTimestamp = "X-Slack-Request-Timestamp"
+
+	// MaxSignatureAge is how old a signed request may be before it is
+	// rejected as a possible replay.
+	MaxSignatureAge = 5 * time.Minute
+)
+
+var (
+	ErrMissingSignature = errors.New("slack: missing request signature")
+	ErrInvalidSignature = errors.New("slack: invalid request signature")
+	ErrStaleSignature   = errors.New("slack: request timestamp too old")
+)
+
+// Signature computes the X-Slack-Signature value for a request body sent at
+// timestamp (Unix seconds).
+func Signature(signingSecret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a request's Slack signature headers against body.
+func VerifySignature(signingSecret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get(HeaderTimestamp)
+	signature := header.Get(HeaderSignature)
+	if timestamp == "" || signature == "" {
+		return ErrMissingSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > MaxSignatureAge || age < -MaxSignatureAge {
+		return ErrStaleSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Signature(signingSecret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+type User struct {
+	ID                string  `json:"id"`
+	TeamID            string  `json:"team_id"`
+	Name              string  `json:"name"`
+	RealName          string  `json:"real_name"`
+	TZ                string  `json:"tz"`
+	Deleted           bool    `json:"deleted"`
+	IsBot             bool    `json:"is_bot"`
+	IsAppUser         bool    `json:"is_app_user"`
+	IsRestricted      bool    `json:"is_restricted"`
+	IsUltraRestricted bool    `json:"is_ultra_restricted"`
+	Profile           Profile `json:"profile"`
+}
+
+type Profile struct {
+	DisplayName string `json:"display_name"`
+	RealName    string `json:"real_name"`
+	Image72     string `json:"image_72"`
+}
+
+type UsersInfoResponse struct {
+	Response
+	User User `json:"user"`
+}
+
+type UsersListResponse struct {
+	Response
+	Members []User `json:"members"`
+}
+
+func (c *Client) UsersInfo(ctx context.Context, token, userID string) (*User, error) {
+	var resp UsersInfoResponse
+	if err := c.call(ctx, "users.info", token, url.Values{"user": {userID}}, "", &resp); err != nil {
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+// UsersList returns one page of the workspace's users. Pass the previous
+// page's ResponseMetadata.NextCursor to continue; an empty cursor starts
+// from the beginning.
+func (c *Client) UsersList(ctx context.Context, token, cursor string, limit int) (*UsersListResponse, error) {
+	params := url.Values{}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	var resp UsersListResponse
+	if err := c.call(ctx, "users.list", token, params, "", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,324 @@
+package slacktest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"MidayBrief/api"
+	"MidayBrief/config"
+	"MidayBrief/db"
+	"MidayBrief/scheduler"
+	"MidayBrief/slack"
+	"MidayBrief/slacktest"
+	"MidayBrief/store"
+	"MidayBrief/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newApp wires the app to srv and in-memory stores and returns its Slack
+// routes, as cmd/router.go mounts them.
+func newApp(t *testing.T, srv *slacktest.Server) (http.Handler, *store.Memory) {
+	t.Helper()
+	if err := utils.InitCrypto(config.Crypto{LegacyKey: "0123456789abcdef0123456789abcdef"}); err != nil {
+		t.Fatal(err)
+	}
+	api.SetConfig(&config.Config{
+		BaseURL: "https://midaybrief.test",
+		Storage: config.StorageMemory,
+		Slack:   config.Slack{ClientID: "client", ClientSecret: "secret", SigningSecret: slacktest.SigningSecret},
+	})
+	api.SetSlackClient(srv.Client())
+	mem := store.NewMemory()
+	api.SetStores(mem.Stores())
+	scheduler.SetStores(mem.Stores())
+	utils.SetDataKeyStore(mem)
+
+	r := chi.NewRouter()
+	r.Get("/slack/install", api.HandleSlackInstall)
+	r.Get("/slack/oauth/callback", api.HandleSlackOAuthCallback)
+	r.With(api.VerifySlackSignature).Post("/slack/events", api.HandleSlackEvents)
+	return r, mem
+}
+
+// install runs the OAuth flow, with Slack approving it with code.
+func install(t *testing.T, app http.Handler, code string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slack/install", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("install: status %d", rec.Code)
+	}
+	redirect, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callback := httptest.NewRequest(http.MethodGet, "/slack/oauth/callback?"+url.Values{
+		"code":  {code},
+		"state": {redirect.Query().Get("state")},
+	}.Encode(), nil)
+	for _, cookie := range rec.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, callback)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("OAuth callback: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+// dm sends text from userID and fails the test unless it's accepted.
+func dm(t *testing.T, app http.Handler, userID, text string) {
+	t.Helper()
+	if rec := slacktest.SendMessage(app, "T1", userID, text); rec.Code != http.StatusOK {
+		t.Fatalf("DM %q from %s: status %d: %s", text, userID, rec.Code, rec.Body)
+	}
+}
+
+func TestInstallConfigurePromptAndSummarize(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	app, mem := newApp(t, srv)
+	srv.AddUser(slack.User{ID: "U1", TeamID: "T1", Name: "ada", TZ: "UTC"})
+	srv.AddUser(slack.User{ID: "U2", TeamID: "T1", Name: "grace", TZ: "UTC"})
+	srv.AddOAuthCode("code", slack.OAuthV2Response{
+		Response:    slack.Response{OK: true},
+		AppID:       "A1",
+		AuthedUser:  slack.AuthedUser{ID: "U1"},
+		AccessToken: "xoxb-1",
+		BotUserID:   "UBOT",
+		Team:        slack.Team{ID: "T1", Name: "Acme"},
+	})
+
+	install(t, app, "code")
+	team, err := mem.GetTeamConfig("T1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.AccessToken != "xoxb-1" || !mem.IsTeamAdmin("T1", "U1") {
+		t.Fatalf("installed team: token %q, installer admin %v", team.AccessToken, mem.IsTeamAdmin("T1", "U1"))
+	}
+	if welcome := srv.MessagesTo("U1"); len(welcome) != 1 {
+		t.Fatalf("installer got %d message(s), want a welcome", len(welcome))
+	}
+
+	for _, command := range []string{"add user <@U2>", "config <#C1|standup>", "prompt time 09:00", "post time 10:00"} {
+		dm(t, app, "U1", command)
+	}
+	standup, err := mem.GetStandupByName("T1", db.DefaultStandupName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if standup.ChannelID != "C1" || standup.PromptTime != "09:00" || standup.PostTime != "10:00" {
+		t.Fatalf("configured standup: channel %q, prompt %q, post %q", standup.ChannelID, standup.PromptTime, standup.PostTime)
+	}
+
+	ctx := context.Background()
+	prompted, err := scheduler.TriggerPrompt(ctx, *team, *standup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompted != 1 {
+		t.Fatalf("prompted %d participant(s), want 1", prompted)
+	}
+	questions := standup.QuestionList()
+	if prompt := srv.MessagesTo("U2"); len(prompt) != 1 || !strings.Contains(prompt[0].Text, questions[0]) {
+		t.Fatalf("participant got %v, want the first question", prompt)
+	}
+	for i := range questions {
+		dm(t, app, "U2", fmt.Sprintf("answer %d", i+1))
+	}
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	run, err := scheduler.PostSummary(ctx, *team, *standup, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != db.RunStatusPosted || run.Submitted != 1 || run.Prompted != 1 {
+		t.Errorf("summary run: %s, %d of %d", run.Status, run.Submitted, run.Prompted)
+	}
+	summary := srv.MessagesTo("C1")
+	if len(summary) != 1 {
+		t.Fatalf("channel got %d message(s), want the summary", len(summary))
+	}
+	for i := range questions {
+		if answer := fmt.Sprintf("answer %d", i+1); !strings.Contains(summary[0].Text, answer) {
+			t.Errorf("summary is missing %q:\n%s", answer, summary[0].Text)
+		}
+	}
+	if summary[0].Token != "xoxb-1" {
+		t.Errorf("summary posted with token %q, want the installed one", summary[0].Token)
+	}
+}
+
+func TestUnsignedEventsAreRejected(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	app, _ := newApp(t, srv)
+
+	req := slacktest.NewSignedRequest(slacktest.EventCallback("T1", slacktest.DirectMessage("U1", "hello")))
+	req.Header.Del(slack.HeaderSignature)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned event: status %d, want 401", rec.Code)
+	}
+
+	// Without a signing secret nothing can be verified, so everything is
+	// rejected unless verification is explicitly skipped.
+	api.SetConfig(&config.Config{Storage: config.StorageMemory})
+	rec = slacktest.SendMessage(app, "T1", "U1", "hello")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("event without a signing secret configured: status %d, want 401", rec.Code)
+	}
+
+	api.SetConfig(&config.Config{Storage: config.StorageMemory, Slack: config.Slack{SkipVerification: true}})
+	req = slacktest.NewSignedRequest(slacktest.EventCallback("T1", slacktest.DirectMessage("U1", "hello")))
+	req.Header.Del(slack.HeaderSignature)
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code == http.StatusUnauthorized {
+		t.Error("unsigned event rejected with SLACK_SKIP_VERIFICATION set")
+	}
+}
+
+// installAcme installs the app in T1 with U1 as the installing admin.
+func installAcme(t *testing.T, srv *slacktest.Server, app http.Handler) {
+	t.Helper()
+	srv.AddOAuthCode("code", slack.OAuthV2Response{
+		Response:    slack.Response{OK: true},
+		AppID:       "A1",
+		AuthedUser:  slack.AuthedUser{ID: "U1"},
+		AccessToken: "xoxb-1",
+		BotUserID:   "UBOT",
+		Team:        slack.Team{ID: "T1", Name: "Acme"},
+	})
+	install(t, app, "code")
+}
+
+// waitForMessages waits for channel to have received n messages.
+func waitForMessages(t *testing.T, srv *slacktest.Server, channel string, n int) []slacktest.Message {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		messages := srv.MessagesTo(channel)
+		if len(messages) >= n {
+			return messages
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s got %d message(s), want %d", channel, len(messages), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAddAllUsersRunsInTheBackground(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	app, mem := newApp(t, srv)
+	srv.SetPageSize(1)
+	for _, id := range []string{"U1", "U2", "U3"} {
+		srv.AddUser(slack.User{ID: id, TeamID: "T1", Name: strings.ToLower(id), TZ: "UTC"})
+	}
+	installAcme(t, srv, app)
+
+	dm(t, app, "U1", "add all users")
+	replies := waitForMessages(t, srv, "DU1", 2)
+	if !strings.Contains(replies[0].Text, "I'll message you when it's done") {
+		t.Errorf("first reply %q doesn't acknowledge the command", replies[0].Text)
+	}
+	if !strings.Contains(replies[1].Text, "Added 3 users") {
+		t.Errorf("follow-up %q doesn't report the users added", replies[1].Text)
+	}
+	standup, err := mem.GetStandupByName("T1", db.DefaultStandupName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users, err := mem.GetAllPromptUser(standup.ID); err != nil || len(users) != 3 {
+		t.Errorf("standup has %d participant(s), want 3 (err %v)", len(users), err)
+	}
+}
+
+func TestUninstallDestroysDataKey(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	app, mem := newApp(t, srv)
+	srv.AddUser(slack.User{ID: "U1", TeamID: "T1", Name: "ada", TZ: "UTC"})
+	installAcme(t, srv, app)
+	if _, _, err := mem.GetDataKey("T1"); err != nil {
+		t.Fatalf("installed team has no data key: %v", err)
+	}
+
+	rec := slacktest.SendEvent(app, slacktest.EventCallback("T1", map[string]any{"type": "app_uninstalled"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("app_uninstalled: status %d", rec.Code)
+	}
+	if _, _, err := mem.GetDataKey("T1"); !errors.Is(err, utils.ErrDataKeyNotFound) {
+		t.Errorf("data key after uninstall: got %v, want it destroyed", err)
+	}
+	if _, err := mem.GetTeamConfig("T1"); err != nil {
+		t.Errorf("team purged before the retention period: %v", err)
+	}
+}
+func TestRetriedEventsAreHandledOnce(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	app, mem := newApp(t, srv)
+	srv.AddUser(slack.User{ID: "U1", TeamID: "T1", Name: "ada", TZ: "UTC"})
+	srv.AddUser(slack.User{ID: "U2", TeamID: "T1", Name: "grace", TZ: "UTC"})
+	installAcme(t, srv, app)
+
+	event := slacktest.EventCallback("T1", slacktest.DirectMessage("U1", "add user <@U2>"))
+	slacktest.SendEvent(app, event)
+	req := slacktest.NewSignedRequest(event)
+	req.Header.Set("X-Slack-Retry-Num", "1")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("retry: status %d, want 200", rec.Code)
+	}
+
+	if replies := srv.MessagesTo("DU1"); len(replies) != 1 {
+		t.Errorf("admin got %d replies, want 1", len(replies))
+	}
+	standup, err := mem.GetStandupByName("T1", db.DefaultStandupName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users, err := mem.GetAllPromptUser(standup.ID); err != nil || len(users) != 1 {
+		t.Errorf("standup has %d participant(s), want 1 (err %v)", len(users), err)
+	}
+}
+
+func TestRetryOfFailedEventIsHandled(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	app, _ := newApp(t, srv)
+	srv.AddUser(slack.User{ID: "U1", TeamID: "T1", Name: "ada", TZ: "UTC"})
+
+	// The team isn't installed yet, so the first delivery fails.
+	event := slacktest.EventCallback("T1", slacktest.DirectMessage("U1", "list standups"))
+	if rec := slacktest.SendEvent(app, event); rec.Code == http.StatusOK {
+		t.Fatal("event for a team that isn't installed succeeded")
+	}
+
+	installAcme(t, srv, app)
+	req := slacktest.NewSignedRequest(event)
+	req.Header.Set("X-Slack-Retry-Num", "1")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("retry: status %d, want 200", rec.Code)
+	}
+	if replies := srv.MessagesTo("DU1"); len(replies) != 1 {
+		t.Errorf("admin got %d replies to the retry, want 1", len(replies))
+	}
+}
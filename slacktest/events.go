@@ -0,0 +1,84 @@
+package slacktest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"MidayBrief/slack"
+)
+
+// SigningSecret is the secret used by NewSignedRequest; configure it as
+// the app's Slack.SigningSecret so the app accepts the requests.
+const SigningSecret = "slacktest-signing-secret"
+
+var lastEventID atomic.Int64
+
+// EventCallback builds an Events API event_callback envelope for teamID
+// wrapping event, with a new event ID.
+func EventCallback(teamID string, event map[string]any) map[string]any {
+	return map[string]any{
+		"type":     "event_callback",
+		"event_id": "Ev" + strconv.FormatInt(lastEventID.Add(1), 10),
+		"team_id":  teamID,
+		"event":    event,
+		"authorizations": []map[string]any{
+			{"team_id": teamID, "is_enterprise_install": false},
+		},
+	}
+}
+
+// DirectMessage builds a message event from userID in their DM with the app.
+func DirectMessage(userID, text string) map[string]any {
+	return map[string]any{
+		"type":         "message",
+		"user":         userID,
+		"text":         text,
+		"channel":      "D" + userID,
+		"channel_type": "im",
+	}
+}
+
+// MemberJoinedChannel builds a member_joined_channel event.
+func MemberJoinedChannel(userID, channelID string) map[string]any {
+	return map[string]any{"type": "member_joined_channel", "user": userID, "channel": channelID}
+}
+
+// MemberLeftChannel builds a member_left_channel event.
+func MemberLeftChannel(userID, channelID string) map[string]any {
+	return map[string]any{"type": "member_left_channel", "user": userID, "channel": channelID}
+}
+
+// NewSignedRequest returns a POST to /slack/events carrying payload as JSON,
+// signed with SigningSecret at the current time.
+func NewSignedRequest(payload any) *http.Request {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		panic("slacktest: can't encode payload: " + err.Error())
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/slack/events", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(slack.HeaderTimestamp, timestamp)
+	req.Header.Set(slack.HeaderSignature, slack.Signature(SigningSecret, timestamp, body))
+	return req
+}
+
+// SendEvent delivers payload to handler as a signed Slack request and
+// returns the recorded response. Pass the router, or the events handler
+// wrapped in the signature middleware, to exercise verification too.
+func SendEvent(handler http.Handler, payload any) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, NewSignedRequest(payload))
+	return rec
+}
+
+// SendMessage delivers a DM from userID in teamID to handler.
+func SendMessage(handler http.Handler, teamID, userID, text string) *httptest.ResponseRecorder {
+	return SendEvent(handler, EventCallback(teamID, DirectMessage(userID, text)))
+}
@@ -0,0 +1,433 @@
+// Package slacktest runs an in-process fake of the Slack Web API and helps
+// drive the app's HTTP handlers with signed Slack requests, for end-to-end
+// tests of install, configuration, prompting and summaries.
+package slacktest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"MidayBrief/slack"
+)
+
+// Call is one request received by the fake server.
+type Call struct {
+	Method string
+	Token  string
+	Params url.Values
+}
+
+// Message is a chat.postMessage call as Slack would have delivered it.
+type Message struct {
+	Token   string
+	Channel string
+	Text    string
+	TS      string
+}
+
+type failure struct {
+	code       string
+	retryAfter time.Duration
+}
+
+// Server is a fake Slack Web API. Its zero value isn't usable; create one
+// with NewServer and Close it when done.
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	calls         []Call
+	messages      []Message
+	users         map[string]slack.User
+	userOrder     []string
+	channels      map[string][]string
+	userGroups    map[string][]string
+	oauthCodes    map[string]slack.OAuthV2Response
+	openIDCodes   map[string]IDTokenClaims
+	refreshTokens map[string]slack.OAuthV2Response
+	validTokens   map[string]bool
+	failures      map[string][]failure
+	pageSize      int
+	ts            int
+}
+
+func NewServer() *Server {
+	s := &Server{
+		users:         make(map[string]slack.User),
+		channels:      make(map[string][]string),
+		userGroups:    make(map[string][]string),
+		oauthCodes:    make(map[string]slack.OAuthV2Response),
+		openIDCodes:   make(map[string]IDTokenClaims),
+		refreshTokens: make(map[string]slack.OAuthV2Response),
+		validTokens:   make(map[string]bool),
+		failures:      make(map[string][]failure),
+		pageSize:      100,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Client returns a Slack client talking to the fake server, with
+// client-side rate limiting and retries turned off so tests run fast.
+func (s *Server) Client() *slack.Client {
+	return slack.New(slack.WithBaseURL(s.URL), slack.WithoutRateLimit(), slack.WithMaxRetries(0))
+}
+
+// AddToken makes token valid for authenticated methods. Until a token is
+// added, every token is accepted.
+func (s *Server) AddToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validTokens[token] = true
+}
+
+// RevokeToken makes calls with token fail with token_revoked.
+func (s *Server) RevokeToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validTokens[token] = false
+}
+
+// AddUser registers a user returned by users.info and users.list.
+func (s *Server) AddUser(user slack.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.ID]; !ok {
+		s.userOrder = append(s.userOrder, user.ID)
+	}
+	s.users[user.ID] = user
+}
+
+// SetChannelMembers sets what conversations.members returns for channel.
+func (s *Server) SetChannelMembers(channel string, members ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels[channel] = members
+}
+
+// SetUserGroupMembers sets what usergroups.users.list returns for group.
+func (s *Server) SetUserGroupMembers(group string, members ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userGroups[group] = members
+}
+
+// SetPageSize sets how many items paginated methods return per page.
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = n
+}
+
+// AddOAuthCode makes oauth.v2.access exchange code for resp. OK is set for
+// you and the returned access token becomes valid.
+func (s *Server) AddOAuthCode(code string, resp slack.OAuthV2Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp.OK = true
+	s.oauthCodes[code] = resp
+	s.validTokens[resp.AccessToken] = true
+}
+
+// IDTokenClaims are the claims of an ID token from Sign in with Slack.
+type IDTokenClaims struct {
+	TeamID   string
+	UserID   string
+	Name     string
+	Nonce    string
+	Audience string
+}
+
+// AddOpenIDCode makes openid.connect.token exchange code, once, for an ID
+// token with claims issued by Slack and expiring in an hour. The token
+// isn't signed.
+func (s *Server) AddOpenIDCode(code string, claims IDTokenClaims) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.openIDCodes[code] = claims
+}
+
+// AddRefreshToken makes oauth.v2.access with grant_type=refresh_token
+// exchange refreshToken for resp, once.
+func (s *Server) AddRefreshToken(refreshToken string, resp slack.OAuthV2Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp.OK = true
+	s.refreshTokens[refreshToken] = resp
+	s.validTokens[resp.AccessToken] = true
+}
+
+// FailNext makes the next call to method fail with the Slack error code.
+func (s *Server) FailNext(method, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], failure{code: code})
+}
+
+// RateLimitNext makes the next call to method answer HTTP 429 with
+// Retry-After.
+func (s *Server) RateLimitNext(method string, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], failure{retryAfter: retryAfter})
+}
+
+// Calls returns the recorded calls, optionally only those to method.
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Messages returns every message posted with chat.postMessage.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// MessagesTo returns the messages posted to channel, which is a user ID for
+// DMs.
+func (s *Server) MessagesTo(channel string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []Message
+	for _, m := range s.messages {
+		if m.Channel == channel {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// Reset forgets recorded calls and messages but keeps configured data.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+	s.messages = nil
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, Call{Method: method, Token: token, Params: r.Form})
+
+	if queued := s.failures[method]; len(queued) > 0 {
+		f := queued[0]
+		s.failures[method] = queued[1:]
+		if f.code == "" {
+			w.Header().Set("Retry-After", strconv.Itoa(int(f.retryAfter.Seconds())))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writeError(w, f.code)
+		return
+	}
+
+	if method != "oauth.v2.access" && method != "openid.connect.token" {
+		if token == "" {
+			writeError(w, slack.ErrCodeNotAuthed)
+			return
+		}
+		if valid, known := s.validTokens[token]; known && !valid {
+			writeError(w, slack.ErrCodeTokenRevoked)
+			return
+		} else if !known && len(s.validTokens) > 0 {
+			writeError(w, slack.ErrCodeInvalidAuth)
+			return
+		}
+	}
+
+	switch method {
+	case "oauth.v2.access":
+		s.oauthAccess(w, r.Form)
+	case "openid.connect.token":
+		s.openIDToken(w, r.Form)
+	case "chat.postMessage":
+		s.postMessage(w, token, r.Form)
+	case "users.info":
+		s.usersInfo(w, r.Form)
+	case "users.list":
+		s.usersList(w, r.Form)
+	case "conversations.members":
+		s.conversationsMembers(w, r.Form)
+	case "usergroups.users.list":
+		s.userGroupUsers(w, r.Form)
+	case "views.open", "views.publish", "views.push", "views.update":
+		s.views(w, r.Form)
+	default:
+		writeError(w, "unknown_method")
+	}
+}
+
+func (s *Server) oauthAccess(w http.ResponseWriter, form url.Values) {
+	if form.Get("grant_type") == "refresh_token" {
+		resp, ok := s.refreshTokens[form.Get("refresh_token")]
+		if !ok {
+			writeError(w, slack.ErrCodeInvalidRefresh)
+			return
+		}
+		delete(s.refreshTokens, form.Get("refresh_token"))
+		writeJSON(w, resp)
+		return
+	}
+
+	resp, ok := s.oauthCodes[form.Get("code")]
+	if !ok {
+		writeError(w, slack.ErrCodeInvalidCode)
+		return
+	}
+	delete(s.oauthCodes, form.Get("code"))
+	writeJSON(w, resp)
+}
+
+func (s *Server) openIDToken(w http.ResponseWriter, form url.Values) {
+	claims, ok := s.openIDCodes[form.Get("code")]
+	if !ok {
+		writeError(w, slack.ErrCodeInvalidCode)
+		return
+	}
+	delete(s.openIDCodes, form.Get("code"))
+
+	payload, _ := json.Marshal(map[string]any{
+		"iss":                       "https://slack.com",
+		"aud":                       claims.Audience,
+		"sub":                       claims.UserID,
+		"exp":                       time.Now().Add(time.Hour).Unix(),
+		"iat":                       time.Now().Unix(),
+		"nonce":                     claims.Nonce,
+		"name":                      claims.Name,
+		"https://slack.com/user_id": claims.UserID,
+		"https://slack.com/team_id": claims.TeamID,
+	})
+	encode := base64.RawURLEncoding.EncodeToString
+	idToken := encode([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + encode(payload) + "."
+	writeJSON(w, slack.OpenIDConnectTokenResponse{
+		Response:    slack.Response{OK: true},
+		AccessToken: "xoxp-openid-" + claims.UserID,
+		TokenType:   "Bearer",
+		IDToken:     idToken,
+	})
+}
+
+func (s *Server) postMessage(w http.ResponseWriter, token string, form url.Values) {
+	channel := form.Get("channel")
+	if channel == "" {
+		writeError(w, slack.ErrCodeChannelNotFound)
+		return
+	}
+
+	s.ts++
+	ts := fmt.Sprintf("%d.%06d", time.Now().Unix(), s.ts)
+	s.messages = append(s.messages, Message{Token: token, Channel: channel, Text: form.Get("text"), TS: ts})
+	writeJSON(w, slack.PostMessageResponse{Response: slack.Response{OK: true}, Channel: channel, TS: ts})
+}
+
+func (s *Server) usersInfo(w http.ResponseWriter, form url.Values) {
+	user, ok := s.users[form.Get("user")]
+	if !ok {
+		writeError(w, slack.ErrCodeUserNotFound)
+		return
+	}
+	writeJSON(w, slack.UsersInfoResponse{Response: slack.Response{OK: true}, User: user})
+}
+
+func (s *Server) usersList(w http.ResponseWriter, form url.Values) {
+	page, next := paginate(s.userOrder, form.Get("cursor"), s.pageSize)
+	members := make([]slack.User, 0, len(page))
+	for _, id := range page {
+		members = append(members, s.users[id])
+	}
+
+	resp := slack.UsersListResponse{Response: slack.Response{OK: true}, Members: members}
+	resp.ResponseMetadata.NextCursor = next
+	writeJSON(w, resp)
+}
+
+func (s *Server) conversationsMembers(w http.ResponseWriter, form url.Values) {
+	members, ok := s.channels[form.Get("channel")]
+	if !ok {
+		writeError(w, slack.ErrCodeChannelNotFound)
+		return
+	}
+
+	page, next := paginate(members, form.Get("cursor"), s.pageSize)
+	resp := slack.ConversationsMembersResponse{Response: slack.Response{OK: true}, Members: page}
+	resp.ResponseMetadata.NextCursor = next
+	writeJSON(w, resp)
+}
+
+func (s *Server) userGroupUsers(w http.ResponseWriter, form url.Values) {
+	users, ok := s.userGroups[form.Get("usergroup")]
+	if !ok {
+		writeError(w, slack.ErrCodeUsergroupMissing)
+		return
+	}
+	writeJSON(w, slack.UserGroupsUsersListResponse{Response: slack.Response{OK: true}, Users: users})
+}
+
+// views accepts any view and echoes it back with an ID, which is enough for
+// handlers that only care that the call succeeded.
+func (s *Server) views(w http.ResponseWriter, form url.Values) {
+	var view map[string]any
+	if raw := form.Get("view"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &view); err != nil {
+			writeError(w, "invalid_arguments")
+			return
+		}
+	}
+	if view == nil {
+		view = map[string]any{}
+	}
+	s.ts++
+	view["id"] = fmt.Sprintf("V%06d", s.ts)
+	writeJSON(w, map[string]any{"ok": true, "view": view})
+}
+
+// paginate returns the page of items starting at cursor, which is the
+// decimal offset into items, and the cursor of the next page.
+func paginate(items []string, cursor string, pageSize int) ([]string, string) {
+	start, _ := strconv.Atoi(cursor)
+	if start > len(items) {
+		start = len(items)
+	}
+	end := min(start+pageSize, len(items))
+
+	next := ""
+	if end < len(items) {
+		next = strconv.Itoa(end)
+	}
+	return items[start:end], next
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code string) {
+	writeJSON(w, slack.Response{OK: false, Error: code})
+}
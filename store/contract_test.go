@@ -0,0 +1,371 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"MidayBrief/config"
+	"MidayBrief/db"
+	"MidayBrief/utils"
+
+	"gorm.io/gorm"
+)
+
+// The contract tests check that every Stores implementation behaves the
+// same, so tests and STORAGE=memory can stand in for Postgres.
+
+func TestMemoryContract(t *testing.T) {
+	testContract(t, NewMemory().Stores())
+}
+
+// TestPostgresContract runs against the database at DATABASE_URL, which it
+// migrates. Rows are created under unique IDs and left behind.
+func TestPostgresContract(t *testing.T) {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	db.Init(config.Database{URL: url, MigrateOnStart: true})
+	testContract(t, Default())
+}
+
+func testContract(t *testing.T, stores *Stores) {
+	if err := utils.InitCrypto(config.Crypto{LegacyKey: "0123456789abcdef0123456789abcdef"}); err != nil {
+		t.Fatal(err)
+	}
+	utils.SetDataKeyStore(stores.Crypto)
+	// IDs are unique per run so a shared database can be reused.
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	id := func(prefix string) string { return prefix + run }
+
+	t.Run("Teams", func(t *testing.T) { testTeams(t, stores, id) })
+	t.Run("Enterprise", func(t *testing.T) { testEnterprise(t, stores, id) })
+	t.Run("Standups", func(t *testing.T) { testStandups(t, stores, id) })
+	t.Run("Submissions", func(t *testing.T) { testSubmissions(t, stores, id) })
+	t.Run("APIKeys", func(t *testing.T) { testAPIKeys(t, stores, id) })
+	t.Run("DataKeys", func(t *testing.T) { testDataKeys(t, stores, id) })
+}
+
+func encrypt(t *testing.T, teamID, value string) string {
+	t.Helper()
+	ciphertext, err := utils.EncryptForTeam(teamID, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ciphertext
+}
+
+func testTeams(t *testing.T, stores *Stores, id func(string) string) {
+	teamID := id("T")
+	if _, err := stores.Teams.GetTeamConfig(teamID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("GetTeamConfig of a missing team: got %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	if err := stores.Teams.SaveTeamConfig(db.TeamConfig{TeamID: teamID, AccessToken: encrypt(t, teamID, "xoxb-1"), InstallerUserID: "U1"}); err != nil {
+		t.Fatal(err)
+	}
+	team, err := stores.Teams.GetTeamConfig(teamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.AccessToken != "xoxb-1" || !team.IsActive || team.CreatedAt.IsZero() {
+		t.Errorf("saved team: token %q, active %v, created %v", team.AccessToken, team.IsActive, team.CreatedAt)
+	}
+	if !team.IncludeGuests || team.IncludeBots || team.IncludeAppUsers {
+		t.Errorf("new team includes guests %v, bots %v, app users %v; want only guests", team.IncludeGuests, team.IncludeBots, team.IncludeAppUsers)
+	}
+
+	// Reinstalling updates the installation but keeps the settings.
+	if err := stores.Teams.UpdateUserFilter(teamID, "include_guests", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Teams.SaveTeamConfig(db.TeamConfig{TeamID: teamID, AccessToken: encrypt(t, teamID, "xoxb-2")}); err != nil {
+		t.Fatal(err)
+	}
+	team, err = stores.Teams.GetTeamConfig(teamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.AccessToken != "xoxb-2" || team.IncludeGuests {
+		t.Errorf("reinstalled team: token %q, includes guests %v; want xoxb-2 and the guests setting kept", team.AccessToken, team.IncludeGuests)
+	}
+
+	if err := stores.Teams.AddTeamAdmin(teamID, "U1"); err != nil {
+		t.Fatal(err)
+	}
+	if !stores.Teams.IsTeamAdmin(teamID, "U1") || stores.Teams.IsTeamAdmin(teamID, "U2") {
+		t.Error("IsTeamAdmin doesn't match the admins added")
+	}
+	if err := stores.Teams.RemoveTeamAdmin(teamID, "U1"); err == nil {
+		t.Error("removed the team's last admin")
+	}
+
+	if err := stores.Teams.DeactivateTeam(teamID); err != nil {
+		t.Fatal(err)
+	}
+	team, err = stores.Teams.GetTeamConfig(teamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.IsActive || team.AccessToken != "" || team.DeactivatedAt == nil {
+		t.Errorf("deactivated team: active %v, token %q, deactivated at %v", team.IsActive, team.AccessToken, team.DeactivatedAt)
+	}
+	deactivated, err := stores.Teams.GetTeamsDeactivatedBefore(time.Now().UTC().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(deactivated, func(team db.TeamConfig) bool { return team.TeamID == teamID }) {
+		t.Error("GetTeamsDeactivatedBefore left out the deactivated team")
+	}
+
+	if err := stores.Teams.PurgeTeam(teamID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Teams.GetTeamConfig(teamID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetTeamConfig of a purged team: got %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func testEnterprise(t *testing.T, stores *Stores, id func(string) string) {
+	orgID, workspaceID := id("E"), id("W")
+	org := db.TeamConfig{TeamID: orgID, EnterpriseID: orgID, IsEnterpriseInstall: true, AccessToken: encrypt(t, orgID, "xoxb-org")}
+	if err := stores.Teams.SaveTeamConfig(org); err != nil {
+		t.Fatal(err)
+	}
+	workspace, err := stores.Teams.GetOrCreateWorkspaceConfig(orgID, workspaceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workspace.AccessToken != "xoxb-org" || !workspace.IsActive || !workspace.IncludeGuests {
+		t.Errorf("workspace: token %q, active %v, includes guests %v; want the org's token, active, guests included", workspace.AccessToken, workspace.IsActive, workspace.IncludeGuests)
+	}
+
+	if err := stores.Teams.DeactivateEnterprise(orgID); err != nil {
+		t.Fatal(err)
+	}
+	if workspace, err = stores.Teams.GetTeamConfig(workspaceID); err != nil {
+		t.Fatal(err)
+	}
+	if workspace.IsActive {
+		t.Error("workspace still active after the org was uninstalled")
+	}
+
+	org.AccessToken = encrypt(t, orgID, "xoxb-org-2")
+	if err := stores.Teams.SaveTeamConfig(org); err != nil {
+		t.Fatal(err)
+	}
+	if workspace, err = stores.Teams.GetTeamConfig(workspaceID); err != nil {
+		t.Fatal(err)
+	}
+	if !workspace.IsActive || workspace.AccessToken != "xoxb-org-2" {
+		t.Errorf("workspace after the org reinstalled: active %v, token %q", workspace.IsActive, workspace.AccessToken)
+	}
+
+	if err := stores.Teams.DeactivateEnterprise(orgID); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Teams.PurgeEnterprise(orgID); err != nil {
+		t.Fatal(err)
+	}
+	for _, teamID := range []string{orgID, workspaceID} {
+		if _, err := stores.Teams.GetTeamConfig(teamID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("GetTeamConfig(%s) after purging the enterprise: got %v, want gorm.ErrRecordNotFound", teamID, err)
+		}
+	}
+}
+
+func testStandups(t *testing.T, stores *Stores, id func(string) string) {
+	teamID := id("P")
+	standup, err := stores.Standups.CreateStandup(teamID, "Daily", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if standup.Name != "daily" {
+		t.Errorf("standup name %q, want it lowercased", standup.Name)
+	}
+	if _, err := stores.Standups.CreateStandup(teamID, "daily", "UTC"); err == nil {
+		t.Error("created a second standup with the same name")
+	}
+	if found, err := stores.Standups.GetStandupByName(teamID, "DAILY"); err != nil || found.ID != standup.ID {
+		t.Errorf("GetStandupByName: got %v, %v", found, err)
+	}
+	if _, err := stores.Standups.GetStandupByName(teamID, "weekly"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetStandupByName of a missing standup: got %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	if err := stores.Participants.AddPromptUser(teamID, standup.ID, "U1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Participants.AddPromptUser(teamID, standup.ID, "U1"); err != nil {
+		t.Fatalf("adding a participant twice: %v", err)
+	}
+	added, removed, err := stores.Participants.SyncPromptUsers(teamID, standup.ID, []string{"U2", "U3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 || removed != 1 {
+		t.Errorf("SyncPromptUsers added %d and removed %d, want 2 and 1", added, removed)
+	}
+	users, err := stores.Participants.GetAllPromptUser(standup.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var userIDs []string
+	for _, user := range users {
+		userIDs = append(userIDs, user.UserID)
+	}
+	slices.Sort(userIDs)
+	if !slices.Equal(userIDs, []string{"U2", "U3"}) {
+		t.Errorf("participants %v, want [U2 U3]", userIDs)
+	}
+
+	standups, err := stores.Standups.GetStandupsForUser(teamID, "U2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(standups) != 1 || standups[0].ID != standup.ID {
+		t.Errorf("GetStandupsForUser: got %v", standups)
+	}
+	if err := stores.Participants.RemovePromptUserFromTeam(teamID, "U2"); err != nil {
+		t.Fatal(err)
+	}
+	if standups, _ := stores.Standups.GetStandupsForUser(teamID, "U2"); len(standups) != 0 {
+		t.Errorf("user still in %d standup(s) after being removed from the team", len(standups))
+	}
+}
+
+func testSubmissions(t *testing.T, stores *Stores, id func(string) string) {
+	ctx := context.Background()
+	teamID := id("S")
+	standup, err := stores.Standups.CreateStandup(teamID, "daily", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"first", "second"} {
+		if err := stores.Submissions.SaveUserMessage(teamID, standup.ID, "U1", encrypt(t, teamID, text), "hash-"+text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !stores.Submissions.IsDuplicateMessage(standup.ID, "U1", []string{"hash-first"}, "UTC") {
+		t.Error("IsDuplicateMessage missed an update sent today")
+	}
+	if stores.Submissions.IsDuplicateMessage(standup.ID, "U1", []string{"hash-other"}, "UTC") {
+		t.Error("IsDuplicateMessage matched an update never sent")
+	}
+
+	pending, err := stores.Submissions.GetMessagesForStandupToday(ctx, standup.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].Message != "first" || pending[1].Message != "second" {
+		t.Fatalf("pending updates %v, want first and second decrypted in order", pending)
+	}
+
+	date := time.Now().UTC().Format(time.DateOnly)
+	if err := stores.Submissions.ArchiveMessages(standup.ID, date); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := stores.Submissions.GetMessagesForStandupToday(ctx, standup.ID); len(pending) != 0 {
+		t.Errorf("%d update(s) still pending after archiving", len(pending))
+	}
+	history, err := stores.Submissions.GetHistory(ctx, db.HistoryFilter{StandupID: standup.ID, From: date, To: date})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].SummaryDate != date {
+		t.Errorf("history %v, want both updates dated %s", history, date)
+	}
+
+	run := db.StandupRun{TeamID: teamID, StandupID: standup.ID, Date: date, Status: db.RunStatusFailed, Error: "boom"}
+	if err := stores.Submissions.SaveStandupRun(&run); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := stores.Submissions.GetFailedStandupRuns(date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(failed, func(run db.StandupRun) bool { return run.StandupID == standup.ID }) {
+		t.Error("GetFailedStandupRuns left out the failed run")
+	}
+	run = db.StandupRun{TeamID: teamID, StandupID: standup.ID, Date: date, Status: db.RunStatusPosted, Prompted: 2, Submitted: 1}
+	if err := stores.Submissions.SaveStandupRun(&run); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := stores.Submissions.GetStandupRuns(standup.ID, date, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Status != db.RunStatusPosted || runs[0].Submitted != 1 {
+		t.Errorf("runs %v, want the posted run to replace the failed one", runs)
+	}
+
+	if messages, err := stores.Submissions.GetUserMessages(teamID, "U1"); err != nil || len(messages) != 2 {
+		t.Errorf("GetUserMessages: got %d, %v; want 2", len(messages), err)
+	}
+	deleted, err := stores.Submissions.DeleteUserMessages(teamID, "U1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteUserMessages deleted %d, want 2", deleted)
+	}
+}
+
+func testAPIKeys(t *testing.T, stores *Stores, id func(string) string) {
+	teamID := id("K")
+	key := db.APIKey{TeamID: teamID, Name: "ci", Prefix: id("mb_"), KeyHash: id("hash-"), Scopes: db.APIScopeTeamRead}
+	if err := stores.APIKeys.CreateAPIKey(&key); err != nil {
+		t.Fatal(err)
+	}
+	if key.ID == 0 {
+		t.Error("CreateAPIKey didn't set the key's ID")
+	}
+	found, err := stores.APIKeys.GetAPIKeyByHash(key.KeyHash)
+	if err != nil || found.ID != key.ID {
+		t.Fatalf("GetAPIKeyByHash: got %v, %v", found, err)
+	}
+
+	if err := stores.APIKeys.RevokeAPIKey(teamID, key.Prefix); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.APIKeys.GetAPIKeyByHash(key.KeyHash); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetAPIKeyByHash of a revoked key: got %v, want gorm.ErrRecordNotFound", err)
+	}
+	if keys, _ := stores.APIKeys.GetAPIKeys(teamID); len(keys) != 0 {
+		t.Errorf("GetAPIKeys returned %d revoked key(s)", len(keys))
+	}
+	if err := stores.APIKeys.RevokeAPIKey(teamID, key.Prefix); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("revoking a key twice: got %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func testDataKeys(t *testing.T, stores *Stores, id func(string) string) {
+	teamID := id("D")
+	if _, _, err := stores.Crypto.GetDataKey(teamID); !errors.Is(err, utils.ErrDataKeyNotFound) {
+		t.Fatalf("GetDataKey of a team without one: got %v, want utils.ErrDataKeyNotFound", err)
+	}
+	created, err := stores.Crypto.CreateDataKey(teamID, []byte("wrapped-1"), "k1")
+	if err != nil || !created {
+		t.Fatalf("CreateDataKey: got %v, %v", created, err)
+	}
+	created, err = stores.Crypto.CreateDataKey(teamID, []byte("wrapped-2"), "k2")
+	if err != nil || created {
+		t.Fatalf("CreateDataKey over an existing key: got %v, %v; want false", created, err)
+	}
+	wrapped, masterKeyID, err := stores.Crypto.GetDataKey(teamID)
+	if err != nil || string(wrapped) != "wrapped-1" || masterKeyID != "k1" {
+		t.Errorf("GetDataKey: got %q, %q, %v; want the first key kept", wrapped, masterKeyID, err)
+	}
+
+	if err := stores.Crypto.DeleteDataKey(teamID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := stores.Crypto.GetDataKey(teamID); !errors.Is(err, utils.ErrDataKeyNotFound) {
+		t.Errorf("GetDataKey after DeleteDataKey: got %v, want utils.ErrDataKeyNotFound", err)
+	}
+}
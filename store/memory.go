@@ -0,0 +1,1151 @@
+package store
+
+import (
+	"MidayBrief/db"
+	"MidayBrief/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Memory implements every store in memory. It behaves like the Postgres and
+// Redis stores, including returning gorm.ErrRecordNotFound for missing rows
+// and decrypting tokens and messages on read, so it needs utils.InitCrypto.
+type Memory struct {
+	mu sync.Mutex
+
+	nextID       uint
+	teams        map[string]*db.TeamConfig
+	admins       []db.TeamAdmin
+	audit        []db.AuditEvent
+	standups     map[uint]*db.Standup
+	participants []db.PromptUser
+	messages     []db.UserMessage
+	runs         []db.StandupRun
+	dataKeys     map[string]db.TeamDataKey
+	apiKeys      []db.APIKey
+
+	prompts     map[string]memoryEntry[utils.PromptState]
+	oauthStates map[string]memoryEntry[struct{}]
+	sessions    map[string]memoryEntry[utils.Session]
+	locks       map[string]memoryEntry[string]
+	profiles    map[string]memoryEntry[utils.UserProfile]
+}
+
+type memoryEntry[T any] struct {
+	value     T
+	expiresAt time.Time
+}
+
+func (e memoryEntry[T]) live(now time.Time) bool {
+	return e.expiresAt.IsZero() || now.Before(e.expiresAt)
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		teams:       make(map[string]*db.TeamConfig),
+		standups:    make(map[uint]*db.Standup),
+		dataKeys:    make(map[string]db.TeamDataKey),
+		prompts:     make(map[string]memoryEntry[utils.PromptState]),
+		oauthStates: make(map[string]memoryEntry[struct{}]),
+		sessions:    make(map[string]memoryEntry[utils.Session]),
+		locks:       make(map[string]memoryEntry[string]),
+		profiles:    make(map[string]memoryEntry[utils.UserProfile]),
+	}
+}
+
+// Stores returns m as every store.
+func (m *Memory) Stores() *Stores {
+	return &Stores{Teams: m, Standups: m, Participants: m, Submissions: m, State: m, Crypto: m, APIKeys: m}
+}
+
+func (m *Memory) id() uint {
+	m.nextID++
+	return m.nextID
+}
+
+func notFound(format string, args ...any) error {
+	return fmt.Errorf(format+": %w", append(args, gorm.ErrRecordNotFound)...)
+}
+
+// decryptTokens must be called without m.mu held: loading the team's data
+// key goes through m.
+func decryptTokens(team *db.TeamConfig) error {
+	if err := db.DecryptField("team_configs", "access_token", team.ID, team.TokenTeamID, &team.AccessToken); err != nil {
+		team.DecryptErr = err
+	}
+	if err := db.DecryptField("team_configs", "refresh_token", team.ID, team.TokenTeamID, &team.RefreshToken); err != nil {
+		team.DecryptErr = err
+	}
+	if team.DecryptErr != nil {
+		return team.DecryptErr
+	}
+	return nil
+}
+
+// loadTeam returns a copy of team, with the org's tokens for workspaces
+// covered by an org-wide install. The tokens are still encrypted.
+func (m *Memory) loadTeam(team *db.TeamConfig) db.TeamConfig {
+	loaded := *team
+	loaded.TokenTeamID = team.TeamID
+	if team.EnterpriseID != "" && !team.IsEnterpriseInstall && team.AccessToken == "" {
+		if org, ok := m.teams[team.EnterpriseID]; ok && org.IsEnterpriseInstall {
+			loaded.TokenTeamID = org.TeamID
+			loaded.AccessToken, loaded.RefreshToken, loaded.TokenExpiresAt = org.AccessToken, org.RefreshToken, org.TokenExpiresAt
+			loaded.BotUserID = org.BotUserID
+			loaded.IsActive = team.IsActive && org.IsActive
+		}
+	}
+	return loaded
+}
+
+func (m *Memory) findTeams(match func(*db.TeamConfig) bool) []db.TeamConfig {
+	var teams []db.TeamConfig
+	for _, team := range m.teams {
+		if match(team) {
+			teams = append(teams, *team)
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].ID < teams[j].ID })
+	return teams
+}
+
+func (m *Memory) GetTeamConfig(teamID string) (*db.TeamConfig, error) {
+	m.mu.Lock()
+	team, ok := m.teams[teamID]
+	if !ok {
+		m.mu.Unlock()
+		return nil, notFound("GetTeamConfig: failed to retrieve team %s", teamID)
+	}
+	loaded := m.loadTeam(team)
+	m.mu.Unlock()
+
+	if err := decryptTokens(&loaded); err != nil {
+		return nil, fmt.Errorf("GetTeamConfig: team %s: %w", teamID, err)
+	}
+	return &loaded, nil
+}
+
+func (m *Memory) GetOrCreateWorkspaceConfig(enterpriseID, teamID string) (*db.TeamConfig, error) {
+	if err := m.createWorkspaceConfig(enterpriseID, teamID); err != nil {
+		return nil, err
+	}
+	return m.GetTeamConfig(teamID)
+}
+
+// createWorkspaceConfig adds a config sharing the org's token for a
+// workspace that doesn't have one yet.
+func (m *Memory) createWorkspaceConfig(enterpriseID, teamID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.teams[teamID]; ok || enterpriseID == "" {
+		return nil
+	}
+
+	org, ok := m.teams[enterpriseID]
+	if !ok || !org.IsEnterpriseInstall {
+		return notFound("GetOrCreateWorkspaceConfig: no installation for team %s in enterprise %s", teamID, enterpriseID)
+	}
+	if !org.IsActive {
+		return fmt.Errorf("GetOrCreateWorkspaceConfig: installation for enterprise %s is inactive", enterpriseID)
+	}
+
+	now := time.Now().UTC()
+	workspace := &db.TeamConfig{
+		ID:              m.id(),
+		TeamID:          teamID,
+		EnterpriseID:    enterpriseID,
+		InstallerUserID: org.InstallerUserID,
+		Timezone:        org.Timezone,
+		IncludeGuests:   true,
+		IsActive:        true,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	m.teams[teamID] = workspace
+	return nil
+}
+
+func (m *Memory) GetActiveTeamConfigs() ([]db.TeamConfig, error) {
+	m.mu.Lock()
+	teams := m.findTeams(func(team *db.TeamConfig) bool { return team.IsActive })
+	for i := range teams {
+		teams[i] = m.loadTeam(&teams[i])
+	}
+	m.mu.Unlock()
+
+	usable := teams[:0]
+	for _, team := range teams {
+		if decryptTokens(&team) == nil {
+			usable = append(usable, team)
+		}
+	}
+	return usable, nil
+}
+
+func (m *Memory) GetAllTeamConfigs() ([]db.TeamConfig, error) {
+	m.mu.Lock()
+	teams := m.findTeams(func(*db.TeamConfig) bool { return true })
+	for i := range teams {
+		teams[i] = m.loadTeam(&teams[i])
+	}
+	m.mu.Unlock()
+
+	for i := range teams {
+		decryptTokens(&teams[i])
+	}
+	return teams, nil
+}
+
+// SaveTeamConfig creates the team or, like the Postgres upsert, updates
+// only its installation fields.
+func (m *Memory) SaveTeamConfig(team db.TeamConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+
+	existing, ok := m.teams[team.TeamID]
+	if !ok {
+		team.ID = m.id()
+		team.CreatedAt = now
+		team.UpdatedAt = now
+		team.IsActive = true
+		team.DeactivatedAt = nil
+		// Postgres fills in the column default.
+		team.IncludeGuests = true
+		m.teams[team.TeamID] = &team
+		return nil
+	}
+
+	// Like the Postgres store, reinstalling an org reactivates the
+	// workspaces DeactivateEnterprise turned off with it.
+	if team.IsEnterpriseInstall && !existing.IsActive && existing.DeactivatedAt != nil {
+		deactivatedAt := *existing.DeactivatedAt
+		for _, workspace := range m.teams {
+			if workspace != existing && workspace.EnterpriseID == team.TeamID && workspace.AccessToken == "" && !workspace.IsActive &&
+				workspace.DeactivatedAt != nil && workspace.DeactivatedAt.Equal(deactivatedAt) {
+				workspace.IsActive = true
+				workspace.DeactivatedAt = nil
+				workspace.UpdatedAt = now
+			}
+		}
+	}
+
+	existing.AccessToken = team.AccessToken
+	existing.RefreshToken = team.RefreshToken
+	existing.TokenExpiresAt = team.TokenExpiresAt
+	existing.BotUserID = team.BotUserID
+	existing.InstallerUserID = team.InstallerUserID
+	existing.EnterpriseID = team.EnterpriseID
+	existing.IsEnterpriseInstall = team.IsEnterpriseInstall
+	existing.IsActive = true
+	existing.DeactivatedAt = nil
+	existing.UpdatedAt = now
+	return nil
+}
+
+func (m *Memory) UpdateUserFilter(teamID, column string, include bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	team, ok := m.teams[teamID]
+	if !ok {
+		return nil
+	}
+	switch column {
+	case "include_guests":
+		team.IncludeGuests = include
+	case "include_bots":
+		team.IncludeBots = include
+	case "include_app_users":
+		team.IncludeAppUsers = include
+	default:
+		return fmt.Errorf("UpdateUserFilter: unknown column %s", column)
+	}
+	team.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *Memory) UpdateTeamTokens(teamID, accessToken, refreshToken string, expiresAt *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if team, ok := m.teams[teamID]; ok {
+		team.AccessToken, team.RefreshToken, team.TokenExpiresAt = accessToken, refreshToken, expiresAt
+		team.UpdatedAt = time.Now().UTC()
+	}
+	return nil
+}
+
+func (m *Memory) GetTeamsWithTokensExpiringBefore(cutoff time.Time) ([]db.TeamConfig, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.findTeams(func(team *db.TeamConfig) bool {
+		return team.IsActive && team.RefreshToken != "" && team.TokenExpiresAt != nil && team.TokenExpiresAt.Before(cutoff)
+	}), nil
+}
+
+func (m *Memory) deactivate(team *db.TeamConfig, now time.Time) {
+	team.IsActive = false
+	team.AccessToken = ""
+	team.RefreshToken = ""
+	team.DeactivatedAt = &now
+	team.UpdatedAt = now
+}
+
+func (m *Memory) DeactivateTeam(teamID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if team, ok := m.teams[teamID]; ok {
+		m.deactivate(team, time.Now().UTC())
+	}
+	return nil
+}
+
+func (m *Memory) DeactivateEnterprise(enterpriseID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	for _, team := range m.teams {
+		if team.TeamID == enterpriseID || (team.EnterpriseID == enterpriseID && team.AccessToken == "") {
+			m.deactivate(team, now)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetTeamsDeactivatedBefore(cutoff time.Time) ([]db.TeamConfig, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.findTeams(func(team *db.TeamConfig) bool {
+		return !team.IsActive && team.DeactivatedAt != nil && team.DeactivatedAt.Before(cutoff)
+	}), nil
+}
+
+func (m *Memory) PurgeTeam(teamID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purgeTeam(teamID)
+	return nil
+}
+
+func (m *Memory) purgeTeam(teamID string) {
+	m.messages = slices.DeleteFunc(m.messages, func(msg db.UserMessage) bool { return msg.TeamID == teamID })
+	m.runs = slices.DeleteFunc(m.runs, func(run db.StandupRun) bool { return run.TeamID == teamID })
+	m.participants = slices.DeleteFunc(m.participants, func(user db.PromptUser) bool { return user.TeamID == teamID })
+	m.admins = slices.DeleteFunc(m.admins, func(admin db.TeamAdmin) bool { return admin.TeamID == teamID })
+	m.audit = slices.DeleteFunc(m.audit, func(event db.AuditEvent) bool { return event.TeamID == teamID })
+	delete(m.dataKeys, teamID)
+	utils.ForgetTeamDataKey(teamID)
+	m.apiKeys = slices.DeleteFunc(m.apiKeys, func(key db.APIKey) bool { return key.TeamID == teamID })
+	for id, standup := range m.standups {
+		if standup.TeamID == teamID {
+			delete(m.standups, id)
+		}
+	}
+	delete(m.teams, teamID)
+}
+
+func (m *Memory) PurgeEnterprise(enterpriseID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, team := range m.findTeams(func(team *db.TeamConfig) bool {
+		return team.EnterpriseID == enterpriseID && !team.IsActive && team.TeamID != enterpriseID
+	}) {
+		m.purgeTeam(team.TeamID)
+	}
+	m.purgeTeam(enterpriseID)
+	return nil
+}
+
+func (m *Memory) AddTeamAdmin(teamID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.isTeamAdmin(teamID, userID) {
+		m.admins = append(m.admins, db.TeamAdmin{ID: m.id(), TeamID: teamID, UserID: userID, CreatedAt: time.Now().UTC()})
+	}
+	return nil
+}
+
+func (m *Memory) RemoveTeamAdmin(teamID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, admin := range m.admins {
+		if admin.TeamID == teamID {
+			count++
+		}
+	}
+	if count <= 1 {
+		return fmt.Errorf("RemoveTeamAdmin: can't remove the last admin of team %s", teamID)
+	}
+	m.admins = slices.DeleteFunc(m.admins, func(admin db.TeamAdmin) bool {
+		return admin.TeamID == teamID && admin.UserID == userID
+	})
+	return nil
+}
+
+func (m *Memory) GetTeamAdmins(teamID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var admins []string
+	for _, admin := range m.admins {
+		if admin.TeamID == teamID {
+			admins = append(admins, admin.UserID)
+		}
+	}
+	return admins, nil
+}
+
+func (m *Memory) IsAdmin(team *db.TeamConfig, userID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.isTeamAdmin(team.TeamID, userID) {
+		return true
+	}
+	return team.EnterpriseID != "" && team.EnterpriseID != team.TeamID && m.isTeamAdmin(team.EnterpriseID, userID)
+}
+
+func (m *Memory) IsTeamAdmin(teamID, userID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.isTeamAdmin(teamID, userID)
+}
+
+func (m *Memory) isTeamAdmin(teamID, userID string) bool {
+	return slices.ContainsFunc(m.admins, func(admin db.TeamAdmin) bool {
+		return admin.TeamID == teamID && admin.UserID == userID
+	})
+}
+
+func (m *Memory) RecordAudit(teamID, actorUserID, action, detail string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = append(m.audit, db.AuditEvent{
+		ID:          m.id(),
+		TeamID:      teamID,
+		ActorUserID: actorUserID,
+		Action:      action,
+		Detail:      detail,
+		CreatedAt:   time.Now().UTC(),
+	})
+	return nil
+}
+
+func (m *Memory) GetAuditEvents(teamID string, limit int) ([]db.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []db.AuditEvent
+	for i := len(m.audit) - 1; i >= 0 && len(events) < limit; i-- {
+		if m.audit[i].TeamID == teamID {
+			events = append(events, m.audit[i])
+		}
+	}
+	return events, nil
+}
+
+func (m *Memory) GetUserAuditEvents(teamID, userID string) ([]db.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []db.AuditEvent
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		if m.audit[i].TeamID == teamID && m.audit[i].ActorUserID == userID {
+			events = append(events, m.audit[i])
+		}
+	}
+	return events, nil
+}
+
+func (m *Memory) findStandups(match func(*db.Standup) bool) []db.Standup {
+	var standups []db.Standup
+	for _, standup := range m.standups {
+		if match(standup) {
+			standups = append(standups, *standup)
+		}
+	}
+	sort.Slice(standups, func(i, j int) bool { return standups[i].ID < standups[j].ID })
+	return standups
+}
+
+func (m *Memory) CreateStandup(teamID, name, timezone string) (*db.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = strings.ToLower(name)
+	for _, standup := range m.standups {
+		if standup.TeamID == teamID && standup.Name == name {
+			return nil, fmt.Errorf("CreateStandup: failed to create standup %s for team %s: %w", name, teamID, gorm.ErrDuplicatedKey)
+		}
+	}
+
+	now := time.Now().UTC()
+	standup := &db.Standup{ID: m.id(), TeamID: teamID, Name: name, Timezone: timezone, CreatedAt: now, UpdatedAt: now}
+	m.standups[standup.ID] = standup
+	created := *standup
+	return &created, nil
+}
+
+func (m *Memory) GetStandup(id uint) (*db.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	standup, ok := m.standups[id]
+	if !ok {
+		return nil, notFound("GetStandup: failed to retrieve standup %d", id)
+	}
+	found := *standup
+	return &found, nil
+}
+
+func (m *Memory) GetStandupByName(teamID, name string) (*db.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	standups := m.findStandups(func(standup *db.Standup) bool {
+		return standup.TeamID == teamID && standup.Name == strings.ToLower(name)
+	})
+	if len(standups) == 0 {
+		return nil, notFound("GetStandupByName: failed to retrieve standup %s for team %s", name, teamID)
+	}
+	return &standups[0], nil
+}
+
+func (m *Memory) GetOrCreateDefaultStandup(teamID, timezone string) (*db.Standup, error) {
+	if standup, err := m.GetStandupByName(teamID, db.DefaultStandupName); err == nil {
+		return standup, nil
+	}
+	return m.CreateStandup(teamID, db.DefaultStandupName, timezone)
+}
+
+func (m *Memory) GetStandupsForTeam(teamID string) ([]db.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	standups := m.findStandups(func(standup *db.Standup) bool { return standup.TeamID == teamID })
+	sort.Slice(standups, func(i, j int) bool { return standups[i].Name < standups[j].Name })
+	return standups, nil
+}
+
+func (m *Memory) GetAllStandups() ([]db.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.findStandups(func(*db.Standup) bool { return true }), nil
+}
+
+func (m *Memory) GetStandupsForUser(teamID, userID string) ([]db.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.findStandups(func(standup *db.Standup) bool {
+		return slices.ContainsFunc(m.participants, func(user db.PromptUser) bool {
+			return user.StandupID == standup.ID && user.TeamID == teamID && user.UserID == userID
+		})
+	}), nil
+}
+
+func (m *Memory) DeleteStandup(standup *db.Standup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.participants = slices.DeleteFunc(m.participants, func(user db.PromptUser) bool { return user.StandupID == standup.ID })
+	m.messages = slices.DeleteFunc(m.messages, func(msg db.UserMessage) bool { return msg.StandupID == standup.ID })
+	m.runs = slices.DeleteFunc(m.runs, func(run db.StandupRun) bool { return run.StandupID == standup.ID })
+	delete(m.standups, standup.ID)
+	return nil
+}
+
+func (m *Memory) updateStandup(standupID uint, update func(*db.Standup)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if standup, ok := m.standups[standupID]; ok {
+		update(standup)
+		standup.UpdatedAt = time.Now().UTC()
+	}
+	return nil
+}
+
+func (m *Memory) UpdateChannelID(standupID uint, channelID string) error {
+	return m.updateStandup(standupID, func(s *db.Standup) { s.ChannelID = channelID })
+}
+
+func (m *Memory) UpdatePostTime(standupID uint, postTime string) error {
+	return m.updateStandup(standupID, func(s *db.Standup) { s.PostTime = postTime })
+}
+
+func (m *Memory) UpdatePromptTime(standupID uint, promptTime string) error {
+	return m.updateStandup(standupID, func(s *db.Standup) { s.PromptTime = promptTime })
+}
+
+func (m *Memory) UpdateTimezone(standupID uint, timezone string) error {
+	return m.updateStandup(standupID, func(s *db.Standup) { s.Timezone = timezone })
+}
+
+func (m *Memory) UpdateQuestions(standupID uint, questions []string) error {
+	return m.updateStandup(standupID, func(s *db.Standup) { s.Questions = strings.Join(questions, "\n") })
+}
+
+func (m *Memory) UpdateSyncSource(standupID uint, source, syncID string) error {
+	return m.updateStandup(standupID, func(s *db.Standup) { s.SyncSource, s.SyncID = source, syncID })
+}
+
+func (m *Memory) GetStandupsSyncedTo(teamID, source, syncID string) ([]db.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.findStandups(func(standup *db.Standup) bool {
+		return standup.TeamID == teamID && standup.SyncSource == source && standup.SyncID == syncID
+	}), nil
+}
+
+func (m *Memory) GetSyncedStandups() ([]db.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.findStandups(func(standup *db.Standup) bool { return standup.SyncSource != "" }), nil
+}
+
+func (m *Memory) AddPromptUser(teamID string, standupID uint, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addPromptUser(teamID, standupID, userID)
+	return nil
+}
+
+func (m *Memory) addPromptUser(teamID string, standupID uint, userID string) bool {
+	if slices.ContainsFunc(m.participants, func(user db.PromptUser) bool {
+		return user.StandupID == standupID && user.UserID == userID
+	}) {
+		return false
+	}
+	m.participants = append(m.participants, db.PromptUser{
+		ID:        m.id(),
+		TeamID:    teamID,
+		StandupID: standupID,
+		UserID:    userID,
+		IsActive:  true,
+		CreatedAt: time.Now().UTC(),
+	})
+	return true
+}
+
+func (m *Memory) RemovePromptUser(standupID uint, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.participants = slices.DeleteFunc(m.participants, func(user db.PromptUser) bool {
+		return user.StandupID == standupID && user.UserID == userID
+	})
+	return nil
+}
+
+func (m *Memory) RemovePromptUserFromTeam(teamID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.participants = slices.DeleteFunc(m.participants, func(user db.PromptUser) bool {
+		return user.TeamID == teamID && user.UserID == userID
+	})
+	return nil
+}
+
+func (m *Memory) GetAllPromptUser(standupID uint) ([]db.PromptUser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var users []db.PromptUser
+	for _, user := range m.participants {
+		if user.StandupID == standupID {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (m *Memory) SyncPromptUsers(teamID string, standupID uint, userIDs []string) (added, removed int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := len(m.participants)
+	m.participants = slices.DeleteFunc(m.participants, func(user db.PromptUser) bool {
+		return user.StandupID == standupID && !slices.Contains(userIDs, user.UserID)
+	})
+	removed = before - len(m.participants)
+
+	for _, userID := range userIDs {
+		if m.addPromptUser(teamID, standupID, userID) {
+			added++
+		}
+	}
+	return added, removed, nil
+}
+
+func (m *Memory) SaveUserMessage(teamID string, standupID uint, userID, text, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, db.UserMessage{
+		ID:          m.id(),
+		TeamID:      teamID,
+		StandupID:   standupID,
+		UserID:      userID,
+		Message:     text,
+		MessageHash: hash,
+		Timestamp:   time.Now().UTC(),
+	})
+	return nil
+}
+
+func (m *Memory) GetMessagesForStandupToday(ctx context.Context, standupID uint) ([]db.UserMessage, error) {
+	m.mu.Lock()
+	var messages []db.UserMessage
+	for _, msg := range m.messages {
+		if msg.StandupID == standupID && msg.SummaryDate == "" {
+			messages = append(messages, msg)
+		}
+	}
+	m.mu.Unlock()
+	return decryptMessages(messages), nil
+}
+
+// decryptMessages decrypts messages in place, dropping those that can't be.
+func decryptMessages(messages []db.UserMessage) []db.UserMessage {
+	readable := messages[:0]
+	for _, msg := range messages {
+		if db.DecryptField("user_messages", "message", msg.ID, msg.TeamID, &msg.Message) == nil {
+			readable = append(readable, msg)
+		}
+	}
+	return readable
+}
+
+func (m *Memory) GetHistory(ctx context.Context, filter db.HistoryFilter) ([]db.UserMessage, error) {
+	m.mu.Lock()
+	var messages []db.UserMessage
+	for _, msg := range m.messages {
+		if msg.StandupID != filter.StandupID || msg.SummaryDate == "" ||
+			(filter.UserID != "" && msg.UserID != filter.UserID) ||
+			(filter.From != "" && msg.SummaryDate < filter.From) ||
+			(filter.To != "" && msg.SummaryDate > filter.To) {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	m.mu.Unlock()
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		if messages[i].SummaryDate != messages[j].SummaryDate {
+			return messages[i].SummaryDate > messages[j].SummaryDate
+		}
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+	return decryptMessages(messages), nil
+}
+
+func (m *Memory) SaveStandupRun(run *db.StandupRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	run.UpdatedAt = now
+	for i, existing := range m.runs {
+		if existing.StandupID == run.StandupID && existing.Date == run.Date {
+			run.ID, run.CreatedAt = existing.ID, existing.CreatedAt
+			m.runs[i] = *run
+			return nil
+		}
+	}
+	run.ID, run.CreatedAt = m.id(), now
+	m.runs = append(m.runs, *run)
+	return nil
+}
+
+func (m *Memory) GetStandupRuns(standupID uint, from, to string) ([]db.StandupRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []db.StandupRun
+	for _, run := range m.runs {
+		if run.StandupID == standupID && (from == "" || run.Date >= from) && (to == "" || run.Date <= to) {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Date < runs[j].Date })
+	return runs, nil
+}
+
+func (m *Memory) GetFailedStandupRuns(since string) ([]db.StandupRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []db.StandupRun
+	for _, run := range m.runs {
+		if run.Status == db.RunStatusFailed && run.Date >= since {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].Date != runs[j].Date {
+			return runs[i].Date < runs[j].Date
+		}
+		return runs[i].StandupID < runs[j].StandupID
+	})
+	return runs, nil
+}
+
+func (m *Memory) PruneHistory(cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	date := cutoff.UTC().Format(time.DateOnly)
+	before := len(m.messages) + len(m.runs)
+	m.messages = slices.DeleteFunc(m.messages, func(msg db.UserMessage) bool { return msg.SummaryDate != "" && msg.SummaryDate < date })
+	m.runs = slices.DeleteFunc(m.runs, func(run db.StandupRun) bool { return run.Date < date })
+	return int64(before - len(m.messages) - len(m.runs)), nil
+}
+
+func (m *Memory) ArchiveMessages(standupID uint, date string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.messages {
+		if m.messages[i].StandupID == standupID && m.messages[i].SummaryDate == "" {
+			m.messages[i].SummaryDate = date
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetUserMessages(teamID, userID string) ([]db.UserMessage, error) {
+	m.mu.Lock()
+	var messages []db.UserMessage
+	for _, msg := range m.messages {
+		if msg.TeamID == teamID && msg.UserID == userID {
+			messages = append(messages, msg)
+		}
+	}
+	m.mu.Unlock()
+	return decryptMessages(messages), nil
+}
+
+func (m *Memory) DeleteUserMessages(teamID, userID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := len(m.messages)
+	m.messages = slices.DeleteFunc(m.messages, func(msg db.UserMessage) bool { return msg.TeamID == teamID && msg.UserID == userID })
+	return int64(before - len(m.messages)), nil
+}
+
+func (m *Memory) IsDuplicateMessage(standupID uint, userID string, hashes []string, timezone string) bool {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		slog.Warn("Invalid timezone for duplicate check; defaulting to UTC", "timezone", timezone)
+		location = time.UTC
+	}
+	startOfDay := time.Now().In(location).Truncate(24 * time.Hour).UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.ContainsFunc(m.messages, func(msg db.UserMessage) bool {
+		return msg.StandupID == standupID && msg.UserID == userID && slices.Contains(hashes, msg.MessageHash) && !msg.Timestamp.Before(startOfDay)
+	})
+}
+
+func (m *Memory) GetDataKey(teamID string) ([]byte, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.dataKeys[teamID]
+	if !ok {
+		return nil, "", utils.ErrDataKeyNotFound
+	}
+	return key.WrappedKey, key.MasterKeyID, nil
+}
+
+func (m *Memory) CreateDataKey(teamID string, wrapped []byte, masterKeyID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.dataKeys[teamID]; ok {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	m.dataKeys[teamID] = db.TeamDataKey{ID: m.id(), TeamID: teamID, WrappedKey: wrapped, MasterKeyID: masterKeyID, CreatedAt: now, UpdatedAt: now}
+	return true, nil
+}
+
+func (m *Memory) DeleteDataKey(teamID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.dataKeys, teamID)
+	return nil
+}
+
+// ReencryptStaleCiphertexts must not hold m.mu while encrypting: that may
+// load or create a data key through m.
+func (m *Memory) ReencryptStaleCiphertexts(batchSize int) (db.ReencryptResult, error) {
+	var result db.ReencryptResult
+
+	m.mu.Lock()
+	keys := make([]db.TeamDataKey, 0, len(m.dataKeys))
+	for _, key := range m.dataKeys {
+		keys = append(keys, key)
+	}
+	m.mu.Unlock()
+
+	for _, key := range keys {
+		if key.MasterKeyID == utils.CurrentMasterKeyID() {
+			continue
+		}
+		wrapped, masterKeyID, err := utils.RewrapDataKey(key.WrappedKey, key.MasterKeyID)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		m.mu.Lock()
+		if current, ok := m.dataKeys[key.TeamID]; ok && current.MasterKeyID == key.MasterKeyID {
+			current.WrappedKey, current.MasterKeyID = wrapped, masterKeyID
+			m.dataKeys[key.TeamID] = current
+			result.Rewrapped++
+		}
+		m.mu.Unlock()
+	}
+
+	// Values are re-encrypted outside the lock and only written back if
+	// unchanged meanwhile.
+	type staleValue struct {
+		teamID    string
+		messageID uint
+		field     string
+		value     string
+	}
+	m.mu.Lock()
+	var stale []staleValue
+	for _, team := range m.teams {
+		stale = append(stale,
+			staleValue{teamID: team.TeamID, field: "access_token", value: team.AccessToken},
+			staleValue{teamID: team.TeamID, field: "refresh_token", value: team.RefreshToken})
+	}
+	for _, msg := range m.messages {
+		stale = append(stale, staleValue{teamID: msg.TeamID, messageID: msg.ID, field: "message", value: msg.Message})
+	}
+	m.mu.Unlock()
+
+	for _, v := range stale {
+		if !utils.NeedsReencryption(v.value) {
+			continue
+		}
+		reencrypted, err := utils.ReencryptForTeam(v.teamID, v.value)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+
+		m.mu.Lock()
+		var target *string
+		switch v.field {
+		case "access_token", "refresh_token":
+			if team, ok := m.teams[v.teamID]; ok {
+				target = &team.AccessToken
+				if v.field == "refresh_token" {
+					target = &team.RefreshToken
+				}
+			}
+		case "message":
+			if i := slices.IndexFunc(m.messages, func(msg db.UserMessage) bool { return msg.ID == v.messageID }); i >= 0 {
+				target = &m.messages[i].Message
+			}
+		}
+		if target != nil && *target == v.value {
+			*target = reencrypted
+			result.Reencrypted++
+		}
+		m.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	var unhashed []db.UserMessage
+	for _, msg := range m.messages {
+		if utils.NeedsRehash(msg.MessageHash) {
+			unhashed = append(unhashed, msg)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, msg := range unhashed {
+		text, err := utils.DecryptForTeam(msg.TeamID, msg.Message)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		hash := utils.MessageHash(msg.UserID, text)
+
+		m.mu.Lock()
+		if i := slices.IndexFunc(m.messages, func(stored db.UserMessage) bool { return stored.ID == msg.ID }); i >= 0 && m.messages[i].MessageHash == msg.MessageHash {
+			m.messages[i].MessageHash = hash
+			result.Rehashed++
+		}
+		m.mu.Unlock()
+	}
+	return result, nil
+}
+
+func (m *Memory) GetPromptState(teamID, userID string, ctx context.Context) (*utils.PromptState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.prompts[utils.GetPromptStateKey(teamID, userID)]
+	if !ok || !entry.live(time.Now()) {
+		return nil, fmt.Errorf("GetPromptState: no prompt state for team %s, user %s", teamID, userID)
+	}
+	state := entry.value
+	state.Responses = make(map[string]string, len(entry.value.Responses))
+	for question, answer := range entry.value.Responses {
+		state.Responses[question] = answer
+	}
+	return &state, nil
+}
+
+func (m *Memory) SetPromptState(teamID, userID string, state utils.PromptState, ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompts[utils.GetPromptStateKey(teamID, userID)] = memoryEntry[utils.PromptState]{value: state, expiresAt: time.Now().Add(utils.PromptStateTTL)}
+	return nil
+}
+
+func (m *Memory) DeletePromptState(teamID, userID string, ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.prompts, utils.GetPromptStateKey(teamID, userID))
+	return nil
+}
+
+func (m *Memory) SaveOAuthState(state string, ttl time.Duration, ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.oauthStates[state] = memoryEntry[struct{}]{expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *Memory) ConsumeOAuthState(state string, ctx context.Context) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.oauthStates[state]
+	delete(m.oauthStates, state)
+	return ok && entry.live(time.Now()), nil
+}
+
+func (m *Memory) SaveSession(id string, session utils.Session, ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[id] = memoryEntry[utils.Session]{value: session, expiresAt: time.Now().Add(utils.SessionTTL)}
+	return nil
+}
+
+func (m *Memory) GetSession(id string, ctx context.Context) (*utils.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.sessions[id]
+	if !ok || !entry.live(time.Now()) {
+		return nil, nil
+	}
+	session := entry.value
+	return &session, nil
+}
+
+func (m *Memory) DeleteSession(id string, ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *Memory) AcquireLock(key string, ttl time.Duration, ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if entry, ok := m.locks[key]; ok && entry.live(now) {
+		return "", nil
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	m.locks[key] = memoryEntry[string]{value: token, expiresAt: now.Add(ttl)}
+	return token, nil
+}
+
+func (m *Memory) ReleaseLock(key, token string, ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.locks[key]; ok && entry.value == token {
+		delete(m.locks, key)
+	}
+	return nil
+}
+
+func (m *Memory) GetCachedUserProfile(teamID, userID string, ctx context.Context) (*utils.UserProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.profiles[teamID+":"+userID]
+	if !ok || !entry.live(time.Now()) {
+		return nil, fmt.Errorf("GetCachedUserProfile: no cached profile for team %s, user %s", teamID, userID)
+	}
+	profile := entry.value
+	return &profile, nil
+}
+
+func (m *Memory) CacheUserProfiles(teamID string, profiles []utils.UserProfile, ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expiresAt := time.Now().Add(utils.UserProfileTTL)
+	for _, profile := range profiles {
+		m.profiles[teamID+":"+profile.ID] = memoryEntry[utils.UserProfile]{value: profile, expiresAt: expiresAt}
+	}
+	return nil
+}
+
+func (m *Memory) CreateAPIKey(key *db.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key.ID = m.id()
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now().UTC()
+	}
+	m.apiKeys = append(m.apiKeys, *key)
+	return nil
+}
+
+func (m *Memory) GetAPIKeyByHash(hash string) (*db.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range m.apiKeys {
+		if key.KeyHash == hash && key.RevokedAt == nil {
+			return &key, nil
+		}
+	}
+	return nil, notFound("GetAPIKeyByHash")
+}
+
+func (m *Memory) GetAPIKeys(teamID string) ([]db.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []db.APIKey
+	for _, key := range m.apiKeys {
+		if key.TeamID == teamID && key.RevokedAt == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *Memory) RevokeAPIKey(teamID, prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, key := range m.apiKeys {
+		if key.TeamID == teamID && key.Prefix == prefix && key.RevokedAt == nil {
+			now := time.Now().UTC()
+			m.apiKeys[i].RevokedAt = &now
+			return nil
+		}
+	}
+	return notFound("RevokeAPIKey: team %s has no key %s", teamID, prefix)
+}
+
+func (m *Memory) TouchAPIKey(id uint, usedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.apiKeys {
+		if m.apiKeys[i].ID == id {
+			m.apiKeys[i].LastUsedAt = &usedAt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package store
+
+import (
+	"MidayBrief/db"
+	"MidayBrief/utils"
+	"context"
+	"time"
+)
+
+// Default returns the production stores: Postgres through the db package
+// and Redis through utils. db.Init and utils.InitRedis must have been
+// called before they are used.
+func Default() *Stores {
+	return &Stores{
+		Teams:        postgresTeams{},
+		Standups:     postgresStandups{},
+		Participants: postgresParticipants{},
+		Submissions:  postgresSubmissions{},
+		State:        redisState{},
+		Crypto:       postgresCrypto{},
+		APIKeys:      postgresAPIKeys{},
+	}
+}
+
+type postgresTeams struct{}
+
+func (postgresTeams) GetTeamConfig(teamID string) (*db.TeamConfig, error) {
+	return db.GetTeamConfig(teamID)
+}
+
+func (postgresTeams) GetOrCreateWorkspaceConfig(enterpriseID, teamID string) (*db.TeamConfig, error) {
+	return db.GetOrCreateWorkspaceConfig(enterpriseID, teamID)
+}
+
+func (postgresTeams) GetActiveTeamConfigs() ([]db.TeamConfig, error) {
+	return db.GetActiveTeamConfigs()
+}
+
+func (postgresTeams) GetAllTeamConfigs() ([]db.TeamConfig, error) {
+	return db.GetAllTeamConfigs()
+}
+
+func (postgresTeams) SaveTeamConfig(team db.TeamConfig) error {
+	return db.SaveTeamConfig(team)
+}
+
+func (postgresTeams) UpdateUserFilter(teamID, column string, include bool) error {
+	return db.UpdateUserFilter(teamID, column, include)
+}
+
+func (postgresTeams) UpdateTeamTokens(teamID, accessToken, refreshToken string, expiresAt *time.Time) error {
+	return db.UpdateTeamTokens(teamID, accessToken, refreshToken, expiresAt)
+}
+
+func (postgresTeams) GetTeamsWithTokensExpiringBefore(cutoff time.Time) ([]db.TeamConfig, error) {
+	return db.GetTeamsWithTokensExpiringBefore(cutoff)
+}
+
+func (postgresTeams) DeactivateTeam(teamID string) error {
+	return db.DeactivateTeam(teamID)
+}
+
+func (postgresTeams) DeactivateEnterprise(enterpriseID string) error {
+	return db.DeactivateEnterprise(enterpriseID)
+}
+
+func (postgresTeams) GetTeamsDeactivatedBefore(cutoff time.Time) ([]db.TeamConfig, error) {
+	return db.GetTeamsDeactivatedBefore(cutoff)
+}
+
+func (postgresTeams) PurgeTeam(teamID string) error {
+	return db.PurgeTeam(teamID)
+}
+
+func (postgresTeams) PurgeEnterprise(enterpriseID string) error {
+	return db.PurgeEnterprise(enterpriseID)
+}
+
+func (postgresTeams) AddTeamAdmin(teamID, userID string) error {
+	return db.AddTeamAdmin(teamID, userID)
+}
+
+func (postgresTeams) RemoveTeamAdmin(teamID, userID string) error {
+	return db.RemoveTeamAdmin(teamID, userID)
+}
+
+func (postgresTeams) GetTeamAdmins(teamID string) ([]string, error) {
+	return db.GetTeamAdmins(teamID)
+}
+
+func (postgresTeams) IsAdmin(team *db.TeamConfig, userID string) bool {
+	return db.IsAdmin(team, userID)
+}
+
+func (postgresTeams) IsTeamAdmin(teamID, userID string) bool {
+	return db.IsTeamAdmin(teamID, userID)
+}
+
+func (postgresTeams) RecordAudit(teamID, actorUserID, action, detail string) error {
+	return db.RecordAudit(teamID, actorUserID, action, detail)
+}
+
+func (postgresTeams) GetAuditEvents(teamID string, limit int) ([]db.AuditEvent, error) {
+	return db.GetAuditEvents(teamID, limit)
+}
+
+func (postgresTeams) GetUserAuditEvents(teamID, userID string) ([]db.AuditEvent, error) {
+	return db.GetUserAuditEvents(teamID, userID)
+}
+
+type postgresStandups struct{}
+
+func (postgresStandups) CreateStandup(teamID, name, timezone string) (*db.Standup, error) {
+	return db.CreateStandup(teamID, name, timezone)
+}
+
+func (postgresStandups) GetStandup(id uint) (*db.Standup, error) {
+	return db.GetStandup(id)
+}
+
+func (postgresStandups) GetStandupByName(teamID, name string) (*db.Standup, error) {
+	return db.GetStandupByName(teamID, name)
+}
+
+func (postgresStandups) GetOrCreateDefaultStandup(teamID, timezone string) (*db.Standup, error) {
+	return db.GetOrCreateDefaultStandup(teamID, timezone)
+}
+
+func (postgresStandups) GetStandupsForTeam(teamID string) ([]db.Standup, error) {
+	return db.GetStandupsForTeam(teamID)
+}
+
+func (postgresStandups) GetAllStandups() ([]db.Standup, error) {
+	return db.GetAllStandups()
+}
+
+func (postgresStandups) GetStandupsForUser(teamID, userID string) ([]db.Standup, error) {
+	return db.GetStandupsForUser(teamID, userID)
+}
+
+func (postgresStandups) DeleteStandup(standup *db.Standup) error {
+	return db.DeleteStandup(standup)
+}
+
+func (postgresStandups) UpdateChannelID(standupID uint, channelID string) error {
+	return db.UpdateChannelID(standupID, channelID)
+}
+
+func (postgresStandups) UpdatePostTime(standupID uint, postTime string) error {
+	return db.UpdatePostTime(standupID, postTime)
+}
+
+func (postgresStandups) UpdatePromptTime(standupID uint, promptTime string) error {
+	return db.UpdatePromptTime(standupID, promptTime)
+}
+
+func (postgresStandups) UpdateTimezone(standupID uint, timezone string) error {
+	return db.UpdateTimezone(standupID, timezone)
+}
+
+func (postgresStandups) UpdateQuestions(standupID uint, questions []string) error {
+	return db.UpdateQuestions(standupID, questions)
+}
+
+func (postgresStandups) UpdateSyncSource(standupID uint, source, syncID string) error {
+	return db.UpdateSyncSource(standupID, source, syncID)
+}
+
+func (postgresStandups) GetStandupsSyncedTo(teamID, source, syncID string) ([]db.Standup, error) {
+	return db.GetStandupsSyncedTo(teamID, source, syncID)
+}
+
+func (postgresStandups) GetSyncedStandups() ([]db.Standup, error) {
+	return db.GetSyncedStandups()
+}
+
+type postgresParticipants struct{}
+
+func (postgresParticipants) AddPromptUser(teamID string, standupID uint, userID string) error {
+	return db.AddPromptUser(teamID, standupID, userID)
+}
+
+func (postgresParticipants) RemovePromptUser(standupID uint, userID string) error {
+	return db.RemovePromptUser(standupID, userID)
+}
+
+func (postgresParticipants) RemovePromptUserFromTeam(teamID, userID string) error {
+	return db.RemovePromptUserFromTeam(teamID, userID)
+}
+
+func (postgresParticipants) GetAllPromptUser(standupID uint) ([]db.PromptUser, error) {
+	return db.GetAllPromptUser(standupID)
+}
+
+func (postgresParticipants) SyncPromptUsers(teamID string, standupID uint, userIDs []string) (int, int, error) {
+	return db.SyncPromptUsers(teamID, standupID, userIDs)
+}
+
+type postgresSubmissions struct{}
+
+func (postgresSubmissions) SaveUserMessage(teamID string, standupID uint, userID, text, hash string) error {
+	return db.SaveUserMessage(teamID, standupID, userID, text, hash)
+}
+
+func (postgresSubmissions) GetMessagesForStandupToday(ctx context.Context, standupID uint) ([]db.UserMessage, error) {
+	return db.GetMessagesForStandupToday(ctx, standupID)
+}
+
+func (postgresSubmissions) ArchiveMessages(standupID uint, date string) error {
+	return db.ArchiveMessages(standupID, date)
+}
+
+func (postgresSubmissions) IsDuplicateMessage(standupID uint, userID string, hashes []string, timezone string) bool {
+	return db.IsDuplicateMessage(standupID, userID, hashes, timezone)
+}
+
+func (postgresSubmissions) GetUserMessages(teamID, userID string) ([]db.UserMessage, error) {
+	return db.GetUserMessages(teamID, userID)
+}
+
+func (postgresSubmissions) DeleteUserMessages(teamID, userID string) (int64, error) {
+	return db.DeleteUserMessages(teamID, userID)
+}
+
+func (postgresSubmissions) GetHistory(ctx context.Context, filter db.HistoryFilter) ([]db.UserMessage, error) {
+	return db.GetHistory(ctx, filter)
+}
+
+func (postgresSubmissions) SaveStandupRun(run *db.StandupRun) error {
+	return db.SaveStandupRun(run)
+}
+
+func (postgresSubmissions) GetStandupRuns(standupID uint, from, to string) ([]db.StandupRun, error) {
+	return db.GetStandupRuns(standupID, from, to)
+}
+
+func (postgresSubmissions) GetFailedStandupRuns(since string) ([]db.StandupRun, error) {
+	return db.GetFailedStandupRuns(since)
+}
+
+func (postgresSubmissions) PruneHistory(cutoff time.Time) (int64, error) {
+	return db.PruneHistory(cutoff)
+}
+
+type postgresCrypto struct{}
+
+func (postgresCrypto) GetDataKey(teamID string) ([]byte, string, error) {
+	return db.GetDataKey(teamID)
+}
+
+func (postgresCrypto) CreateDataKey(teamID string, wrapped []byte, masterKeyID string) (bool, error) {
+	return db.CreateDataKey(teamID, wrapped, masterKeyID)
+}
+
+func (postgresCrypto) DeleteDataKey(teamID string) error {
+	return db.DeleteDataKey(teamID)
+}
+
+func (postgresCrypto) ReencryptStaleCiphertexts(batchSize int) (db.ReencryptResult, error) {
+	return db.ReencryptStaleCiphertexts(batchSize)
+}
+
+type redisState struct{}
+
+func (redisState) GetPromptState(teamID, userID string, ctx context.Context) (*utils.PromptState, error) {
+	return utils.GetPromptState(teamID, userID, ctx)
+}
+
+func (redisState) SetPromptState(teamID, userID string, state utils.PromptState, ctx context.Context) error {
+	return utils.SetPromptState(teamID, userID, state, ctx)
+}
+
+func (redisState) DeletePromptState(teamID, userID string, ctx context.Context) error {
+	return utils.DeletePromptState(teamID, userID, ctx)
+}
+
+func (redisState) SaveOAuthState(state string, ttl time.Duration, ctx context.Context) error {
+	return utils.SaveOAuthState(state, ttl, ctx)
+}
+
+func (redisState) ConsumeOAuthState(state string, ctx context.Context) (bool, error) {
+	return utils.ConsumeOAuthState(state, ctx)
+}
+
+func (redisState) SaveSession(id string, session utils.Session, ctx context.Context) error {
+	return utils.SaveSession(id, session, ctx)
+}
+
+func (redisState) GetSession(id string, ctx context.Context) (*utils.Session, error) {
+	return utils.GetSession(id, ctx)
+}
+
+func (redisState) DeleteSession(id string, ctx context.Context) error {
+	return utils.DeleteSession(id, ctx)
+}
+
+func (redisState) AcquireLock(key string, ttl time.Duration, ctx context.Context) (string, error) {
+	return utils.AcquireLock(key, ttl, ctx)
+}
+
+func (redisState) ReleaseLock(key, token string, ctx context.Context) error {
+	return utils.ReleaseLock(key, token, ctx)
+}
+
+func (redisState) GetCachedUserProfile(teamID, userID string, ctx context.Context) (*utils.UserProfile, error) {
+	return utils.GetCachedUserProfile(teamID, userID, ctx)
+}
+
+func (redisState) CacheUserProfiles(teamID string, profiles []utils.UserProfile, ctx context.Context) error {
+	return utils.CacheUserProfiles(teamID, profiles, ctx)
+}
+
+type postgresAPIKeys struct{}
+
+func (postgresAPIKeys) CreateAPIKey(key *db.APIKey) error {
+	return db.CreateAPIKey(key)
+}
+
+func (postgresAPIKeys) GetAPIKeyByHash(hash string) (*db.APIKey, error) {
+	return db.GetAPIKeyByHash(hash)
+}
+
+func (postgresAPIKeys) GetAPIKeys(teamID string) ([]db.APIKey, error) {
+	return db.GetAPIKeys(teamID)
+}
+
+func (postgresAPIKeys) RevokeAPIKey(teamID, prefix string) error {
+	return db.RevokeAPIKey(teamID, prefix)
+}
+
+func (postgresAPIKeys) TouchAPIKey(id uint, usedAt time.Time) error {
+	return db.TouchAPIKey(id, usedAt)
+}
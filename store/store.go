@@ -0,0 +1,129 @@
+// Package store defines the storage the API handlers and scheduler depend
+// on, so they can run against Postgres and Redis in production and against
+// in-memory replacements in tests and local development.
+package store
+
+import (
+	"MidayBrief/db"
+	"MidayBrief/utils"
+	"context"
+	"time"
+)
+
+// Stores groups the repositories the application uses.
+type Stores struct {
+	Teams        TeamStore
+	Standups     StandupStore
+	Participants ParticipantStore
+	Submissions  SubmissionStore
+	State        StateStore
+	Crypto       CryptoStore
+	APIKeys      APIKeyStore
+}
+
+// TeamStore holds installations, their admins and the audit trail.
+// Tokens are passed in encrypted and returned decrypted.
+type TeamStore interface {
+	GetTeamConfig(teamID string) (*db.TeamConfig, error)
+	GetOrCreateWorkspaceConfig(enterpriseID, teamID string) (*db.TeamConfig, error)
+	GetActiveTeamConfigs() ([]db.TeamConfig, error)
+	GetAllTeamConfigs() ([]db.TeamConfig, error)
+	SaveTeamConfig(team db.TeamConfig) error
+	UpdateUserFilter(teamID, column string, include bool) error
+	UpdateTeamTokens(teamID, accessToken, refreshToken string, expiresAt *time.Time) error
+	GetTeamsWithTokensExpiringBefore(cutoff time.Time) ([]db.TeamConfig, error)
+	DeactivateTeam(teamID string) error
+	DeactivateEnterprise(enterpriseID string) error
+	GetTeamsDeactivatedBefore(cutoff time.Time) ([]db.TeamConfig, error)
+	PurgeTeam(teamID string) error
+	PurgeEnterprise(enterpriseID string) error
+
+	AddTeamAdmin(teamID, userID string) error
+	RemoveTeamAdmin(teamID, userID string) error
+	GetTeamAdmins(teamID string) ([]string, error)
+	IsAdmin(team *db.TeamConfig, userID string) bool
+	IsTeamAdmin(teamID, userID string) bool
+	RecordAudit(teamID, actorUserID, action, detail string) error
+	GetAuditEvents(teamID string, limit int) ([]db.AuditEvent, error)
+	GetUserAuditEvents(teamID, userID string) ([]db.AuditEvent, error)
+}
+
+type StandupStore interface {
+	CreateStandup(teamID, name, timezone string) (*db.Standup, error)
+	GetStandup(id uint) (*db.Standup, error)
+	GetStandupByName(teamID, name string) (*db.Standup, error)
+	GetOrCreateDefaultStandup(teamID, timezone string) (*db.Standup, error)
+	GetStandupsForTeam(teamID string) ([]db.Standup, error)
+	GetAllStandups() ([]db.Standup, error)
+	GetStandupsForUser(teamID, userID string) ([]db.Standup, error)
+	DeleteStandup(standup *db.Standup) error
+	UpdateChannelID(standupID uint, channelID string) error
+	UpdatePostTime(standupID uint, postTime string) error
+	UpdatePromptTime(standupID uint, promptTime string) error
+	UpdateTimezone(standupID uint, timezone string) error
+	UpdateQuestions(standupID uint, questions []string) error
+	UpdateSyncSource(standupID uint, source, syncID string) error
+	GetStandupsSyncedTo(teamID, source, syncID string) ([]db.Standup, error)
+	GetSyncedStandups() ([]db.Standup, error)
+}
+
+type ParticipantStore interface {
+	AddPromptUser(teamID string, standupID uint, userID string) error
+	RemovePromptUser(standupID uint, userID string) error
+	RemovePromptUserFromTeam(teamID, userID string) error
+	GetAllPromptUser(standupID uint) ([]db.PromptUser, error)
+	SyncPromptUsers(teamID string, standupID uint, userIDs []string) (added, removed int, err error)
+}
+
+// SubmissionStore holds standup answers and, once summarized, their
+// history. Messages are passed in encrypted and returned decrypted.
+type SubmissionStore interface {
+	SaveUserMessage(teamID string, standupID uint, userID, text, hash string) error
+	GetMessagesForStandupToday(ctx context.Context, standupID uint) ([]db.UserMessage, error)
+	ArchiveMessages(standupID uint, date string) error
+	IsDuplicateMessage(standupID uint, userID string, hashes []string, timezone string) bool
+	GetUserMessages(teamID, userID string) ([]db.UserMessage, error)
+	DeleteUserMessages(teamID, userID string) (int64, error)
+
+	GetHistory(ctx context.Context, filter db.HistoryFilter) ([]db.UserMessage, error)
+	SaveStandupRun(run *db.StandupRun) error
+	GetStandupRuns(standupID uint, from, to string) ([]db.StandupRun, error)
+	GetFailedStandupRuns(since string) ([]db.StandupRun, error)
+	PruneHistory(cutoff time.Time) (int64, error)
+}
+
+// StateStore holds short-lived state: in-progress prompts, OAuth state
+// values, dashboard sessions, locks and cached user profiles.
+type StateStore interface {
+	GetPromptState(teamID, userID string, ctx context.Context) (*utils.PromptState, error)
+	SetPromptState(teamID, userID string, state utils.PromptState, ctx context.Context) error
+	DeletePromptState(teamID, userID string, ctx context.Context) error
+	SaveOAuthState(state string, ttl time.Duration, ctx context.Context) error
+	ConsumeOAuthState(state string, ctx context.Context) (bool, error)
+	SaveSession(id string, session utils.Session, ctx context.Context) error
+	GetSession(id string, ctx context.Context) (*utils.Session, error)
+	DeleteSession(id string, ctx context.Context) error
+	AcquireLock(key string, ttl time.Duration, ctx context.Context) (string, error)
+	ReleaseLock(key, token string, ctx context.Context) error
+	GetCachedUserProfile(teamID, userID string, ctx context.Context) (*utils.UserProfile, error)
+	CacheUserProfiles(teamID string, profiles []utils.UserProfile, ctx context.Context) error
+}
+
+// APIKeyStore holds the keys that authenticate admin API requests. Keys
+// are looked up by the hash of their secret.
+type APIKeyStore interface {
+	CreateAPIKey(key *db.APIKey) error
+	GetAPIKeyByHash(hash string) (*db.APIKey, error)
+	GetAPIKeys(teamID string) ([]db.APIKey, error)
+	RevokeAPIKey(teamID, prefix string) error
+	TouchAPIKey(id uint, usedAt time.Time) error
+}
+
+// CryptoStore holds team data keys and maintains encrypted data at rest.
+type CryptoStore interface {
+	utils.DataKeyStore
+
+	// ReencryptStaleCiphertexts moves data encrypted with an old key to the
+	// primary key.
+	ReencryptStaleCiphertexts(batchSize int) (db.ReencryptResult, error)
+}
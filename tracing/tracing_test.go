@@ -0,0 +1,182 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"MidayBrief/api"
+	"MidayBrief/config"
+	"MidayBrief/db"
+	"MidayBrief/scheduler"
+	"MidayBrief/slack"
+	"MidayBrief/slacktest"
+	"MidayBrief/store"
+	"MidayBrief/tracing"
+	"MidayBrief/utils"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider that keeps every ended span.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+// findSpan returns the ended span named name, failing the test if there is
+// none.
+func findSpan(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	var names []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+	}
+	t.Fatalf("no span %q; got %q", name, names)
+	return nil
+}
+
+func assertChild(t *testing.T, child, parent sdktrace.ReadOnlySpan) {
+	t.Helper()
+	if child.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span %q isn't a child of %q", child.Name(), parent.Name())
+	}
+}
+
+func assertStatus(t *testing.T, span sdktrace.ReadOnlySpan, want codes.Code) {
+	t.Helper()
+	if got := span.Status().Code; got != want {
+		t.Errorf("span %q has status %v, want %v", span.Name(), got, want)
+	}
+}
+
+func TestMiddlewareNamesSpansAfterRoutes(t *testing.T) {
+	recorder := recordSpans(t)
+
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Get("/teams/{teamID}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "handler")
+		tracing.End(span, errors.New("boom"))
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/teams/T1", nil))
+
+	server := findSpan(t, recorder, "GET /teams/{teamID}")
+	assertStatus(t, server, codes.Error)
+	if server.Parent().IsValid() {
+		t.Errorf("server span has parent %s, want none", server.Parent().SpanID())
+	}
+	handler := findSpan(t, recorder, "handler")
+	assertChild(t, handler, server)
+	assertStatus(t, handler, codes.Error)
+}
+
+func TestTransportTracesSlackCalls(t *testing.T) {
+	recorder := recordSpans(t)
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	client := slack.New(slack.WithBaseURL(srv.URL), slack.WithoutRateLimit(), slack.WithMaxRetries(0),
+		slack.WithTransport(tracing.Transport(http.DefaultTransport, "slack")))
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	if _, err := client.PostMessage(ctx, "xoxb-1", "C1", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	srv.RateLimitNext("chat.postMessage", time.Second)
+	if _, err := client.PostMessage(ctx, "xoxb-1", "C1", "hello"); err == nil {
+		t.Fatal("rate-limited post succeeded")
+	}
+	parent.End()
+
+	var calls []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "slack chat.postMessage" {
+			calls = append(calls, span)
+		}
+	}
+	if len(calls) != 2 {
+		t.Fatalf("got %d chat.postMessage spans, want 2", len(calls))
+	}
+	for _, call := range calls {
+		assertChild(t, call, findSpan(t, recorder, "parent"))
+	}
+	assertStatus(t, calls[0], codes.Unset)
+	assertStatus(t, calls[1], codes.Error)
+}
+
+func TestSchedulerJobSpans(t *testing.T) {
+	recorder := recordSpans(t)
+	if err := utils.InitCrypto(config.Crypto{LegacyKey: "0123456789abcdef0123456789abcdef"}); err != nil {
+		t.Fatal(err)
+	}
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	api.SetSlackClient(slack.New(slack.WithBaseURL(srv.URL), slack.WithoutRateLimit(), slack.WithMaxRetries(0),
+		slack.WithTransport(tracing.Transport(http.DefaultTransport, "slack"))))
+	mem := store.NewMemory()
+	api.SetStores(mem.Stores())
+	scheduler.SetStores(mem.Stores())
+	utils.SetDataKeyStore(mem)
+
+	token, err := utils.EncryptForTeam("T1", "xoxb-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.SaveTeamConfig(db.TeamConfig{TeamID: "T1", AccessToken: token}); err != nil {
+		t.Fatal(err)
+	}
+	team, err := mem.GetTeamConfig("T1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	standup, err := mem.CreateStandup("T1", db.DefaultStandupName, "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	standup.ChannelID = "C1"
+	message, err := utils.EncryptForTeam("T1", "shipped it")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.SaveUserMessage("T1", standup.ID, "U1", message, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.RateLimitNext("chat.postMessage", time.Second)
+	today := time.Now().UTC().Format(time.DateOnly)
+	if _, err := scheduler.PostSummary(context.Background(), *team, *standup, today); err == nil {
+		t.Fatal("summary posted despite the rate limit")
+	}
+
+	job := findSpan(t, recorder, "scheduler.summary")
+	assertStatus(t, job, codes.Error)
+	if job.Parent().IsValid() {
+		t.Errorf("job span has parent %s, want none", job.Parent().SpanID())
+	}
+	assertChild(t, findSpan(t, recorder, "scheduler.load_updates"), job)
+	post := findSpan(t, recorder, "slack chat.postMessage")
+	assertChild(t, post, job)
+	assertStatus(t, post, codes.Error)
+}
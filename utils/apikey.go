@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// apiKeyTag starts every admin API key so a leaked one is easy to spot.
+const apiKeyTag = "mbk_"
+
+// NewAPIKey generates an admin API key. It returns the key, which is shown
+// once, the prefix that identifies it afterwards and the hash to store.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", fmt.Errorf("NewAPIKey: %w", err)
+	}
+	secret := hex.EncodeToString(buf)
+	key = apiKeyTag + secret
+	return key, secret[:8], HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hash an API key is stored and looked up by. Keys
+// are random enough that an unsalted hash can't be reversed.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(key)))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,80 +1,160 @@
 package utils
 
 import (
+	"MidayBrief/config"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/hex"
 	"errors"
-	"io"
-	"os"
+	"fmt"
+	"strings"
 )
 
-var secretKey []byte
+// legacyKeyID names ENCRYPTION_KEY in the keyring. Ciphertexts written
+// before key IDs existed carry no prefix and are decrypted with it.
+const legacyKeyID = config.LegacyKeyID
 
-func InitCrypto() {
-	key := os.Getenv("ENCRYPTION_KEY")
-	if len(key) != 32 {
-		panic("ENCRYPTION_SECRET must be 32 bytes long (AES-256)")
+var (
+	keys         map[string][]byte
+	primaryKeyID string
+)
+
+var ErrUnknownKeyID = errors.New("ciphertext was encrypted with an unknown key")
+
+// InitCrypto loads the encryption keyring and the message hash keys from
+// cfg and sets the key provider that wraps team data keys. The key formats
+// are checked by config.Validate; InitCrypto only fails on what it can't load.
+func InitCrypto(cfg config.Crypto) error {
+	if err := LoadKeys(cfg.Keys, cfg.KeyID, cfg.LegacyKey); err != nil {
+		return fmt.Errorf("InitCrypto: %w", err)
+	}
+	if err := LoadHashKeys(cfg.HashKeys, cfg.HashKeyID); err != nil {
+		return fmt.Errorf("InitCrypto: %w", err)
+	}
+	switch cfg.KMSProvider {
+	case "":
+		SetKeyProvider(LocalKeyProvider{})
+	case config.KMSProviderVault:
+		SetKeyProvider(NewKMSKeyProvider(NewVaultTransitClient(cfg.VaultAddr, cfg.VaultToken), cfg.KMSKeyID))
+	default:
+		return fmt.Errorf("InitCrypto: unknown KMS_PROVIDER %q", cfg.KMSProvider)
 	}
-	secretKey = []byte(key)
+	return nil
 }
 
-func Encrypt(plainText string) (string, error) {
-	block, err := aes.NewCipher(secretKey)
-	if err != nil {
-		return "", err
+// LoadKeys replaces the keyring; see InitCrypto for the formats.
+func LoadKeys(keyList, primaryID, legacyKey string) error {
+	loaded := make(map[string][]byte)
+	var lastID string
+
+	if legacyKey != "" {
+		key, err := config.ParseKey(legacyKey)
+		if err != nil {
+			return fmt.Errorf("ENCRYPTION_KEY: %w", err)
+		}
+		loaded[legacyKeyID] = key
+		lastID = legacyKeyID
 	}
 
-	aesGCM, err := cipher.NewGCM(block)
+	entries, err := config.ParseKeyList("ENCRYPTION_KEYS", keyList)
 	if err != nil {
-		return "", err
+		return err
+	}
+	for _, entry := range entries {
+		if entry.ID == config.ReservedKeyID {
+			return fmt.Errorf("ENCRYPTION_KEYS: the key id %s is reserved", config.ReservedKeyID)
+		}
+		loaded[entry.ID] = entry.Key
+		lastID = entry.ID
 	}
 
-	nonce := make([]byte, aesGCM.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
+	if len(loaded) == 0 {
+		return errors.New("no encryption key configured: set ENCRYPTION_KEYS or ENCRYPTION_KEY")
+	}
+	if primaryID == "" {
+		primaryID = lastID
+	}
+	if _, ok := loaded[primaryID]; !ok {
+		return fmt.Errorf("ENCRYPTION_KEY_ID %s is not in the keyring", primaryID)
 	}
 
-	cipherText := aesGCM.Seal(nonce, nonce, []byte(plainText), nil)
-	return base64.StdEncoding.EncodeToString(cipherText), nil
+	keys, primaryKeyID = loaded, primaryID
+	return nil
 }
 
-func Decrypt(encrypted string) (string, error) {
-	cipherData, err := base64.StdEncoding.DecodeString(encrypted)
-	if err != nil {
-		return "", err
+// CheckKeys reports whether the encryption and hash keys are loaded and
+// the primary key can round-trip a value.
+func CheckKeys() error {
+	if keys[primaryKeyID] == nil {
+		return errors.New("no encryption key loaded")
 	}
-
-	block, err := aes.NewCipher(secretKey)
+	if hashKeys[primaryHashKeyID] == nil {
+		return errors.New("no message hash key loaded")
+	}
+	encrypted, err := Encrypt("readiness")
 	if err != nil {
-		return "", err
+		return fmt.Errorf("CheckKeys: %w", err)
+	}
+	if plain, err := Decrypt(encrypted); err != nil || plain != "readiness" {
+		return fmt.Errorf("CheckKeys: primary key %s failed to round-trip: %v", primaryKeyID, err)
 	}
+	return nil
+}
 
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
+// PrimaryKeyID is the ID of the key new data is encrypted with.
+func PrimaryKeyID() string {
+	return primaryKeyID
+}
+
+// KeyID returns the ID of the key encrypted was written with.
+func KeyID(encrypted string) string {
+	if id, _, ok := strings.Cut(encrypted, ":"); ok {
+		return id
 	}
+	return legacyKeyID
+}
 
-	nonceSize := aesGCM.NonceSize()
-	if len(cipherData) < nonceSize {
-		return "", errors.New("cipher text too short")
+// CiphertextPrefix is how values written now start: with the data key
+// marker when per-team data keys are enabled, otherwise the primary key ID.
+func CiphertextPrefix() string {
+	if dataKeyStore != nil {
+		return dataKeyPrefix
 	}
+	return primaryKeyID + ":"
+}
 
-	nonce := cipherData[:nonceSize]
-	cipherText := cipherData[nonceSize:]
+// NeedsReencryption reports whether encrypted was written with a retired
+// key, or with the keyring directly when per-team data keys are enabled.
+// Empty values never need it.
+func NeedsReencryption(encrypted string) bool {
+	return encrypted != "" && !strings.HasPrefix(encrypted, CiphertextPrefix()) && !strings.HasPrefix(encrypted, dataKeyPrefix)
+}
 
-	plainText, err := aesGCM.Open(nil, nonce, cipherText, nil)
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return cipher.NewGCM(block)
+}
 
-	return string(plainText), nil
+// Encrypt seals plainText with the primary key. The result is the key ID,
+// a colon and the base64 nonce and ciphertext.
+func Encrypt(plainText string) (string, error) {
+	sealed, err := seal(keys[primaryKeyID], plainText)
+	if err != nil {
+		return "", err
+	}
+	return primaryKeyID + ":" + sealed, nil
 }
 
-func Hash(text string) string {
-	sum := sha256.Sum256([]byte(text))
-	return hex.EncodeToString(sum[:])
+func Decrypt(encrypted string) (string, error) {
+	keyID := KeyID(encrypted)
+	key, ok := keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKeyID, keyID)
+	}
+	if _, rest, ok := strings.Cut(encrypted, ":"); ok {
+		encrypted = rest
+	}
+	return open(key, encrypted)
 }
@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"MidayBrief/config"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dataKeyPrefix marks values encrypted with their team's data key rather
+// than directly with a key from the keyring.
+const dataKeyPrefix = config.ReservedKeyID + ":"
+
+// dataKeyCacheTTL bounds how long an unwrapped data key is kept in memory,
+// and so how long another instance may keep using a destroyed key.
+const dataKeyCacheTTL = 10 * time.Minute
+
+const keyProviderTimeout = 5 * time.Second
+
+var ErrDataKeyDestroyed = errors.New("team data key has been destroyed")
+
+// KeyProvider wraps and unwraps team data keys with a master key it
+// manages.
+type KeyProvider interface {
+	// WrapKey encrypts dataKey and returns it with the ID of the master key
+	// used.
+	WrapKey(ctx context.Context, dataKey []byte) (wrapped []byte, masterKeyID string, err error)
+	UnwrapKey(ctx context.Context, wrapped []byte, masterKeyID string) ([]byte, error)
+	// CurrentKeyID is the master key new data keys are wrapped with; data
+	// keys wrapped with another one are re-wrapped by the re-encryption job.
+	CurrentKeyID() string
+}
+
+// DataKeyStore persists wrapped team data keys.
+type DataKeyStore interface {
+	// GetDataKey returns ErrDataKeyNotFound when the team has no key.
+	GetDataKey(teamID string) (wrapped []byte, masterKeyID string, err error)
+	// CreateDataKey stores the team's key unless it already has one, and
+	// reports whether it did.
+	CreateDataKey(teamID string, wrapped []byte, masterKeyID string) (bool, error)
+	DeleteDataKey(teamID string) error
+}
+
+var ErrDataKeyNotFound = errors.New("team has no data key")
+
+type cachedDataKey struct {
+	key       []byte
+	expiresAt time.Time
+}
+
+var (
+	keyProvider  KeyProvider = LocalKeyProvider{}
+	dataKeyStore DataKeyStore
+
+	dataKeyMu    sync.Mutex
+	dataKeyCache = make(map[string]cachedDataKey)
+)
+
+// SetKeyProvider replaces the master key provider, LocalKeyProvider by
+// default.
+func SetKeyProvider(provider KeyProvider) {
+	keyProvider = provider
+	clearDataKeyCache()
+}
+
+// SetDataKeyStore enables per-team data keys. Without one, EncryptForTeam
+// falls back to encrypting with the keyring directly.
+func SetDataKeyStore(store DataKeyStore) {
+	dataKeyStore = store
+	clearDataKeyCache()
+}
+
+func clearDataKeyCache() {
+	dataKeyMu.Lock()
+	defer dataKeyMu.Unlock()
+	dataKeyCache = make(map[string]cachedDataKey)
+}
+
+// teamDataKey returns the team's unwrapped data key, creating one on first
+// use when create is set.
+func teamDataKey(teamID string, create bool) ([]byte, error) {
+	dataKeyMu.Lock()
+	cached, ok := dataKeyCache[teamID]
+	dataKeyMu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyProviderTimeout)
+	defer cancel()
+
+	wrapped, masterKeyID, err := dataKeyStore.GetDataKey(teamID)
+	if errors.Is(err, ErrDataKeyNotFound) && create {
+		if err := createTeamDataKey(ctx, teamID); err != nil {
+			return nil, err
+		}
+		wrapped, masterKeyID, err = dataKeyStore.GetDataKey(teamID)
+	}
+	if errors.Is(err, ErrDataKeyNotFound) {
+		return nil, fmt.Errorf("%w: team %s", ErrDataKeyDestroyed, teamID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data key for team %s: %w", teamID, err)
+	}
+
+	key, err := keyProvider.UnwrapKey(ctx, wrapped, masterKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key for team %s: %w", teamID, err)
+	}
+
+	dataKeyMu.Lock()
+	dataKeyCache[teamID] = cachedDataKey{key: key, expiresAt: time.Now().Add(dataKeyCacheTTL)}
+	dataKeyMu.Unlock()
+	return key, nil
+}
+
+func createTeamDataKey(ctx context.Context, teamID string) error {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+	wrapped, masterKeyID, err := keyProvider.WrapKey(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to wrap data key for team %s: %w", teamID, err)
+	}
+	// Losing a race with another instance is fine; everyone then reads the
+	// winner's key.
+	if _, err := dataKeyStore.CreateDataKey(teamID, wrapped, masterKeyID); err != nil {
+		return fmt.Errorf("failed to store data key for team %s: %w", teamID, err)
+	}
+	return nil
+}
+
+// EncryptForTeam encrypts plainText with the team's data key.
+func EncryptForTeam(teamID, plainText string) (string, error) {
+	if dataKeyStore == nil {
+		return Encrypt(plainText)
+	}
+	key, err := teamDataKey(teamID, true)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(key, plainText)
+	if err != nil {
+		return "", err
+	}
+	return dataKeyPrefix + sealed, nil
+}
+
+// DecryptForTeam decrypts a value written by EncryptForTeam, or by Encrypt
+// before the team had a data key.
+func DecryptForTeam(teamID, encrypted string) (string, error) {
+	rest, ok := strings.CutPrefix(encrypted, dataKeyPrefix)
+	if !ok {
+		return Decrypt(encrypted)
+	}
+	if dataKeyStore == nil {
+		return "", errors.New("value is encrypted with a team data key but no data key store is configured")
+	}
+	key, err := teamDataKey(teamID, false)
+	if err != nil {
+		return "", err
+	}
+	return open(key, rest)
+}
+
+// ReencryptForTeam rewrites encrypted the way EncryptForTeam would now.
+func ReencryptForTeam(teamID, encrypted string) (string, error) {
+	plainText, err := DecryptForTeam(teamID, encrypted)
+	if err != nil {
+		return "", err
+	}
+	return EncryptForTeam(teamID, plainText)
+}
+
+// RewrapDataKey re-wraps a stored data key with the provider's current
+// master key.
+func RewrapDataKey(wrapped []byte, masterKeyID string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyProviderTimeout)
+	defer cancel()
+
+	key, err := keyProvider.UnwrapKey(ctx, wrapped, masterKeyID)
+	if err != nil {
+		return nil, "", err
+	}
+	return keyProvider.WrapKey(ctx, key)
+}
+
+// CurrentMasterKeyID is the master key new data keys are wrapped with.
+func CurrentMasterKeyID() string {
+	return keyProvider.CurrentKeyID()
+}
+
+// DestroyTeamDataKey crypto-shreds the team's data: once the key is gone,
+// nothing encrypted with it, including copies in backups, can be read.
+func DestroyTeamDataKey(teamID string) error {
+	ForgetTeamDataKey(teamID)
+	if dataKeyStore == nil {
+		return nil
+	}
+	return dataKeyStore.DeleteDataKey(teamID)
+}
+
+// ForgetTeamDataKey drops the team's cached data key once the stored key
+// has been deleted, so a reinstall gets a new one.
+func ForgetTeamDataKey(teamID string) {
+	dataKeyMu.Lock()
+	delete(dataKeyCache, teamID)
+	dataKeyMu.Unlock()
+}
+
+// LocalKeyProvider wraps data keys with the keyring loaded by InitCrypto,
+// from ENCRYPTION_KEYS, ENCRYPTION_KEYS_FILE or ENCRYPTION_KEY.
+type LocalKeyProvider struct{}
+
+func (LocalKeyProvider) WrapKey(ctx context.Context, dataKey []byte) ([]byte, string, error) {
+	sealed, err := seal(keys[primaryKeyID], string(dataKey))
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(sealed), primaryKeyID, nil
+}
+
+func (LocalKeyProvider) UnwrapKey(ctx context.Context, wrapped []byte, masterKeyID string) ([]byte, error) {
+	key, ok := keys[masterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, masterKeyID)
+	}
+	dataKey, err := open(key, string(wrapped))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(dataKey), nil
+}
+
+func (LocalKeyProvider) CurrentKeyID() string {
+	return primaryKeyID
+}
+
+// KMSClient is the subset of a cloud KMS API needed to wrap data keys. An
+// adapter for AWS KMS, Google Cloud KMS or Vault transit can be plugged in
+// with NewKMSKeyProvider.
+type KMSClient interface {
+	Encrypt(ctx context.Context, keyID string, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// KMSKeyProvider wraps data keys with a key held in a KMS; the master key
+// never leaves it.
+type KMSKeyProvider struct {
+	client KMSClient
+	keyID  string
+}
+
+func NewKMSKeyProvider(client KMSClient, keyID string) *KMSKeyProvider {
+	return &KMSKeyProvider{client: client, keyID: keyID}
+}
+
+func (p *KMSKeyProvider) WrapKey(ctx context.Context, dataKey []byte) ([]byte, string, error) {
+	wrapped, err := p.client.Encrypt(ctx, p.keyID, dataKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("kms encrypt with %s: %w", p.keyID, err)
+	}
+	return wrapped, p.keyID, nil
+}
+
+func (p *KMSKeyProvider) UnwrapKey(ctx context.Context, wrapped []byte, masterKeyID string) ([]byte, error) {
+	dataKey, err := p.client.Decrypt(ctx, masterKeyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("kms decrypt with %s: %w", masterKeyID, err)
+	}
+	return dataKey, nil
+}
+
+func (p *KMSKeyProvider) CurrentKeyID() string {
+	return p.keyID
+}
+
+func seal(key []byte, plainText string) (string, error) {
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	cipherText := aesGCM.Seal(nonce, nonce, []byte(plainText), nil)
+	return base64.StdEncoding.EncodeToString(cipherText), nil
+}
+
+func open(key []byte, sealed string) (string, error) {
+	cipherData, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := aesGCM.NonceSize()
+	if len(cipherData) < nonceSize {
+		return "", errors.New("cipher text too short")
+	}
+
+	plainText, err := aesGCM.Open(nil, cipherData[:nonceSize], cipherData[nonceSize:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plainText), nil
+}
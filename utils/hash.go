@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"MidayBrief/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// When HASH_KEYS isn't set, a hash key is derived from each encryption key
+// and named after it, e.g. derived-k1, so hashes keep matching while the
+// encryption keys are rotated.
+const derivedHashKeyPrefix = "derived-"
+
+var (
+	hashKeys         map[string][]byte
+	primaryHashKeyID string
+)
+
+// LoadHashKeys replaces the keys used for message hashes. keyList has the
+// same "id:key,id:key" format as ENCRYPTION_KEYS and primaryID picks the
+// one new hashes use (default: the last listed). With no keys, one is
+// derived from each encryption key, which must be loaded first, and the
+// primary encryption key's is used.
+func LoadHashKeys(keyList, primaryID string) error {
+	loaded := make(map[string][]byte)
+	var lastID string
+
+	entries, err := config.ParseKeyList("HASH_KEYS", keyList)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		loaded[entry.ID] = entry.Key
+		lastID = entry.ID
+	}
+
+	if len(loaded) == 0 {
+		if keys[primaryKeyID] == nil {
+			return errors.New("no hash key configured and no encryption key to derive one from")
+		}
+		slog.Info("HASH_KEYS is not set; deriving the message hash keys from the encryption keys")
+		for id, key := range keys {
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte("midaybrief message hash"))
+			loaded[derivedHashKeyPrefix+id] = mac.Sum(nil)
+		}
+		lastID = derivedHashKeyPrefix + primaryKeyID
+	}
+
+	if primaryID == "" {
+		primaryID = lastID
+	}
+	if _, ok := loaded[primaryID]; !ok {
+		return fmt.Errorf("HASH_KEY_ID %s is not in HASH_KEYS", primaryID)
+	}
+
+	hashKeys, primaryHashKeyID = loaded, primaryID
+	return nil
+}
+
+func messageHash(keyID, userID, text string) string {
+	mac := hmac.New(sha256.New, hashKeys[keyID])
+	mac.Write([]byte(userID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(text))
+	return keyID + ":" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// MessageHash is the keyed hash of a user's update stored for duplicate
+// detection. Including the user means equal updates from different people
+// hash differently.
+func MessageHash(userID, text string) string {
+	return messageHash(primaryHashKeyID, userID, text)
+}
+
+// MessageHashCandidates returns the update's hash under every loaded hash
+// key, the current one first, so duplicates are still found while old
+// hashes are being migrated.
+func MessageHashCandidates(userID, text string) []string {
+	hashes := []string{MessageHash(userID, text)}
+	for id := range hashKeys {
+		if id != primaryHashKeyID {
+			hashes = append(hashes, messageHash(id, userID, text))
+		}
+	}
+	return hashes
+}
+
+// HashPrefix is how hashes written now start.
+func HashPrefix() string {
+	return primaryHashKeyID + ":"
+}
+
+// NeedsRehash reports whether hash wasn't made with the current hash key,
+// including unkeyed hashes from before keyed hashes existed.
+func NeedsRehash(hash string) bool {
+	return !strings.HasPrefix(hash, HashPrefix())
+}
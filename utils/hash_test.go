@@ -0,0 +1,32 @@
+package utils_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"MidayBrief/config"
+	"MidayBrief/utils"
+)
+
+func TestDerivedHashesMatchAcrossKeyRotation(t *testing.T) {
+	const key1, key2 = "0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210"
+	if err := utils.InitCrypto(config.Crypto{Keys: "k1:" + key1}); err != nil {
+		t.Fatal(err)
+	}
+	old := utils.MessageHash("U1", "shipped it")
+	if !strings.HasPrefix(old, "derived-k1:") {
+		t.Fatalf("hash %q isn't named after the derived key", old)
+	}
+
+	if err := utils.InitCrypto(config.Crypto{Keys: "k1:" + key1 + ",k2:" + key2}); err != nil {
+		t.Fatal(err)
+	}
+	candidates := utils.MessageHashCandidates("U1", "shipped it")
+	if len(candidates) != 2 || !strings.HasPrefix(candidates[0], "derived-k2:") || !slices.Contains(candidates, old) {
+		t.Errorf("candidates %q, want the derived-k2 hash first and the old one", candidates)
+	}
+	if !utils.NeedsRehash(old) || utils.NeedsRehash(candidates[0]) {
+		t.Error("NeedsRehash doesn't follow the primary key")
+	}
+}
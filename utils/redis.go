@@ -1,35 +1,51 @@
 package utils
 
 import (
+	"MidayBrief/logging"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
-	"os"
-	"strings"
+	"log/slog"
 	"time"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
 var RedisClient *redis.Client
 
-func InitRedis() {
-	url := os.Getenv("REDIS_URL")
-	opt, err := redis.ParseURL(strings.TrimSpace(url))
+// InitRedis connects to the Redis server at url.
+func InitRedis(url string) {
+	opt, err := redis.ParseURL(url)
 	if err != nil {
-		log.Fatalf("failed to parse redis url: %v", err)
+		logging.Fatal("Failed to parse REDIS_URL", "error", err)
 	}
 	RedisClient = redis.NewClient(opt)
+	RedisClient.AddHook(metricsHook{})
+	// Command arguments include prompt answers, so spans leave them out.
+	if err := redisotel.InstrumentTracing(RedisClient, redisotel.WithDBStatement(false)); err != nil {
+		logging.Fatal("Failed to instrument Redis tracing", "error", err)
+	}
 
 	_, err = RedisClient.Ping(context.Background()).Result()
 	if err != nil {
-		log.Fatalf("Redis connection failed: %v", err)
+		logging.Fatal("Redis connection failed", "error", err)
 	}
-	log.Println("Redis connection established")
+	slog.Info("Redis connection established")
+}
+
+// PingRedis checks that Redis answers.
+func PingRedis(ctx context.Context) error {
+	return RedisClient.Ping(ctx).Err()
 }
 
+// PromptStateTTL is how long an unanswered prompt session is kept.
+const PromptStateTTL = 12 * time.Hour
+
 type PromptState struct {
+	StandupID uint              `json:"standup_id"`
 	Step      int               `json:"step"`
 	Responses map[string]string `json:"responses"`
 }
@@ -38,13 +54,13 @@ func GetPromptStateKey(teamID, userID string) string {
 	return fmt.Sprintf("prompt_state:%s:%s", teamID, userID)
 }
 
-func SetPromptState(teamID, userID string, state PromptState, ctx context.Context) any {
+func SetPromptState(teamID, userID string, state PromptState, ctx context.Context) error {
 	key := GetPromptStateKey(teamID, userID)
 	data, err := json.Marshal(state)
 	if err != nil {
 		return err
 	}
-	return RedisClient.Set(ctx, key, data, 12*time.Hour).Err()
+	return RedisClient.Set(ctx, key, data, PromptStateTTL).Err()
 }
 
 func GetPromptState(teamID, userID string, ctx context.Context) (*PromptState, error) {
@@ -66,3 +82,142 @@ func DeletePromptState(teamID, userID string, ctx context.Context) error {
 	key := GetPromptStateKey(teamID, userID)
 	return RedisClient.Del(ctx, key).Err()
 }
+
+func getOAuthStateKey(state string) string {
+	return fmt.Sprintf("oauth_state:%s", state)
+}
+
+// SaveOAuthState records an install-flow state value that can be consumed
+// once before ttl expires.
+func SaveOAuthState(state string, ttl time.Duration, ctx context.Context) error {
+	return RedisClient.Set(ctx, getOAuthStateKey(state), 1, ttl).Err()
+}
+
+// ConsumeOAuthState reports whether state was issued and not yet used, and
+// invalidates it.
+func ConsumeOAuthState(state string, ctx context.Context) (bool, error) {
+	err := RedisClient.GetDel(ctx, getOAuthStateKey(state)).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SessionTTL is how long a dashboard sign-in lasts.
+const SessionTTL = 12 * time.Hour
+
+// Session is a user signed in to the dashboard with Slack.
+type Session struct {
+	TeamID    string `json:"team_id"`
+	UserID    string `json:"user_id"`
+	Name      string `json:"name"`
+	CSRFToken string `json:"csrf_token"`
+}
+
+func getSessionKey(id string) string {
+	return fmt.Sprintf("session:%s", id)
+}
+
+// SaveSession stores a dashboard session for SessionTTL.
+func SaveSession(id string, session Session, ctx context.Context) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return RedisClient.Set(ctx, getSessionKey(id), data, SessionTTL).Err()
+}
+
+// GetSession returns the session, or nil if it expired or never existed.
+func GetSession(id string, ctx context.Context) (*Session, error) {
+	val, err := RedisClient.Get(ctx, getSessionKey(id)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal([]byte(val), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func DeleteSession(id string, ctx context.Context) error {
+	return RedisClient.Del(ctx, getSessionKey(id)).Err()
+}
+
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// AcquireLock takes a best-effort distributed lock that expires after ttl.
+// It returns the token needed to release the lock, or "" if someone else
+// holds it.
+func AcquireLock(key string, ttl time.Duration, ctx context.Context) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	ok, err := RedisClient.SetNX(ctx, "lock:"+key, token, ttl).Result()
+	if err != nil || !ok {
+		return "", err
+	}
+	return token, nil
+}
+
+// ReleaseLock releases the lock if it is still held with token.
+func ReleaseLock(key, token string, ctx context.Context) error {
+	return releaseLockScript.Run(ctx, RedisClient, []string{"lock:" + key}, token).Err()
+}
+
+// UserProfile is the subset of a Slack user's profile cached for rendering
+// summaries and resolving timezones.
+type UserProfile struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	TZ          string `json:"tz"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+// UserProfileTTL is how long a cached user profile is used before being
+// fetched from Slack again.
+const UserProfileTTL = 24 * time.Hour
+
+func getUserProfileKey(teamID, userID string) string {
+	return fmt.Sprintf("user_profile:%s:%s", teamID, userID)
+}
+
+func GetCachedUserProfile(teamID, userID string, ctx context.Context) (*UserProfile, error) {
+	val, err := RedisClient.Get(ctx, getUserProfileKey(teamID, userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var profile UserProfile
+	if err := json.Unmarshal([]byte(val), &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func CacheUserProfiles(teamID string, profiles []UserProfile, ctx context.Context) error {
+	pipe := RedisClient.Pipeline()
+	for _, profile := range profiles {
+		data, err := json.Marshal(profile)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, getUserProfileKey(teamID, profile.ID), data, UserProfileTTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
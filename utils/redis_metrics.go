@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"MidayBrief/metrics"
+	"context"
+	"errors"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// metricsHook counts failed Redis commands by command name. Missing keys
+// (redis.Nil) are expected and not counted.
+type metricsHook struct{}
+
+func (metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			metrics.StorageErrors.WithLabelValues("redis", "dial").Inc()
+		}
+		return conn, err
+	}
+}
+
+func (metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			metrics.StorageErrors.WithLabelValues("redis", cmd.Name()).Inc()
+		}
+		return err
+	}
+}
+
+func (metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			metrics.StorageErrors.WithLabelValues("redis", "pipeline").Inc()
+		}
+		return err
+	}
+}
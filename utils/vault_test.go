@@ -0,0 +1,84 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"MidayBrief/config"
+	"MidayBrief/store"
+	"MidayBrief/utils"
+)
+
+// fakeTransit is a Vault transit engine that "encrypts" by prefixing.
+func fakeTransit(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		var data map[string]string
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/midaybrief":
+			data = map[string]string{"ciphertext": "vault:v1:" + body["plaintext"]}
+		case "/v1/transit/decrypt/midaybrief":
+			data = map[string]string{"plaintext": strings.TrimPrefix(body["ciphertext"], "vault:v1:")}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestInitCryptoWrapsDataKeysWithVault(t *testing.T) {
+	srv := fakeTransit(t)
+	err := utils.InitCrypto(config.Crypto{
+		LegacyKey:   "0123456789abcdef0123456789abcdef",
+		KMSProvider: config.KMSProviderVault,
+		KMSKeyID:    "midaybrief",
+		VaultAddr:   srv.URL,
+		VaultToken:  "vault-token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { utils.SetKeyProvider(utils.LocalKeyProvider{}) })
+	mem := store.NewMemory()
+	utils.SetDataKeyStore(mem)
+
+	encrypted, err := utils.EncryptForTeam("T1", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, masterKeyID, err := mem.GetDataKey("T1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if masterKeyID != "midaybrief" || !strings.HasPrefix(string(wrapped), "vault:v1:") {
+		t.Errorf("data key wrapped as %q with %q, want by Vault with midaybrief", wrapped, masterKeyID)
+	}
+
+	utils.ForgetTeamDataKey("T1")
+	if plain, err := utils.DecryptForTeam("T1", encrypted); err != nil || plain != "secret" {
+		t.Errorf("DecryptForTeam: got %q, %v", plain, err)
+	}
+}
+
+func TestVaultTransitClientReportsErrors(t *testing.T) {
+	srv := fakeTransit(t)
+	client := utils.NewVaultTransitClient(srv.URL, "wrong-token")
+	_, err := client.Encrypt(context.Background(), "midaybrief", []byte("key"))
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Encrypt with a bad token: got %v, want Vault's error", err)
+	}
+}